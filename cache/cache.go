@@ -33,7 +33,7 @@ func (r *RedisCache) Get(ctx context.Context, key string) (data []byte, err erro
 	return redis.Bytes(cl.Do("GET", key))
 }
 
-// GetKeys method
+// GetKeys method, not supported in redis cluster mode
 func (r *RedisCache) GetKeys(ctx context.Context, pattern string) (data []string, err error) {
 	trace := tracer.StartTrace(ctx, "redis:get_keys")
 	defer func() { trace.SetError(err); tracer.Log(trace.Context(), "result", data); trace.Finish() }()
@@ -2,6 +2,22 @@ package candihelper
 
 import (
 	"encoding/json"
+	"time"
+)
+
+const (
+	// CronOverlapAllow cron job overlap policy, start new execution even if previous execution of the same job is still running (default)
+	CronOverlapAllow = "allow"
+	// CronOverlapSkip cron job overlap policy, skip execution if previous execution of the same job is still running
+	CronOverlapSkip = "skip"
+	// CronOverlapQueue cron job overlap policy, queue one more execution after previous execution of the same job is done,
+	// another execution will be skipped if queue is not empty
+	CronOverlapQueue = "queue"
+
+	// CronCatchUpLatest cron job catch up mode, run only the latest missed execution after service downtime
+	CronCatchUpLatest = "latest"
+	// CronCatchUpAll cron job catch up mode, run all missed executions after service downtime (ordered from the oldest)
+	CronCatchUpAll = "all"
 )
 
 // CronJobKey model
@@ -9,6 +25,49 @@ type CronJobKey struct {
 	JobName  string `json:"jobName"`
 	Args     string `json:"args"`
 	Interval string `json:"interval"`
+	Timezone string `json:"timezone,omitempty"`
+	Overlap  string `json:"overlap,omitempty"`
+	Timeout  string `json:"timeout,omitempty"`
+	CatchUp  string `json:"catchUp,omitempty"`
+	Lookback string `json:"lookback,omitempty"`
+}
+
+// CronJobKeyOption option func for additional cron job configuration
+type CronJobKeyOption func(*CronJobKey)
+
+// CronJobWithTimezone option, set IANA timezone (example: Asia/Jakarta) for computing job activation time.
+// Default timezone from CRON_SCHEDULER_TIMEZONE environment, or local timezone of process if environment is empty
+func CronJobWithTimezone(timezone string) CronJobKeyOption {
+	return func(c *CronJobKey) {
+		c.Timezone = timezone
+	}
+}
+
+// CronJobWithOverlapPolicy option, set policy when previous execution of the same job is still running,
+// must one of CronOverlapAllow (default), CronOverlapSkip, or CronOverlapQueue
+func CronJobWithOverlapPolicy(policy string) CronJobKeyOption {
+	return func(c *CronJobKey) {
+		c.Overlap = policy
+	}
+}
+
+// CronJobWithTimeout option, set maximum execution time of job, context in handler will be canceled when timeout exceeded
+func CronJobWithTimeout(timeout time.Duration) CronJobKeyOption {
+	return func(c *CronJobKey) {
+		c.Timeout = timeout.String()
+	}
+}
+
+// CronJobWithCatchUp option, run missed executions when service is started (or acquire lock) after downtime,
+// mode must one of CronCatchUpLatest or CronCatchUpAll, maxLookback for maximum range of missed executions (default 24 hours).
+// Last success activation time is persisted in fire time store (CRON_SCHEDULER_CATCH_UP_STORE environment)
+func CronJobWithCatchUp(mode string, maxLookback time.Duration) CronJobKeyOption {
+	return func(c *CronJobKey) {
+		c.CatchUp = mode
+		if maxLookback > 0 {
+			c.Lookback = maxLookback.String()
+		}
+	}
 }
 
 // String implement stringer
@@ -25,22 +84,39 @@ Allowed interval:
 
 * custom start time and repeat duration, example:
 	- 23:00@daily, will repeated at 23:00 every day
-	- 23:00@weekly, will repeated at 23:00 every week
+	- 23:00@weekly, will repeated at 23:00 every week (same weekday with start day)
+	- 23:00@monthly, will repeated at 23:00 every month (same day of month with start day)
 	- 23:00@10s, will repeated at 23:00 and next repeat every 10 seconds
+
+* standard cron expression with five fields (minute hour day-of-month month day-of-week)
+or six fields (second minute hour day-of-month month day-of-week), example:
+	- 0,30 9-17 * * MON-FRI, will repeated every 30 minutes from 09:00 to 17:30 on weekdays
+	- 0 30 2 1 * *, will repeated at 02:30:00 on first day of every month
+
+* cron descriptor: @yearly (or @annually), @monthly, @weekly, @daily (or @midnight), @hourly, @every <duration>
 */
-func CronJobKeyToString(jobName, args, interval string) string {
-	return CronJobKey{
+func CronJobKeyToString(jobName, args, interval string, opts ...CronJobKeyOption) string {
+	cronKey := CronJobKey{
 		JobName: jobName, Args: args, Interval: interval,
-	}.String()
+	}
+	for _, opt := range opts {
+		opt(&cronKey)
+	}
+	return cronKey.String()
 }
 
 // ParseCronJobKey helper
 func ParseCronJobKey(str string) (jobName, args, interval string) {
-	var cronKey CronJobKey
-	json.Unmarshal([]byte(str), &cronKey)
+	cronKey := ParseCronJobKeyModel(str)
 	return cronKey.JobName, cronKey.Args, cronKey.Interval
 }
 
+// ParseCronJobKeyModel helper, parse cron job key with all additional configuration
+func ParseCronJobKeyModel(str string) (cronKey CronJobKey) {
+	json.Unmarshal([]byte(str), &cronKey)
+	return
+}
+
 // RedisMessage model for redis subscriber key
 type RedisMessage struct {
 	HandlerName string `json:"h"`
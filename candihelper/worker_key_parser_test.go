@@ -14,3 +14,15 @@ func TestRedisPubSubKeyTopic(t *testing.T) {
 	assert.Equal(t, "scheduled-notif", handlerName)
 	assert.Equal(t, "{\"test\":\"testing\"}", message)
 }
+
+func TestCronJobKey(t *testing.T) {
+	got := CronJobKeyToString("daily-report", "message", "0 2 * * *")
+	assert.Equal(t, "{\"jobName\":\"daily-report\",\"args\":\"message\",\"interval\":\"0 2 * * *\"}", got)
+
+	got = CronJobKeyToString("daily-report", "message", "0 2 * * *", CronJobWithTimezone(TimeZoneAsia))
+	jobName, args, interval := ParseCronJobKey(got)
+	assert.Equal(t, "daily-report", jobName)
+	assert.Equal(t, "message", args)
+	assert.Equal(t, "0 2 * * *", interval)
+	assert.Equal(t, TimeZoneAsia, ParseCronJobKeyModel(got).Timezone)
+}
@@ -17,6 +17,9 @@ const (
 
 	// ContextKeyWorkerKey context key
 	ContextKeyWorkerKey ContextKey = "workerKey"
+
+	// ContextKeyWorkerHeader context key
+	ContextKeyWorkerHeader ContextKey = "workerHeader"
 )
 
 // SetToContext will set context with specific key
@@ -38,3 +41,10 @@ func ParseTokenClaimFromContext(ctx context.Context) *TokenClaim {
 func ParseWorkerKeyFromContext(ctx context.Context) []byte {
 	return GetValueFromContext(ctx, ContextKeyWorkerKey).([]byte)
 }
+
+// ParseWorkerHeaderFromContext parse consumed message header (example: kafka message header) from given context,
+// return nil if not exist
+func ParseWorkerHeaderFromContext(ctx context.Context) map[string]string {
+	header, _ := GetValueFromContext(ctx, ContextKeyWorkerHeader).(map[string]string)
+	return header
+}
@@ -1,5 +1,7 @@
 package candishared
 
+import "time"
+
 // PublisherArgument declare publisher argument
 type PublisherArgument struct {
 	// Topic or queue name
@@ -8,4 +10,6 @@ type PublisherArgument struct {
 	Header      map[string]interface{}
 	ContentType string
 	Data        interface{}
+	// Delay message delivery, currently supported in redis publisher (scheduled redis subscriber job)
+	Delay time.Duration
 }
@@ -0,0 +1,67 @@
+package candiutils
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golangid/candi/codebase/interfaces"
+)
+
+const (
+	// LockerBackendConsul distributed lock backend using consul session
+	LockerBackendConsul = "consul"
+	// LockerBackendRedis distributed lock backend using redis SET NX PX with periodic renewal
+	LockerBackendRedis = "redis"
+	// LockerBackendPostgres distributed lock backend using postgres session advisory lock
+	LockerBackendPostgres = "postgres"
+)
+
+// DistributedLocker abstraction for distributed lock, used for leader election of worker when run in multiple instance
+type DistributedLocker interface {
+	// RetryLockAcquire attempts to acquire the lock until success, send to acquired channel when lock is acquired
+	// and send to released channel when acquired lock is released (destroyed or lost). When lock is lost, released is sent
+	// immediately and holder must stop work which require the lock, then acquire the lock again
+	RetryLockAcquire(value map[string]string, acquired chan<- struct{}, released chan<- struct{})
+	// DestroySession release acquired lock, so that lock can be acquired by another instance
+	DestroySession() error
+}
+
+// DistributedLockerConfig is used to configure creation of distributed locker
+type DistributedLockerConfig struct {
+	// Backend must one of LockerBackendConsul, LockerBackendRedis, or LockerBackendPostgres
+	Backend           string
+	Key               string
+	LockRetryInterval time.Duration
+	ConsulAgentHost   string
+	RedisPool         interfaces.RedisPool
+	SQLDatabase       interfaces.SQLDatabase
+}
+
+// NewDistributedLocker constructor, create locker from selected backend
+func NewDistributedLocker(opt *DistributedLockerConfig) (DistributedLocker, error) {
+	switch opt.Backend {
+	case LockerBackendConsul:
+		return NewConsul(&ConsulConfig{
+			ConsulAgentHost: opt.ConsulAgentHost, ConsulKey: opt.Key, LockRetryInterval: opt.LockRetryInterval,
+		})
+
+	case LockerBackendRedis:
+		if opt.RedisPool == nil {
+			return nil, errors.New("redis locker: redis pool is not initialized")
+		}
+		return NewRedisLocker(&RedisLockerConfig{
+			Pool: opt.RedisPool.WritePool(), Key: opt.Key, LockRetryInterval: opt.LockRetryInterval,
+		}), nil
+
+	case LockerBackendPostgres:
+		if opt.SQLDatabase == nil {
+			return nil, errors.New("postgres locker: sql database is not initialized")
+		}
+		return NewPostgresLocker(&PostgresLockerConfig{
+			DB: opt.SQLDatabase.WriteDB(), Key: opt.Key, LockRetryInterval: opt.LockRetryInterval,
+		}), nil
+	}
+
+	return nil, fmt.Errorf(`invalid distributed lock backend "%s"`, opt.Backend)
+}
@@ -0,0 +1,151 @@
+package candiutils
+
+import (
+	"context"
+	"database/sql"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/golangid/candi/logger"
+)
+
+// PostgresLocker distributed lock using postgres session advisory lock,
+// lock is held by dedicated connection and released when connection is closed
+type PostgresLocker struct {
+	DB                  *sql.DB
+	Key                 string
+	LockRetryInterval   time.Duration
+	HealthCheckInterval time.Duration
+
+	mu     sync.Mutex
+	lockID int64
+	conn   *sql.Conn
+	done   chan struct{}
+}
+
+// PostgresLockerConfig is used to configure creation of postgres locker
+type PostgresLockerConfig struct {
+	DB                  *sql.DB
+	Key                 string
+	LockRetryInterval   time.Duration
+	HealthCheckInterval time.Duration
+}
+
+// NewPostgresLocker constructor, advisory lock id is hash of key
+func NewPostgresLocker(opt *PostgresLockerConfig) *PostgresLocker {
+	h := fnv.New64a()
+	h.Write([]byte(opt.Key))
+
+	p := &PostgresLocker{
+		DB:                  opt.DB,
+		Key:                 opt.Key,
+		LockRetryInterval:   30 * time.Second,
+		HealthCheckInterval: 10 * time.Second,
+		lockID:              int64(h.Sum64()),
+	}
+	if opt.LockRetryInterval != 0 {
+		p.LockRetryInterval = opt.LockRetryInterval
+	}
+	if opt.HealthCheckInterval != 0 {
+		p.HealthCheckInterval = opt.HealthCheckInterval
+	}
+	return p
+}
+
+// RetryLockAcquire attempts to acquire the lock at `LockRetryInterval`, value is not stored in postgres
+func (p *PostgresLocker) RetryLockAcquire(value map[string]string, acquired chan<- struct{}, released chan<- struct{}) {
+	ticker := time.NewTicker(p.LockRetryInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		lock, err := p.acquireLock(released)
+		if err != nil {
+			logger.LogYellow("Cannot acquire postgres advisory lock, " + err.Error())
+			continue
+		}
+		if lock {
+			break
+		}
+	}
+
+	acquired <- struct{}{}
+}
+
+// DestroySession method, unlock advisory lock and close dedicated connection
+func (p *PostgresLocker) DestroySession() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.done == nil {
+		return nil
+	}
+	close(p.done)
+	p.done = nil
+
+	defer p.conn.Close()
+	_, err := p.conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, p.lockID)
+	return err
+}
+
+func (p *PostgresLocker) acquireLock(released chan<- struct{}) (bool, error) {
+	ctx := context.Background()
+	conn, err := p.DB.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, p.lockID).Scan(&locked); err != nil {
+		conn.Close()
+		return false, err
+	}
+	if !locked {
+		conn.Close()
+		return false, nil
+	}
+
+	doneCh := make(chan struct{})
+	p.mu.Lock()
+	p.conn, p.done = conn, doneCh
+	p.mu.Unlock()
+
+	go func() {
+		if p.checkConnection(conn, doneCh) {
+			// lock is lost, signal holder immediately so that stop work which require the lock
+			released <- struct{}{}
+			return
+		}
+		time.Sleep(p.LockRetryInterval)
+		released <- struct{}{}
+	}()
+	return true, nil
+}
+
+// checkConnection check dedicated connection periodically until session destroyed or connection is lost (lock released by postgres),
+// return true if lock is lost
+func (p *PostgresLocker) checkConnection(conn *sql.Conn, doneCh <-chan struct{}) (lost bool) {
+	ticker := time.NewTicker(p.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-doneCh:
+			return false
+
+		case <-ticker.C:
+			if err := conn.PingContext(context.Background()); err != nil {
+				p.mu.Lock()
+				defer p.mu.Unlock()
+				if p.done != doneCh {
+					// session is destroyed while checking connection
+					return false
+				}
+				logger.LogRed("Postgres advisory lock " + p.Key + " is lost, " + err.Error())
+				p.done = nil
+				conn.Close()
+				return true
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package candiutils
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakePostgres sql connector for test, advisory lock result and ping error is configured from test
+type fakePostgres struct {
+	mu      sync.Mutex
+	locked  bool
+	pingErr error
+	queries []string
+}
+
+func (f *fakePostgres) Connect(context.Context) (driver.Conn, error) {
+	return &fakePostgresConn{f}, nil
+}
+func (f *fakePostgres) Driver() driver.Driver { return nil }
+
+func (f *fakePostgres) setPingErr(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pingErr = err
+}
+
+type fakePostgresConn struct{ db *fakePostgres }
+
+func (c *fakePostgresConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepare is not supported")
+}
+func (c *fakePostgresConn) Close() error              { return nil }
+func (c *fakePostgresConn) Begin() (driver.Tx, error) { return nil, errors.New("tx is not supported") }
+func (c *fakePostgresConn) Ping(context.Context) error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	return c.db.pingErr
+}
+func (c *fakePostgresConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.queries = append(c.db.queries, query)
+	return &fakeBoolRows{value: c.db.locked}, nil
+}
+func (c *fakePostgresConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.queries = append(c.db.queries, query)
+	return driver.RowsAffected(0), nil
+}
+
+type fakeBoolRows struct {
+	value bool
+	done  bool
+}
+
+func (r *fakeBoolRows) Columns() []string { return []string{"locked"} }
+func (r *fakeBoolRows) Close() error      { return nil }
+func (r *fakeBoolRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = r.value
+	return nil
+}
+
+func newTestPostgresLocker(db *fakePostgres) *PostgresLocker {
+	return NewPostgresLocker(&PostgresLockerConfig{
+		DB: sql.OpenDB(db), Key: "lock", LockRetryInterval: 10 * time.Millisecond, HealthCheckInterval: 10 * time.Millisecond,
+	})
+}
+
+func TestPostgresLockerAcquire(t *testing.T) {
+	db := &fakePostgres{}
+	locker := newTestPostgresLocker(db)
+	lock, err := locker.acquireLock(make(chan struct{}, 1))
+	assert.NoError(t, err)
+	assert.False(t, lock, "lock is owned by another instance")
+
+	db.locked = true
+	released := make(chan struct{}, 1)
+	lock, err = locker.acquireLock(released)
+	assert.NoError(t, err)
+	assert.True(t, lock)
+
+	assert.NoError(t, locker.DestroySession())
+	select {
+	case <-released:
+	case <-time.After(time.Second):
+		t.Fatal("holder is not signaled when session destroyed")
+	}
+	db.mu.Lock()
+	assert.Equal(t, `SELECT pg_advisory_unlock($1)`, db.queries[len(db.queries)-1])
+	db.mu.Unlock()
+}
+
+func TestPostgresLockerLost(t *testing.T) {
+	db := &fakePostgres{locked: true}
+	locker := newTestPostgresLocker(db)
+	released := make(chan struct{}, 1)
+	lock, err := locker.acquireLock(released)
+	assert.NoError(t, err)
+	assert.True(t, lock)
+
+	db.setPingErr(errors.New("connection reset by peer"))
+	select {
+	case <-released:
+	case <-time.After(time.Second):
+		t.Fatal("holder is not signaled when lock is lost")
+	}
+	assert.NoError(t, locker.DestroySession(), "session is already released")
+}
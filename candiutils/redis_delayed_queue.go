@@ -0,0 +1,240 @@
+package candiutils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golangid/candi/candihelper"
+	"github.com/gomodule/redigo/redis"
+	"github.com/google/uuid"
+)
+
+// ErrDelayedMessageReclaimed claimed message is recovered after visibility timeout (and may be claimed again by another consumer),
+// acknowledge, retry, or extend from previous claim is ignored
+var ErrDelayedMessageReclaimed = errors.New("delayed message is recovered after visibility timeout")
+
+// fence check message is still claimed by caller, visibility deadline in processing set is used as claim token
+const fenceDelayedQueueScript = `local score = redis.call("ZSCORE", KEYS[1], ARGV[1])
+if not score or tonumber(score) ~= tonumber(ARGV[2]) then
+	return 0
+end
+`
+
+var (
+	// move due messages from delayed set to processing set, processing score is visibility deadline, increment attempt of message
+	claimDelayedQueueScript = redis.NewScript(4, `local ids = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, ARGV[2])
+local result = {}
+for _, id in ipairs(ids) do
+	redis.call("ZREM", KEYS[1], id)
+	redis.call("ZADD", KEYS[2], ARGV[3], id)
+	table.insert(result, id)
+	table.insert(result, redis.call("HGET", KEYS[3], id))
+	table.insert(result, redis.call("HINCRBY", KEYS[4], id, 1))
+end
+return result`)
+	// move unacknowledged messages which exceed visibility deadline back to delayed set
+	recoverDelayedQueueScript = redis.NewScript(2, `local ids = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, ARGV[2])
+for _, id in ipairs(ids) do
+	redis.call("ZREM", KEYS[1], id)
+	redis.call("ZADD", KEYS[2], ARGV[1], id)
+end
+return #ids`)
+	// remove claimed message, the payload, and the attempt
+	ackDelayedQueueScript = redis.NewScript(3, fenceDelayedQueueScript+`redis.call("ZREM", KEYS[1], ARGV[1])
+redis.call("HDEL", KEYS[2], ARGV[1])
+redis.call("HDEL", KEYS[3], ARGV[1])
+return 1`)
+	// move claimed message back to delayed set with new due time
+	retryDelayedQueueScript = redis.NewScript(2, fenceDelayedQueueScript+`redis.call("ZREM", KEYS[1], ARGV[1])
+redis.call("ZADD", KEYS[2], ARGV[3], ARGV[1])
+return 1`)
+	// remove claimed message, the payload, and the attempt, then push dead letter entry to dead letter list
+	deadLetterDelayedQueueScript = redis.NewScript(4, fenceDelayedQueueScript+`redis.call("ZREM", KEYS[1], ARGV[1])
+redis.call("HDEL", KEYS[2], ARGV[1])
+redis.call("HDEL", KEYS[3], ARGV[1])
+redis.call("LPUSH", KEYS[4], ARGV[3])
+return 1`)
+	// set new visibility deadline of claimed message
+	extendDelayedQueueScript = redis.NewScript(1, fenceDelayedQueueScript+`redis.call("ZADD", KEYS[1], ARGV[3], ARGV[1])
+return 1`)
+)
+
+// DelayedMessage message from redis delayed queue
+type DelayedMessage struct {
+	ID          string
+	HandlerName string
+	// Message is nil if payload already deleted
+	Message []byte
+	// Attempt total claim of message, including this claim
+	Attempt int
+
+	// deadline visibility deadline of this claim, used as claim token
+	deadline int64
+}
+
+// DelayedDeadLetter entry of dead letter list, message which cannot be processed (handler not found or exceed max retry)
+type DelayedDeadLetter struct {
+	ID          string    `json:"id"`
+	HandlerName string    `json:"h"`
+	Message     string    `json:"message"`
+	Attempt     int       `json:"attempt"`
+	Error       string    `json:"error"`
+	FailedAt    time.Time `json:"failedAt"`
+}
+
+// RedisDelayedQueue reliable delayed queue with at-least-once delivery, using redis sorted set scored by due time.
+// Payload is stored in separate hash, claimed message must be acknowledged (or retried) or will be recovered after visibility timeout
+type RedisDelayedQueue struct {
+	pool              *redis.Pool
+	delayedKey        string
+	processingKey     string
+	payloadKey        string
+	attemptKey        string
+	deadLetterKey     string
+	visibilityTimeout time.Duration
+}
+
+// NewRedisDelayedQueue constructor, all keys use hash tag from key so that can be used in redis cluster
+func NewRedisDelayedQueue(pool *redis.Pool, key string, visibilityTimeout time.Duration) *RedisDelayedQueue {
+	if visibilityTimeout <= 0 {
+		visibilityTimeout = 5 * time.Minute
+	}
+	return &RedisDelayedQueue{
+		pool:              pool,
+		delayedKey:        fmt.Sprintf("{%s}:delayed", key),
+		processingKey:     fmt.Sprintf("{%s}:processing", key),
+		payloadKey:        fmt.Sprintf("{%s}:payload", key),
+		attemptKey:        fmt.Sprintf("{%s}:attempt", key),
+		deadLetterKey:     fmt.Sprintf("{%s}:dlq", key),
+		visibilityTimeout: visibilityTimeout,
+	}
+}
+
+// Push add message to queue, message will be ready to claim after delay
+func (q *RedisDelayedQueue) Push(ctx context.Context, handlerName string, message []byte, delay time.Duration) (id string, err error) {
+	id = uuid.New().String()
+	payload, _ := json.Marshal(candihelper.RedisMessage{HandlerName: handlerName, Message: string(message)})
+
+	conn := q.pool.Get()
+	defer conn.Close()
+
+	conn.Send("MULTI")
+	conn.Send("HSET", q.payloadKey, id, payload)
+	conn.Send("ZADD", q.delayedKey, toMillis(time.Now().Add(delay)), id)
+	_, err = conn.Do("EXEC")
+	return id, err
+}
+
+// Claim get due messages (maximum limit), claimed message is invisible for another consumer until visibility timeout
+func (q *RedisDelayedQueue) Claim(ctx context.Context, limit int) ([]DelayedMessage, error) {
+	conn := q.pool.Get()
+	defer conn.Close()
+
+	now := time.Now()
+	deadline := toMillis(now.Add(q.visibilityTimeout))
+	values, err := redis.Values(claimDelayedQueueScript.Do(conn, q.delayedKey, q.processingKey, q.payloadKey, q.attemptKey,
+		toMillis(now), limit, deadline))
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]DelayedMessage, 0, len(values)/3)
+	for i := 0; i+2 < len(values); i += 3 {
+		message := DelayedMessage{deadline: deadline}
+		message.ID, _ = redis.String(values[i], nil)
+		if payload, err := redis.Bytes(values[i+1], nil); err == nil {
+			var redisMessage candihelper.RedisMessage
+			json.Unmarshal(payload, &redisMessage)
+			message.HandlerName, message.Message = redisMessage.HandlerName, []byte(redisMessage.Message)
+		}
+		message.Attempt, _ = redis.Int(values[i+2], nil)
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// Ack remove claimed message and the payload from queue,
+// return ErrDelayedMessageReclaimed (payload is not removed) if visibility timeout is exceeded
+func (q *RedisDelayedQueue) Ack(ctx context.Context, message *DelayedMessage) error {
+	conn := q.pool.Get()
+	defer conn.Close()
+
+	return fencedResult(ackDelayedQueueScript.Do(conn, q.processingKey, q.payloadKey, q.attemptKey, message.ID, message.deadline))
+}
+
+// Retry move claimed message back to queue, message will be ready to claim again after delay,
+// return ErrDelayedMessageReclaimed if visibility timeout is exceeded
+func (q *RedisDelayedQueue) Retry(ctx context.Context, message *DelayedMessage, delay time.Duration) error {
+	conn := q.pool.Get()
+	defer conn.Close()
+
+	return fencedResult(retryDelayedQueueScript.Do(conn, q.processingKey, q.delayedKey,
+		message.ID, message.deadline, toMillis(time.Now().Add(delay))))
+}
+
+// DeadLetter remove claimed message from queue and push it to dead letter list (DeadLetterKey) with the reason,
+// return ErrDelayedMessageReclaimed if visibility timeout is exceeded
+func (q *RedisDelayedQueue) DeadLetter(ctx context.Context, message *DelayedMessage, reason error) error {
+	entry, _ := json.Marshal(DelayedDeadLetter{
+		ID: message.ID, HandlerName: message.HandlerName, Message: string(message.Message), Attempt: message.Attempt,
+		Error: reason.Error(), FailedAt: time.Now(),
+	})
+
+	conn := q.pool.Get()
+	defer conn.Close()
+
+	return fencedResult(deadLetterDelayedQueueScript.Do(conn, q.processingKey, q.payloadKey, q.attemptKey, q.deadLetterKey,
+		message.ID, message.deadline, entry))
+}
+
+// DeadLetterKey get key of dead letter list, entry is json of DelayedDeadLetter (newest first)
+func (q *RedisDelayedQueue) DeadLetterKey() string {
+	return q.deadLetterKey
+}
+
+// Extend reset visibility deadline of claimed message, used for long running handler so that message is not recovered while processed.
+// Return ErrDelayedMessageReclaimed if visibility timeout is already exceeded
+func (q *RedisDelayedQueue) Extend(ctx context.Context, message *DelayedMessage) error {
+	conn := q.pool.Get()
+	defer conn.Close()
+
+	deadline := toMillis(time.Now().Add(q.visibilityTimeout))
+	if err := fencedResult(extendDelayedQueueScript.Do(conn, q.processingKey, message.ID, message.deadline, deadline)); err != nil {
+		return err
+	}
+	message.deadline = deadline
+	return nil
+}
+
+// Recover move claimed messages which not acknowledged until visibility timeout back to queue (maximum limit),
+// return total recovered messages
+func (q *RedisDelayedQueue) Recover(ctx context.Context, limit int) (int, error) {
+	conn := q.pool.Get()
+	defer conn.Close()
+
+	return redis.Int(recoverDelayedQueueScript.Do(conn, q.processingKey, q.delayedKey, toMillis(time.Now()), limit))
+}
+
+// VisibilityTimeout get visibility timeout of claimed message
+func (q *RedisDelayedQueue) VisibilityTimeout() time.Duration {
+	return q.visibilityTimeout
+}
+
+// fencedResult convert reply of fenced script, 0 if message is not claimed by caller
+func fencedResult(reply interface{}, err error) error {
+	ok, err := redis.Int(reply, err)
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return ErrDelayedMessageReclaimed
+	}
+	return nil
+}
+
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
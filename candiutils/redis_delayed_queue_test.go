@@ -0,0 +1,91 @@
+package candiutils
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	mocks "github.com/golangid/candi/mocks/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+// scriptCall keys and argv of EVALSHA command
+type scriptCall struct {
+	keys []interface{}
+	argv []interface{}
+}
+
+func recordScript(mu *sync.Mutex, calls *[]scriptCall, reply interface{}) func(cmd string, args ...interface{}) (interface{}, error) {
+	return func(cmd string, args ...interface{}) (interface{}, error) {
+		if cmd != "EVALSHA" {
+			return nil, nil
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		keyCount := args[1].(int)
+		*calls = append(*calls, scriptCall{keys: args[2 : 2+keyCount], argv: args[2+keyCount:]})
+		return reply, nil
+	}
+}
+
+func TestRedisDelayedQueueClaim(t *testing.T) {
+	var mu sync.Mutex
+	var calls []scriptCall
+	conn := mocks.NewConn(recordScript(&mu, &calls, []interface{}{
+		[]byte("id-1"), []byte(`{"h":"push-notif","message":"hello"}`), int64(2),
+		[]byte("id-2"), nil, int64(1),
+	}))
+	queue := NewRedisDelayedQueue(conn.Pool(), "queue", time.Minute)
+
+	messages, err := queue.Claim(context.Background(), 10)
+	assert.NoError(t, err)
+	assert.Len(t, messages, 2)
+	assert.Equal(t, "id-1", messages[0].ID)
+	assert.Equal(t, "push-notif", messages[0].HandlerName)
+	assert.Equal(t, []byte("hello"), messages[0].Message)
+	assert.Equal(t, 2, messages[0].Attempt)
+	assert.Nil(t, messages[1].Message, "payload already deleted")
+	assert.Equal(t, 1, messages[1].Attempt)
+
+	assert.Equal(t, []interface{}{"{queue}:delayed", "{queue}:processing", "{queue}:payload", "{queue}:attempt"}, calls[0].keys)
+	deadline := calls[0].argv[2].(int64)
+	assert.Equal(t, deadline, messages[0].deadline, "visibility deadline is claim token")
+	assert.InDelta(t, toMillis(time.Now().Add(time.Minute)), deadline, float64(time.Second.Milliseconds()))
+}
+
+func TestRedisDelayedQueueFencing(t *testing.T) {
+	var mu sync.Mutex
+	var calls []scriptCall
+	conn := mocks.NewConn(recordScript(&mu, &calls, int64(1)))
+	queue := NewRedisDelayedQueue(conn.Pool(), "queue", time.Minute)
+	message := &DelayedMessage{ID: "id-1", deadline: 1000}
+
+	assert.NoError(t, queue.Ack(context.Background(), message))
+	assert.Equal(t, []interface{}{"{queue}:processing", "{queue}:payload", "{queue}:attempt"}, calls[0].keys)
+	assert.Equal(t, []interface{}{"id-1", int64(1000)}, calls[0].argv)
+
+	assert.NoError(t, queue.Retry(context.Background(), message, time.Second))
+	assert.Equal(t, []interface{}{"{queue}:processing", "{queue}:delayed"}, calls[1].keys)
+	assert.Equal(t, []interface{}{"id-1", int64(1000)}, calls[1].argv[:2])
+
+	assert.NoError(t, queue.Extend(context.Background(), message))
+	assert.Equal(t, []interface{}{"{queue}:processing"}, calls[2].keys)
+	assert.Equal(t, int64(1000), calls[2].argv[1], "fenced with previous deadline")
+	assert.Equal(t, calls[2].argv[2], message.deadline, "new deadline is used for next call")
+
+	assert.NoError(t, queue.DeadLetter(context.Background(), message, errors.New("failed")))
+	assert.Equal(t, []interface{}{"{queue}:processing", "{queue}:payload", "{queue}:attempt", "{queue}:dlq"}, calls[3].keys)
+	assert.Equal(t, message.deadline, calls[3].argv[1])
+	assert.Equal(t, "{queue}:dlq", queue.DeadLetterKey())
+
+	// message is recovered and claimed again, previous claim cannot ack or extend
+	conn.SetReply(recordScript(&mu, &calls, int64(0)))
+	deadline := message.deadline
+	assert.Equal(t, ErrDelayedMessageReclaimed, queue.Ack(context.Background(), message))
+	assert.Equal(t, ErrDelayedMessageReclaimed, queue.Retry(context.Background(), message, time.Second))
+	assert.Equal(t, ErrDelayedMessageReclaimed, queue.Extend(context.Background(), message))
+	assert.Equal(t, ErrDelayedMessageReclaimed, queue.DeadLetter(context.Background(), message, errors.New("failed")))
+	assert.Equal(t, deadline, message.deadline)
+}
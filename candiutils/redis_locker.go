@@ -0,0 +1,171 @@
+package candiutils
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/golangid/candi/logger"
+	"github.com/gomodule/redigo/redis"
+	"github.com/google/uuid"
+)
+
+var (
+	renewRedisLockScript = redis.NewScript(1, `if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+	releaseRedisLockScript = redis.NewScript(1, `if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+)
+
+// RedisLocker distributed lock using redis key (SET NX PX), lock is renewed periodically until session destroyed
+type RedisLocker struct {
+	Pool              *redis.Pool
+	Key               string
+	LockRetryInterval time.Duration
+	TTL               time.Duration
+
+	mu    sync.Mutex
+	value string
+	done  chan struct{}
+}
+
+// RedisLockerConfig is used to configure creation of redis locker
+type RedisLockerConfig struct {
+	Pool              *redis.Pool
+	Key               string
+	LockRetryInterval time.Duration
+	TTL               time.Duration
+}
+
+// NewRedisLocker constructor
+func NewRedisLocker(opt *RedisLockerConfig) *RedisLocker {
+	r := &RedisLocker{
+		Pool:              opt.Pool,
+		Key:               opt.Key,
+		LockRetryInterval: 30 * time.Second,
+		TTL:               15 * time.Second,
+	}
+	if opt.LockRetryInterval != 0 {
+		r.LockRetryInterval = opt.LockRetryInterval
+	}
+	if opt.TTL != 0 {
+		r.TTL = opt.TTL
+	}
+	return r
+}
+
+// RetryLockAcquire attempts to acquire the lock at `LockRetryInterval`
+func (r *RedisLocker) RetryLockAcquire(value map[string]string, acquired chan<- struct{}, released chan<- struct{}) {
+	ticker := time.NewTicker(r.LockRetryInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		value["lockAcquisitionTime"] = time.Now().Format(time.RFC3339)
+		lock, err := r.acquireLock(value, released)
+		if err != nil {
+			logger.LogYellow("Cannot acquire redis lock, " + err.Error())
+			continue
+		}
+		if lock {
+			break
+		}
+	}
+
+	acquired <- struct{}{}
+}
+
+// DestroySession method, release lock if still owned by this instance
+func (r *RedisLocker) DestroySession() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.done == nil {
+		return nil
+	}
+	close(r.done)
+	r.done = nil
+
+	conn := r.Pool.Get()
+	defer conn.Close()
+	_, err := releaseRedisLockScript.Do(conn, r.Key, r.value)
+	return err
+}
+
+func (r *RedisLocker) acquireLock(value map[string]string, released chan<- struct{}) (bool, error) {
+	// unique lock value, so that only owner can renew and release the lock
+	value["lockID"] = uuid.New().String()
+	b, _ := json.Marshal(value)
+
+	conn := r.Pool.Get()
+	defer conn.Close()
+
+	_, err := redis.String(conn.Do("SET", r.Key, b, "NX", "PX", r.TTL.Milliseconds()))
+	if err == redis.ErrNil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	doneCh := make(chan struct{})
+	r.mu.Lock()
+	r.value, r.done = string(b), doneCh
+	r.mu.Unlock()
+
+	go func() {
+		if r.renewPeriodic(string(b), doneCh) {
+			// lock is lost, signal holder immediately so that stop work which require the lock
+			released <- struct{}{}
+			return
+		}
+		time.Sleep(r.LockRetryInterval)
+		released <- struct{}{}
+	}()
+	return true, nil
+}
+
+// renewPeriodic extend lock ttl until session destroyed or lock is lost, return true if lock is lost.
+// Lock is lost when owned by another instance, or renewal keep failing until lock may expire before next renewal
+func (r *RedisLocker) renewPeriodic(value string, doneCh <-chan struct{}) (lost bool) {
+	interval := r.TTL / 3
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastRenewed := time.Now()
+	for {
+		select {
+		case <-doneCh:
+			return false
+
+		case <-ticker.C:
+			conn := r.Pool.Get()
+			renewed, err := redis.Int(renewRedisLockScript.Do(conn, r.Key, value, r.TTL.Milliseconds()))
+			conn.Close()
+			reason := "owned by another instance"
+			if err != nil {
+				if time.Since(lastRenewed)+interval < r.TTL {
+					logger.LogYellow("Cannot renew redis lock, " + err.Error())
+					continue
+				}
+				reason = "cannot renew before expired, " + err.Error()
+			} else if renewed != 0 {
+				lastRenewed = time.Now()
+				continue
+			}
+
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if r.done != doneCh {
+				// session is destroyed while renewing
+				return false
+			}
+			logger.LogRed("Redis lock " + r.Key + " is lost, " + reason)
+			r.done = nil
+			return true
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package candiutils
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	mocks "github.com/golangid/candi/mocks/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRedisLocker(conn *mocks.Conn) *RedisLocker {
+	return NewRedisLocker(&RedisLockerConfig{
+		Pool: conn.Pool(), Key: "lock", LockRetryInterval: 10 * time.Millisecond, TTL: 30 * time.Millisecond,
+	})
+}
+
+func TestRedisLockerAcquire(t *testing.T) {
+	conn := mocks.NewConn(func(cmd string, args ...interface{}) (interface{}, error) { return nil, nil })
+	locker := newTestRedisLocker(conn)
+	lock, err := locker.acquireLock(map[string]string{}, make(chan struct{}, 1))
+	assert.NoError(t, err)
+	assert.False(t, lock, "lock is owned by another instance")
+
+	conn.SetReply(func(cmd string, args ...interface{}) (interface{}, error) {
+		if cmd == "SET" {
+			return "OK", nil
+		}
+		return int64(1), nil
+	})
+	lock, err = locker.acquireLock(map[string]string{}, make(chan struct{}, 1))
+	assert.NoError(t, err)
+	assert.True(t, lock)
+	assert.NoError(t, locker.DestroySession())
+}
+
+func TestRedisLockerLost(t *testing.T) {
+	tests := []struct {
+		name  string
+		renew func() (interface{}, error)
+	}{
+		{name: "renew failed until expired", renew: func() (interface{}, error) { return nil, errors.New("connection refused") }},
+		{name: "owned by another instance", renew: func() (interface{}, error) { return int64(0), nil }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := mocks.NewConn(func(cmd string, args ...interface{}) (interface{}, error) {
+				if cmd == "SET" {
+					return "OK", nil
+				}
+				return tt.renew()
+			})
+			locker := newTestRedisLocker(conn)
+			released := make(chan struct{}, 1)
+			lock, err := locker.acquireLock(map[string]string{}, released)
+			assert.NoError(t, err)
+			assert.True(t, lock)
+
+			select {
+			case <-released:
+			case <-time.After(time.Second):
+				t.Fatal("holder is not signaled when lock is lost")
+			}
+			locker.mu.Lock()
+			assert.Nil(t, locker.done)
+			locker.mu.Unlock()
+		})
+	}
+}
+
+func TestRedisLockerRenewRetry(t *testing.T) {
+	var mu sync.Mutex
+	var renewCount int
+	conn := mocks.NewConn(func(cmd string, args ...interface{}) (interface{}, error) {
+		if cmd == "SET" {
+			return "OK", nil
+		}
+		if len(args) != 5 { // release script (sha, 1, key, value)
+			return int64(1), nil
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		// single renew failure is retried before lock is expired
+		if renewCount++; renewCount%2 == 1 {
+			return nil, errors.New("timeout")
+		}
+		return int64(1), nil
+	})
+	locker := newTestRedisLocker(conn)
+	released := make(chan struct{}, 1)
+	lock, err := locker.acquireLock(map[string]string{}, released)
+	assert.NoError(t, err)
+	assert.True(t, lock)
+
+	select {
+	case <-released:
+		t.Fatal("lock is lost after single renew failure")
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.NoError(t, locker.DestroySession())
+	select {
+	case <-released:
+	case <-time.After(time.Second):
+		t.Fatal("holder is not signaled when session destroyed")
+	}
+	assert.Contains(t, conn.Names(), "EVALSHA")
+}
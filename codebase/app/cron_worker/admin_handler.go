@@ -0,0 +1,132 @@
+package cronworker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/golangid/candi/config/env"
+	"github.com/golangid/candi/wrapper"
+)
+
+/*
+NewAdminHandler http handler for manage cron jobs in runtime, job identified by job name:
+
+* GET /jobs, list all jobs with next and last activation time
+
+* GET /jobs/{jobName}, get job detail
+
+* GET /jobs/{jobName}/histories?limit=20, get latest run histories of job (history store must be configured)
+
+* POST /jobs/{jobName}/trigger, execute job immediately
+
+* POST /jobs/{jobName}/pause, stop activation of job
+
+* POST /jobs/{jobName}/resume, continue activation of paused job
+
+* PUT /jobs/{jobName}/schedule, change job interval with request body {"interval": "0 2 * * *"}
+
+Mount with http.StripPrefix if served under sub path
+*/
+func NewAdminHandler() http.Handler {
+	return http.HandlerFunc(serveAdmin)
+}
+
+func serveAdmin(w http.ResponseWriter, req *http.Request) {
+	paths := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	if paths[0] != "jobs" {
+		wrapper.NewHTTPResponse(http.StatusNotFound, "Resource not found").JSON(w)
+		return
+	}
+
+	switch {
+	case len(paths) == 1 && req.Method == http.MethodGet:
+		wrapper.NewHTTPResponse(http.StatusOK, "Success get all jobs", GetJobStatuses()).JSON(w)
+
+	case len(paths) == 2 && req.Method == http.MethodGet:
+		status, err := GetJobStatus(paths[1])
+		if err != nil {
+			adminErrorResponse(w, err)
+			return
+		}
+		wrapper.NewHTTPResponse(http.StatusOK, "Success get job", status).JSON(w)
+
+	case len(paths) == 3 && req.Method == http.MethodGet && paths[2] == "histories":
+		limit, _ := strconv.Atoi(req.URL.Query().Get("limit"))
+		if limit <= 0 {
+			limit = 20
+		}
+		histories, err := GetJobHistories(req.Context(), paths[1], limit)
+		if err != nil {
+			adminErrorResponse(w, err)
+			return
+		}
+		wrapper.NewHTTPResponse(http.StatusOK, "Success get job histories", histories).JSON(w)
+
+	case len(paths) == 3 && req.Method == http.MethodPost && paths[2] == "trigger":
+		adminActionResponse(w, paths[1], "trigger", TriggerJob(paths[1]))
+
+	case len(paths) == 3 && req.Method == http.MethodPost && paths[2] == "pause":
+		adminActionResponse(w, paths[1], "pause", PauseJob(paths[1]))
+
+	case len(paths) == 3 && req.Method == http.MethodPost && paths[2] == "resume":
+		adminActionResponse(w, paths[1], "resume", ResumeJob(paths[1]))
+
+	case len(paths) == 3 && req.Method == http.MethodPut && paths[2] == "schedule":
+		var payload struct {
+			Interval string `json:"interval"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil || payload.Interval == "" {
+			wrapper.NewHTTPResponse(http.StatusBadRequest, "Invalid request body, interval is required").JSON(w)
+			return
+		}
+		adminActionResponse(w, paths[1], "reschedule", RescheduleJob(paths[1], payload.Interval))
+
+	default:
+		wrapper.NewHTTPResponse(http.StatusNotFound, "Resource not found").JSON(w)
+	}
+}
+
+func adminActionResponse(w http.ResponseWriter, jobName, action string, err error) {
+	if err != nil {
+		adminErrorResponse(w, err)
+		return
+	}
+	status, _ := GetJobStatus(jobName)
+	wrapper.NewHTTPResponse(http.StatusOK, fmt.Sprintf("Success %s job", action), status).JSON(w)
+}
+
+func adminErrorResponse(w http.ResponseWriter, err error) {
+	code := http.StatusBadRequest
+	switch {
+	case errors.Is(err, ErrJobNotFound), errors.Is(err, ErrHistoryStoreNotSet):
+		code = http.StatusNotFound
+	case errors.Is(err, ErrWorkerInactive):
+		code = http.StatusConflict
+	}
+	wrapper.NewHTTPResponse(code, err.Error(), err).JSON(w)
+}
+
+func (c *cronWorker) serveAdminAPI() {
+	if err := c.adminServer.ListenAndServe(); err != nil {
+		switch e := err.(type) {
+		case *net.OpError:
+			panic(fmt.Errorf("cron worker admin api: %v", e))
+		}
+	}
+}
+
+// newAdminServer admin api server in separate port, auth middleware is required because admin api can trigger and change jobs
+func newAdminServer(authMiddleware func(http.Handler) http.Handler) (*http.Server, error) {
+	if authMiddleware == nil {
+		return nil, errors.New("admin api requires auth middleware, set middleware in dependency or unset CRON_SCHEDULER_ADMIN_PORT environment")
+	}
+	return &http.Server{
+		Addr:    fmt.Sprintf(":%d", env.BaseEnv().CronSchedulerAdminPort),
+		Handler: authMiddleware(NewAdminHandler()),
+	}, nil
+}
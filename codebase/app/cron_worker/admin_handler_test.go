@@ -0,0 +1,81 @@
+package cronworker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func adminRequest(t *testing.T, method, path, body string) (int, map[string]interface{}) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	NewAdminHandler().ServeHTTP(rec, req)
+
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	return rec.Code, response
+}
+
+func TestAdminHandler(t *testing.T) {
+	activeJobs, workers, historyStore, isWorkerActive = nil, nil, nil, false
+	triggerJobCh = make(chan *Job, 1)
+	defer func() { activeJobs, workers = nil, nil }()
+	assert.NoError(t, AddJob(Job{
+		HandlerName: "daily-report", Interval: "0 2 * * *", Timezone: "UTC",
+		HandlerFunc: func(context.Context, []byte) error { return nil },
+	}))
+
+	code, response := adminRequest(t, http.MethodGet, "/jobs", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, response["data"], 1)
+
+	code, _ = adminRequest(t, http.MethodGet, "/jobs/unknown", "")
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = adminRequest(t, http.MethodGet, "/jobs/daily-report/histories", "")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, response = adminRequest(t, http.MethodPost, "/jobs/daily-report/pause", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, true, response["data"].(map[string]interface{})["paused"])
+	assert.Nil(t, response["data"].(map[string]interface{})["next_run_at"])
+
+	code, response = adminRequest(t, http.MethodPost, "/jobs/daily-report/resume", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, false, response["data"].(map[string]interface{})["paused"])
+
+	// trigger is rejected when worker is not active in this instance
+	code, _ = adminRequest(t, http.MethodPost, "/jobs/daily-report/trigger", "")
+	assert.Equal(t, http.StatusConflict, code)
+	isWorkerActive = true
+	code, _ = adminRequest(t, http.MethodPost, "/jobs/daily-report/trigger", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "daily-report", (<-triggerJobCh).HandlerName)
+
+	code, response = adminRequest(t, http.MethodPut, "/jobs/daily-report/schedule", `{"interval": "0 3 * * *"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "0 3 * * *", response["data"].(map[string]interface{})["interval"])
+	code, _ = adminRequest(t, http.MethodPut, "/jobs/daily-report/schedule", `{"interval": "invalid"}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = adminRequest(t, http.MethodPut, "/jobs/daily-report/schedule", `{}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestNewAdminServer(t *testing.T) {
+	_, err := newAdminServer(nil)
+	assert.Error(t, err)
+
+	server, err := newAdminServer(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		})
+	})
+	assert.NoError(t, err)
+	rec := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
@@ -0,0 +1,197 @@
+package cronworker
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/golangid/candi/candihelper"
+	"github.com/golangid/candi/logger"
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	defaultCatchUpLookback = 24 * time.Hour
+	maxCatchUpExecutions   = 100
+)
+
+// FireTimeStore abstraction for persist activation time of last success execution of job, used for catch up missed execution
+type FireTimeStore interface {
+	// GetLastFireTime return zero time if job never executed
+	GetLastFireTime(ctx context.Context, jobName string) (time.Time, error)
+	SetLastFireTime(ctx context.Context, jobName string, fireTime time.Time) error
+}
+
+// redisFireTimeStore store last fire time in redis hash, shared between multiple instance
+type redisFireTimeStore struct {
+	pool *redis.Pool
+	key  string
+}
+
+// NewRedisFireTimeStore constructor, last fire time of all jobs stored in hash key
+func NewRedisFireTimeStore(pool *redis.Pool, key string) FireTimeStore {
+	return &redisFireTimeStore{pool: pool, key: key}
+}
+
+func (s *redisFireTimeStore) GetLastFireTime(ctx context.Context, jobName string) (time.Time, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	value, err := redis.String(conn.Do("HGET", s.key, jobName))
+	if err == redis.ErrNil {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+func (s *redisFireTimeStore) SetLastFireTime(ctx context.Context, jobName string, fireTime time.Time) error {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("HSET", s.key, jobName, fireTime.UTC().Format(time.RFC3339Nano))
+	return err
+}
+
+// postgresFireTimeStore store last fire time in postgres table, table created if not exist
+type postgresFireTimeStore struct {
+	db        *sql.DB
+	tableName string
+}
+
+// NewPostgresFireTimeStore constructor, create table for store last fire time if not exist
+func NewPostgresFireTimeStore(db *sql.DB, tableName string) (FireTimeStore, error) {
+	_, err := db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		job_name VARCHAR(255) PRIMARY KEY,
+		fire_time TIMESTAMPTZ NOT NULL
+	)`, tableName))
+	if err != nil {
+		return nil, fmt.Errorf("failed when create table %s: %v", tableName, err)
+	}
+	return &postgresFireTimeStore{db: db, tableName: tableName}, nil
+}
+
+func (s *postgresFireTimeStore) GetLastFireTime(ctx context.Context, jobName string) (fireTime time.Time, err error) {
+	err = s.db.QueryRowContext(ctx, fmt.Sprintf(`SELECT fire_time FROM %s WHERE job_name=$1`, s.tableName), jobName).
+		Scan(&fireTime)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	return
+}
+
+func (s *postgresFireTimeStore) SetLastFireTime(ctx context.Context, jobName string, fireTime time.Time) error {
+	_, err := s.db.ExecContext(ctx, fmt.Sprintf(`INSERT INTO %s (job_name, fire_time) VALUES ($1, $2)
+		ON CONFLICT (job_name) DO UPDATE SET fire_time=EXCLUDED.fire_time`, s.tableName), jobName, fireTime)
+	return err
+}
+
+// missedActivations get activation time of job in range (from, to), limited to maximum catch up executions
+func (job *Job) missedActivations(from, to time.Time) (activations []time.Time) {
+	from, to = from.In(job.location), to.In(job.location)
+	next := func(t time.Time) time.Time { return t.Add(job.currentDuration) }
+	if job.schedule != nil {
+		next = job.schedule.Next
+	}
+
+	for t := next(from); !t.IsZero() && t.Before(to); t = next(t) {
+		activations = append(activations, t)
+		if len(activations) > maxCatchUpExecutions {
+			activations = activations[1:]
+		}
+	}
+	return
+}
+
+// missedExecution missed activations of job since last success fire time
+type missedExecution struct {
+	job          *Job
+	lastFireTime time.Time
+	activations  []time.Time
+}
+
+// loadMissedExecutions get missed activations of jobs with catch up mode, since last success activation time (limited by lookback).
+// Must be called before jobs are started, so that last fire time is not overwritten by regular activation before read
+func (c *cronWorker) loadMissedExecutions() (missed []missedExecution) {
+	if fireTimeStore == nil {
+		return nil
+	}
+
+	now := time.Now()
+	for _, job := range activeJobs {
+		if c.ctx.Err() != nil {
+			return nil
+		}
+		if job.CatchUpMode == "" {
+			continue
+		}
+
+		lastFireTime, err := fireTimeStore.GetLastFireTime(c.ctx, job.HandlerName)
+		if err != nil {
+			logger.LogRed(fmt.Sprintf("cron_scheduler > failed get last fire time of job %s: %v", job.HandlerName, err))
+			continue
+		}
+		if lastFireTime.IsZero() {
+			continue
+		}
+		if lookback := now.Add(-job.CatchUpLookback); lastFireTime.Before(lookback) {
+			lastFireTime = lookback
+		}
+
+		activations := job.missedActivations(lastFireTime, now)
+		if len(activations) == 0 {
+			continue
+		}
+		if job.CatchUpMode == candihelper.CronCatchUpLatest {
+			activations = activations[len(activations)-1:]
+		}
+		missed = append(missed, missedExecution{job: job, lastFireTime: lastFireTime, activations: activations})
+	}
+	return missed
+}
+
+// catchUpMissedJobs run missed executions loaded before jobs are started.
+// Run in background when scheduler started, stopped when worker is shutdown
+func (c *cronWorker) catchUpMissedJobs(missed []missedExecution) {
+	for _, m := range missed {
+		if c.ctx.Err() != nil {
+			return
+		}
+		logger.LogYellow(fmt.Sprintf("[CRON-WORKER] catch up %d missed execution of job \"%s\" since %s",
+			len(m.activations), m.job.HandlerName, m.lastFireTime.In(m.job.location).Format(time.RFC3339)))
+		c.runCatchUpJob(m.job, m.activations)
+	}
+}
+
+// runCatchUpJob run missed executions sequentially in one goroutine, follow overlap policy of job
+func (c *cronWorker) runCatchUpJob(job *Job, activations []time.Time) {
+	if job.startRun(activations[0]) != runStart {
+		return
+	}
+
+	ctx := c.runContext()
+	select {
+	case semaphore <- struct{}{}:
+	case <-ctx.Done():
+		job.cancelRun()
+		return
+	}
+	c.wg.Add(1)
+	go func() {
+		defer func() {
+			c.wg.Done()
+			<-semaphore
+		}()
+
+		for _, fireTime := range activations {
+			if ctx.Err() != nil {
+				break
+			}
+			c.processJob(ctx, job, fireTime)
+		}
+		c.runQueuedJob(ctx, job)
+	}()
+}
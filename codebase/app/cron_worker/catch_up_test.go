@@ -0,0 +1,141 @@
+package cronworker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golangid/candi/candihelper"
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryFireTimeStore struct {
+	mu        sync.Mutex
+	fireTimes map[string]time.Time
+}
+
+func (s *memoryFireTimeStore) GetLastFireTime(ctx context.Context, jobName string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fireTimes[jobName], nil
+}
+
+func (s *memoryFireTimeStore) SetLastFireTime(ctx context.Context, jobName string, fireTime time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fireTimes[jobName] = fireTime
+	return nil
+}
+
+func TestJobMissedActivations(t *testing.T) {
+	from := time.Date(2021, time.May, 14, 2, 0, 0, 0, time.UTC)
+	to := time.Date(2021, time.May, 17, 1, 0, 0, 0, time.UTC)
+
+	sched, _ := parseCronExpression("0 2 * * *")
+	job := &Job{location: time.UTC, schedule: sched}
+	assert.Equal(t, []time.Time{
+		time.Date(2021, time.May, 15, 2, 0, 0, 0, time.UTC),
+		time.Date(2021, time.May, 16, 2, 0, 0, 0, time.UTC),
+	}, job.missedActivations(from, to))
+
+	job = &Job{location: time.UTC, currentDuration: 10 * time.Minute}
+	activations := job.missedActivations(from, to)
+	assert.Len(t, activations, maxCatchUpExecutions)
+	assert.Equal(t, time.Date(2021, time.May, 17, 0, 50, 0, 0, time.UTC), activations[len(activations)-1])
+
+	assert.Empty(t, job.missedActivations(to, to))
+}
+
+func TestSaveFireTimeOnlySuccessExecution(t *testing.T) {
+	store := &memoryFireTimeStore{fireTimes: map[string]time.Time{}}
+	fireTimeStore = store
+	defer func() { fireTimeStore = nil }()
+
+	fireTime := time.Date(2021, time.May, 14, 2, 0, 0, 0, time.UTC)
+	c := &cronWorker{ctx: context.Background()}
+	job := &Job{
+		HandlerName: "report", CatchUpMode: candihelper.CronCatchUpLatest, location: time.UTC,
+		HandlerFunc: func(context.Context, []byte) error { return errors.New("failed") },
+	}
+	c.processJob(c.ctx, job, fireTime)
+	assert.NotContains(t, store.fireTimes, "report", "failed execution is caught up again after restart")
+
+	job.HandlerFunc = func(context.Context, []byte) error { return nil }
+	c.processJob(c.ctx, job, fireTime)
+	assert.Equal(t, fireTime, store.fireTimes["report"])
+}
+
+func TestLoadMissedExecutions(t *testing.T) {
+	lastFireTime := time.Now().Add(-35 * time.Minute)
+	store := &memoryFireTimeStore{fireTimes: map[string]time.Time{"report": lastFireTime}}
+	fireTimeStore = store
+	defer func() { fireTimeStore = nil }()
+	defer func(jobs []*Job) { activeJobs = jobs }(activeJobs)
+
+	job := &Job{
+		HandlerName: "report", CatchUpMode: candihelper.CronCatchUpAll, CatchUpLookback: time.Hour,
+		location: time.UTC, currentDuration: 10 * time.Minute,
+	}
+	activeJobs = []*Job{job, {HandlerName: "sync", location: time.UTC, currentDuration: time.Minute}}
+
+	missed := (&cronWorker{ctx: context.Background()}).loadMissedExecutions()
+	assert.Len(t, missed, 1)
+	assert.Equal(t, job, missed[0].job)
+	assert.Len(t, missed[0].activations, 3)
+}
+
+func TestRunCatchUpJobCanceled(t *testing.T) {
+	if semaphore == nil {
+		semaphore = make(chan struct{}, 1)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &cronWorker{ctx: ctx}
+	job := &Job{HandlerName: "report", location: time.UTC}
+
+	// catch up is waiting semaphore, stopped without leak running state when worker is shutdown
+	for len(semaphore) < cap(semaphore) {
+		semaphore <- struct{}{}
+	}
+	defer func() {
+		for len(semaphore) > 0 {
+			<-semaphore
+		}
+	}()
+	done := make(chan struct{})
+	go func() {
+		c.runCatchUpJob(job, []time.Time{time.Now()})
+		close(done)
+	}()
+	cancel()
+	<-done
+	assert.Equal(t, 0, job.running)
+}
+
+func TestJobTickFireTime(t *testing.T) {
+	tickAt := time.Date(2021, time.May, 14, 2, 0, 3, 0, time.UTC)
+	job := &Job{location: time.UTC, currentDuration: time.Minute, nextRunAt: tickAt.Add(-time.Second)}
+	fireTime, ok := job.tick(tickAt)
+	assert.True(t, ok)
+	assert.Equal(t, tickAt, fireTime)
+
+	assert.Equal(t, tickAt.Add(time.Minute), job.nextRunAt)
+
+	job.paused = true
+	_, ok = job.tick(tickAt)
+	assert.False(t, ok)
+}
+
+func TestJobTickEarly(t *testing.T) {
+	sched, _ := parseCronExpression("* * * * *")
+	slot := time.Now().UTC().Truncate(time.Minute).Add(time.Minute)
+	job := &Job{location: time.UTC, schedule: sched, nextRunAt: slot, ticker: time.NewTicker(time.Hour)}
+	defer job.ticker.Stop()
+
+	// ticker fired slightly before scheduled time, next activation must be the next slot
+	fireTime, ok := job.tick(slot.Add(-time.Millisecond))
+	assert.True(t, ok)
+	assert.Equal(t, slot, fireTime)
+	assert.Equal(t, slot.Add(time.Minute), job.nextRunAt)
+}
@@ -0,0 +1,289 @@
+package cronworker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// schedule describes when a job should run, Next returns the first activation time later than given time,
+// or zero time if no activation time can be found
+type schedule interface {
+	Next(t time.Time) time.Time
+}
+
+type cronField struct {
+	name     string
+	min, max uint
+	names    map[string]uint
+}
+
+var (
+	secondField = cronField{name: "second", min: 0, max: 59}
+	minuteField = cronField{name: "minute", min: 0, max: 59}
+	hourField   = cronField{name: "hour", min: 0, max: 23}
+	domField    = cronField{name: "day of month", min: 1, max: 31}
+	monthField  = cronField{name: "month", min: 1, max: 12, names: map[string]uint{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dowField = cronField{name: "day of week", min: 0, max: 7, names: map[string]uint{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+
+	cronDescriptors = map[string]string{
+		"@yearly":   "0 0 0 1 1 *",
+		"@annually": "0 0 0 1 1 *",
+		"@monthly":  "0 0 0 1 * *",
+		"@weekly":   "0 0 0 * * 0",
+		"@daily":    "0 0 0 * * *",
+		"@midnight": "0 0 0 * * *",
+		"@hourly":   "0 0 * * * *",
+	}
+)
+
+// cronSchedule parsed standard cron expression, each field stored as bit set
+type cronSchedule struct {
+	second, minute, hour, dom, month, dow uint64
+	domStar, dowStar                      bool
+}
+
+// isCronExpression check interval is cron descriptor (@hourly, @daily, ...) or five/six fields cron expression
+func isCronExpression(interval string) bool {
+	if strings.HasPrefix(interval, "@") {
+		return true
+	}
+	fields := len(strings.Fields(interval))
+	return fields == 5 || fields == 6
+}
+
+// parseCronExpression parse standard cron expression, allowed format:
+//
+// * five fields: "minute hour day-of-month month day-of-week", example: "*/15 9-17 * * MON-FRI"
+//
+// * six fields (with seconds): "second minute hour day-of-month month day-of-week", example: "0 */15 9-17 * * MON-FRI"
+//
+// * descriptors: @yearly (or @annually), @monthly, @weekly, @daily (or @midnight), @hourly
+func parseCronExpression(expr string) (*cronSchedule, error) {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "@") {
+		spec, ok := cronDescriptors[strings.ToLower(expr)]
+		if !ok {
+			return nil, fmt.Errorf(`unknown cron descriptor "%s"`, expr)
+		}
+		expr = spec
+	}
+
+	fields := strings.Fields(expr)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf(`invalid cron expression "%s", must contains 5 or 6 fields`, expr)
+	}
+
+	var (
+		sched cronSchedule
+		err   error
+	)
+	if sched.second, err = parseCronField(fields[0], secondField); err != nil {
+		return nil, err
+	}
+	if sched.minute, err = parseCronField(fields[1], minuteField); err != nil {
+		return nil, err
+	}
+	if sched.hour, err = parseCronField(fields[2], hourField); err != nil {
+		return nil, err
+	}
+	if sched.dom, err = parseCronField(fields[3], domField); err != nil {
+		return nil, err
+	}
+	if sched.month, err = parseCronField(fields[4], monthField); err != nil {
+		return nil, err
+	}
+	if sched.dow, err = parseCronField(fields[5], dowField); err != nil {
+		return nil, err
+	}
+
+	// 7 is alias for sunday
+	if sched.dow&(1<<7) > 0 {
+		sched.dow = (sched.dow | 1) &^ (1 << 7)
+	}
+	sched.domStar = strings.HasPrefix(fields[3], "*") || strings.HasPrefix(fields[3], "?")
+	sched.dowStar = strings.HasPrefix(fields[5], "*") || strings.HasPrefix(fields[5], "?")
+
+	return &sched, nil
+}
+
+// parseCronField parse comma separated list of value, range (a-b), and step (*/n, a-b/n, a/n) into bit set
+func parseCronField(field string, f cronField) (bits uint64, err error) {
+	for _, part := range strings.Split(field, ",") {
+		if part == "" {
+			return 0, fmt.Errorf(`empty value in %s field "%s"`, f.name, field)
+		}
+
+		rangeAndStep := strings.Split(part, "/")
+		if len(rangeAndStep) > 2 {
+			return 0, fmt.Errorf(`invalid step in %s field "%s"`, f.name, part)
+		}
+
+		var start, end uint
+		step := uint(1)
+		switch lowAndHigh := strings.Split(rangeAndStep[0], "-"); {
+		case rangeAndStep[0] == "*" || rangeAndStep[0] == "?":
+			start, end = f.min, f.max
+		case len(lowAndHigh) == 1:
+			if start, err = parseCronValue(lowAndHigh[0], f); err != nil {
+				return 0, err
+			}
+			end = start
+			if len(rangeAndStep) == 2 {
+				end = f.max
+			}
+		case len(lowAndHigh) == 2:
+			if start, err = parseCronValue(lowAndHigh[0], f); err != nil {
+				return 0, err
+			}
+			if end, err = parseCronValue(lowAndHigh[1], f); err != nil {
+				return 0, err
+			}
+		default:
+			return 0, fmt.Errorf(`invalid range in %s field "%s"`, f.name, part)
+		}
+
+		if len(rangeAndStep) == 2 {
+			s, err := strconv.ParseUint(rangeAndStep[1], 10, 0)
+			if err != nil || s == 0 {
+				return 0, fmt.Errorf(`invalid step in %s field "%s"`, f.name, part)
+			}
+			step = uint(s)
+		}
+		if start > end {
+			return 0, fmt.Errorf(`invalid range in %s field "%s", start is greater than end`, f.name, part)
+		}
+
+		for i := start; i <= end; i += step {
+			bits |= 1 << i
+		}
+	}
+	return bits, nil
+}
+
+func parseCronValue(value string, f cronField) (uint, error) {
+	if v, ok := f.names[strings.ToLower(value)]; ok {
+		return v, nil
+	}
+	v, err := strconv.ParseUint(value, 10, 0)
+	if err != nil {
+		return 0, fmt.Errorf(`invalid value "%s" in %s field`, value, f.name)
+	}
+	if uint(v) < f.min || uint(v) > f.max {
+		return 0, fmt.Errorf(`value %d out of range [%d-%d] in %s field`, v, f.min, f.max, f.name)
+	}
+	return uint(v), nil
+}
+
+// Next implement schedule, search activation time field by field from month to second
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	// start from the next whole second
+	t = t.Add(time.Second - time.Duration(t.Nanosecond())*time.Nanosecond)
+	loc := t.Location()
+	yearLimit := t.Year() + 5
+	truncated := false
+
+WRAP:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+
+	for s.month&(1<<uint(t.Month())) == 0 {
+		if !truncated {
+			truncated = true
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+		}
+		t = t.AddDate(0, 1, 0)
+		if t.Month() == time.January {
+			goto WRAP
+		}
+	}
+
+	for !s.dayMatches(t) {
+		if !truncated {
+			truncated = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		}
+		t = t.AddDate(0, 0, 1)
+		// midnight may not exist or repeated when daylight saving time changes
+		if t.Hour() != 0 {
+			if t.Hour() > 12 {
+				t = t.Add(time.Duration(24-t.Hour()) * time.Hour)
+			} else {
+				t = t.Add(time.Duration(-t.Hour()) * time.Hour)
+			}
+		}
+		if t.Day() == 1 {
+			goto WRAP
+		}
+	}
+
+	for s.hour&(1<<uint(t.Hour())) == 0 {
+		if !truncated {
+			truncated = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+		}
+		t = t.Add(time.Hour)
+		if t.Hour() == 0 {
+			goto WRAP
+		}
+	}
+
+	for s.minute&(1<<uint(t.Minute())) == 0 {
+		if !truncated {
+			truncated = true
+			t = t.Truncate(time.Minute)
+		}
+		t = t.Add(time.Minute)
+		if t.Minute() == 0 {
+			goto WRAP
+		}
+	}
+
+	for s.second&(1<<uint(t.Second())) == 0 {
+		if !truncated {
+			truncated = true
+			t = t.Truncate(time.Second)
+		}
+		t = t.Add(time.Second)
+		if t.Second() == 0 {
+			goto WRAP
+		}
+	}
+
+	return t
+}
+
+// dayMatches if day of month or day of week is restricted (not "*"), only one of them need to match
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) > 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) > 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// atTimeSchedule start at given time and repeat with constant duration
+type atTimeSchedule struct {
+	start  time.Time
+	repeat time.Duration
+}
+
+// Next implement schedule
+func (s *atTimeSchedule) Next(t time.Time) time.Time {
+	if t.Before(s.start) {
+		return s.start
+	}
+	return s.start.Add((t.Sub(s.start)/s.repeat + 1) * s.repeat)
+}
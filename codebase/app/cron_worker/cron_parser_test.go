@@ -0,0 +1,105 @@
+package cronworker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCronScheduleNext(t *testing.T) {
+	tests := []struct {
+		expr, from, want string
+	}{
+		{expr: "0 */15 9-17 * * MON-FRI", from: "2021-05-14T08:59:59Z", want: "2021-05-14T09:00:00Z"},
+		{expr: "0 */15 9-17 * * MON-FRI", from: "2021-05-14T17:45:00Z", want: "2021-05-17T09:00:00Z"},
+		{expr: "*/5 * * * *", from: "2021-05-14T10:02:30Z", want: "2021-05-14T10:05:00Z"},
+		{expr: "30 2 * * *", from: "2021-05-14T02:30:00Z", want: "2021-05-15T02:30:00Z"},
+		{expr: "0 0 31 * *", from: "2021-04-01T00:00:00Z", want: "2021-05-31T00:00:00Z"},
+		{expr: "0 0 29 2 *", from: "2021-03-01T00:00:00Z", want: "2024-02-29T00:00:00Z"},
+		{expr: "0 0 1 * sun", from: "2021-05-14T00:00:00Z", want: "2021-05-16T00:00:00Z"},
+		{expr: "0 12 * * 7", from: "2021-05-14T00:00:00Z", want: "2021-05-16T12:00:00Z"},
+		{expr: "@hourly", from: "2021-05-14T10:00:00Z", want: "2021-05-14T11:00:00Z"},
+		{expr: "@midnight", from: "2021-05-14T10:00:00Z", want: "2021-05-15T00:00:00Z"},
+		{expr: "@monthly", from: "2021-12-14T10:00:00Z", want: "2022-01-01T00:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			sched, err := parseCronExpression(tt.expr)
+			assert.NoError(t, err)
+
+			from, _ := time.Parse(time.RFC3339, tt.from)
+			want, _ := time.Parse(time.RFC3339, tt.want)
+			assert.Equal(t, want, sched.Next(from))
+		})
+	}
+}
+
+func TestParseCronExpressionError(t *testing.T) {
+	for _, expr := range []string{
+		"* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8",
+		"5-1 * * * *", "*/0 * * * *", "* * * * FOO", "@every5m", "1,,2 * * * *",
+	} {
+		_, err := parseCronExpression(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestParseInterval(t *testing.T) {
+	now := time.Date(2021, time.January, 31, 10, 0, 0, 0, time.UTC)
+
+	duration, sched, err := parseInterval("10s", now)
+	assert.NoError(t, err)
+	assert.Nil(t, sched)
+	assert.Equal(t, 10*time.Second, duration)
+
+	duration, sched, err = parseInterval("@every 1m30s", now)
+	assert.NoError(t, err)
+	assert.Nil(t, sched)
+	assert.Equal(t, 90*time.Second, duration)
+
+	_, sched, err = parseInterval("09:00@daily", now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2021, time.February, 1, 9, 0, 0, 0, time.UTC), sched.Next(now))
+
+	_, sched, err = parseInterval("11:00@monthly", now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2021, time.January, 31, 11, 0, 0, 0, time.UTC), sched.Next(now))
+	// clamped to the last day of short month
+	assert.Equal(t, time.Date(2021, time.February, 28, 11, 0, 0, 0, time.UTC), sched.Next(sched.Next(now)))
+	assert.Equal(t, time.Date(2021, time.March, 31, 11, 0, 0, 0, time.UTC), sched.Next(time.Date(2021, time.February, 28, 11, 0, 0, 0, time.UTC)))
+
+	_, sched, err = parseInterval("11:00@yearly", time.Date(2020, time.February, 29, 10, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2021, time.February, 28, 11, 0, 0, 0, time.UTC), sched.Next(time.Date(2020, time.March, 1, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, time.Date(2024, time.February, 29, 11, 0, 0, 0, time.UTC), sched.Next(time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC)))
+
+	_, sched, err = parseInterval("09:00@10m", now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2021, time.January, 31, 10, 10, 0, 0, time.UTC), sched.Next(now))
+
+	_, _, err = parseInterval("0 0 30 2 *", now)
+	assert.Error(t, err)
+
+	_, _, err = parseInterval("25:00@daily", now)
+	assert.Error(t, err)
+}
+
+func TestCronScheduleNextWithTimezone(t *testing.T) {
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	assert.NoError(t, err)
+	newYork, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
+
+	sched, _ := parseCronExpression("0 2 * * *")
+	from := time.Date(2021, time.May, 14, 20, 0, 0, 0, time.UTC) // 03:00 on 15 May in Jakarta
+	assert.Equal(t, time.Date(2021, time.May, 15, 19, 0, 0, 0, time.UTC), sched.Next(from.In(jakarta)).UTC())
+
+	// daylight saving time starts at 2021-03-14 02:00 in New York
+	sched, _ = parseCronExpression("0 3 * * *")
+	from = time.Date(2021, time.March, 13, 3, 0, 0, 0, newYork)
+	next := sched.Next(from)
+	assert.Equal(t, time.Date(2021, time.March, 14, 3, 0, 0, 0, newYork), next)
+	assert.Equal(t, 23*time.Hour, next.Sub(from))
+}
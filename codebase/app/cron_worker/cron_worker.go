@@ -4,10 +4,14 @@ package cronworker
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"reflect"
+	"strings"
 	"sync"
 	"time"
 
@@ -18,22 +22,50 @@ import (
 	"github.com/golangid/candi/config/env"
 	"github.com/golangid/candi/logger"
 	"github.com/golangid/candi/tracer"
+	"github.com/gomodule/redigo/redis"
 )
 
 type cronWorker struct {
 	ctx           context.Context
 	ctxCancelFunc func()
 
-	service factory.ServiceFactory
-	consul  *candiutils.Consul
-	wg      sync.WaitGroup
+	service       factory.ServiceFactory
+	locker        candiutils.DistributedLocker
+	adminServer   *http.Server
+	historyStore  HistoryStore
+	fireTimeStore FireTimeStore
+	wg            sync.WaitGroup
+
+	// runCtx context of job executions in current lock session, canceled when distributed lock is lost
+	runMu      sync.Mutex
+	runCtx     context.Context
+	cancelRuns func()
+}
+
+// OptionFunc type
+type OptionFunc func(*cronWorker)
+
+// SetHistoryStore option func, set store for persist job run history (default from CRON_SCHEDULER_HISTORY_STORE environment)
+func SetHistoryStore(store HistoryStore) OptionFunc {
+	return func(c *cronWorker) {
+		c.historyStore = store
+	}
+}
+
+// SetFireTimeStore option func, set store for persist last activation time of job with catch up mode
+// (default from CRON_SCHEDULER_CATCH_UP_STORE environment)
+func SetFireTimeStore(store FireTimeStore) OptionFunc {
+	return func(c *cronWorker) {
+		c.fireTimeStore = store
+	}
 }
 
 // NewWorker create new cron worker
-func NewWorker(service factory.ServiceFactory) factory.AppServerFactory {
+func NewWorker(service factory.ServiceFactory, opts ...OptionFunc) factory.AppServerFactory {
 	refreshWorkerNotif, shutdown = make(chan struct{}), make(chan struct{})
 	semaphore = make(chan struct{}, env.BaseEnv().MaxGoroutines)
 	startWorkerCh, releaseWorkerCh = make(chan struct{}), make(chan struct{})
+	triggerJobCh = make(chan *Job, env.BaseEnv().MaxGoroutines)
 
 	// add shutdown channel to first index
 	workers = append(workers, reflect.SelectCase{
@@ -43,24 +75,53 @@ func NewWorker(service factory.ServiceFactory) factory.AppServerFactory {
 	workers = append(workers, reflect.SelectCase{
 		Dir: reflect.SelectRecv, Chan: reflect.ValueOf(refreshWorkerNotif),
 	})
+	// add trigger job channel to third index
+	workers = append(workers, reflect.SelectCase{
+		Dir: reflect.SelectRecv, Chan: reflect.ValueOf(triggerJobCh),
+	})
+	// add distributed lock lost channel to fourth index
+	workers = append(workers, reflect.SelectCase{
+		Dir: reflect.SelectRecv, Chan: reflect.ValueOf(releaseWorkerCh),
+	})
 
 	for _, m := range service.GetModules() {
 		if h := m.WorkerHandler(types.Scheduler); h != nil {
 			var handlerGroup types.WorkerHandlerGroup
 			h.MountHandlers(&handlerGroup)
 			for _, handler := range handlerGroup.Handlers {
-				funcName, args, interval := candihelper.ParseCronJobKey(handler.Pattern)
+				handler.RejectBatchHandler(types.Scheduler)
+				cronKey := candihelper.ParseCronJobKeyModel(handler.Pattern)
 
 				var job Job
-				job.HandlerName = funcName
+				job.HandlerName = cronKey.JobName
 				job.HandlerFunc = handler.HandlerFunc
-				job.Interval = interval
-				job.Params = args
+				job.Interval = cronKey.Interval
+				job.Params = cronKey.Args
+				job.Timezone = cronKey.Timezone
+				job.OverlapPolicy = cronKey.Overlap
+				job.ErrorHandlers = handler.ErrorHandler
+				if cronKey.Timeout != "" {
+					timeout, err := time.ParseDuration(cronKey.Timeout)
+					if err != nil {
+						panic(fmt.Errorf(`Cron Worker: "%s" invalid timeout: %v`, cronKey.JobName, err))
+					}
+					job.Timeout = timeout
+				}
+				job.CatchUpMode = cronKey.CatchUp
+				if cronKey.Lookback != "" {
+					lookback, err := time.ParseDuration(cronKey.Lookback)
+					if err != nil {
+						panic(fmt.Errorf(`Cron Worker: "%s" invalid catch up lookback: %v`, cronKey.JobName, err))
+					}
+					job.CatchUpLookback = lookback
+				}
 				if err := AddJob(job); err != nil {
-					panic(fmt.Errorf(`Cron Worker: "%s" %v`, interval, err))
+					panic(fmt.Errorf(`Cron Worker: "%s" %v`, cronKey.Interval, err))
 				}
 
-				logger.LogYellow(fmt.Sprintf(`[CRON-WORKER] (job name): %s (every): %-8s  --> (module): "%s"`, `"`+funcName+`"`, interval, m.Name()))
+				activeJob := activeJobs[len(activeJobs)-1]
+				logger.LogYellow(fmt.Sprintf(`[CRON-WORKER] (job name): %s (every): %-8s (next): %s  --> (module): "%s"`,
+					`"`+activeJob.HandlerName+`"`, activeJob.Interval, activeJob.nextActivationTime().Format(time.RFC3339), m.Name()))
 			}
 		}
 	}
@@ -69,17 +130,70 @@ func NewWorker(service factory.ServiceFactory) factory.AppServerFactory {
 	c := &cronWorker{
 		service: service,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
 
-	if env.BaseEnv().UseConsul {
-		consul, err := candiutils.NewConsul(&candiutils.ConsulConfig{
-			ConsulAgentHost:   env.BaseEnv().ConsulAgentHost,
-			ConsulKey:         fmt.Sprintf("%s_cron_worker", service.Name()),
+	if c.historyStore == nil {
+		switch env.BaseEnv().CronSchedulerHistoryStore {
+		case "memory":
+			c.historyStore = NewInMemoryHistoryStore(0)
+		case "redis":
+			c.historyStore = NewRedisHistoryStore(redisWritePool(service, "CRON_SCHEDULER_HISTORY_STORE"),
+				fmt.Sprintf("%s:cron_worker:history", service.Name()), 0)
+		}
+	}
+	historyStore = c.historyStore
+	loadLastSuccess()
+
+	if c.fireTimeStore == nil {
+		switch env.BaseEnv().CronSchedulerCatchUpStore {
+		case "redis":
+			c.fireTimeStore = NewRedisFireTimeStore(redisWritePool(service, "CRON_SCHEDULER_CATCH_UP_STORE"),
+				fmt.Sprintf("%s:cron_worker:last_fire_time", service.Name()))
+		case "postgres":
+			store, err := NewPostgresFireTimeStore(sqlWriteDB(service, "CRON_SCHEDULER_CATCH_UP_STORE"),
+				fmt.Sprintf("%s_cron_worker_last_fire_time", strings.ReplaceAll(string(service.Name()), "-", "_")))
+			if err != nil {
+				panic(fmt.Errorf("Cron Worker: %v", err))
+			}
+			c.fireTimeStore = store
+		}
+	}
+	fireTimeStore = c.fireTimeStore
+	for _, job := range activeJobs {
+		if job.CatchUpMode != "" && fireTimeStore == nil {
+			panic(fmt.Errorf(`Cron Worker: "%s" using catch up mode but fire time store is not configured (set CRON_SCHEDULER_CATCH_UP_STORE environment)`,
+				job.HandlerName))
+		}
+	}
+
+	if env.BaseEnv().DistributedLockBackend != "" {
+		locker, err := candiutils.NewDistributedLocker(&candiutils.DistributedLockerConfig{
+			Backend:           env.BaseEnv().DistributedLockBackend,
+			Key:               fmt.Sprintf("%s_cron_worker", service.Name()),
 			LockRetryInterval: time.Second,
+			ConsulAgentHost:   env.BaseEnv().ConsulAgentHost,
+			RedisPool:         service.GetDependency().GetRedisPool(),
+			SQLDatabase:       service.GetDependency().GetSQLDatabase(),
 		})
 		if err != nil {
 			panic(err)
 		}
-		c.consul = consul
+		c.locker = locker
+	}
+
+	if env.BaseEnv().CronSchedulerAdminPort > 0 {
+		var basicAuth func(http.Handler) http.Handler
+		if mw := service.GetDependency().GetMiddleware(); mw != nil {
+			basicAuth = mw.HTTPBasicAuth
+		}
+		adminServer, err := newAdminServer(basicAuth)
+		if err != nil {
+			panic(fmt.Errorf("Cron Worker: %v", err))
+		}
+		c.adminServer = adminServer
+		fmt.Printf("\x1b[34;1m⇨ Cron worker admin api run at port [::]:%d\x1b[0m\n\n", env.BaseEnv().CronSchedulerAdminPort)
 	}
 
 	c.ctx, c.ctxCancelFunc = context.WithCancel(context.Background())
@@ -87,17 +201,28 @@ func NewWorker(service factory.ServiceFactory) factory.AppServerFactory {
 }
 
 func (c *cronWorker) Serve() {
-	c.createConsulSession()
+	if c.adminServer != nil {
+		go c.serveAdminAPI()
+	}
+	c.createLockSession()
 
 START:
 	select {
 	case <-startWorkerCh:
+		c.startRunSession()
+		// last fire time is read before jobs started, then catch up run in background so that scheduler is not blocked by full semaphore
+		missed := c.loadMissedExecutions()
 		startAllJob()
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.catchUpMissedJobs(missed)
+		}()
 		totalRunJobs := 0
 
 		// run worker
 		for {
-			chosen, _, ok := reflect.Select(workers)
+			chosen, value, ok := reflect.Select(workers)
 			if !ok {
 				continue
 			}
@@ -112,37 +237,36 @@ START:
 				continue
 			}
 
-			chosen = chosen - 2
-			job := activeJobs[chosen]
-			if job.nextDuration != nil {
-				job.ticker.Stop()
-				job.currentDuration = *job.nextDuration
-				job.ticker = time.NewTicker(*job.nextDuration)
-				workers[job.WorkerIndex].Chan = reflect.ValueOf(job.ticker.C)
-				activeJobs[chosen].nextDuration = nil
+			// distributed lock is lost, stop all jobs and wait until lock acquired again
+			if chosen == 3 {
+				logger.LogYellow("Cron Scheduler: distributed lock is lost, stop all jobs and cancel running jobs")
+				c.cancelRunSession()
+				c.createLockSession()
+				goto START
 			}
 
-			semaphore <- struct{}{}
-			c.wg.Add(1)
-			go func(j *Job) {
-				defer func() {
-					c.wg.Done()
-					<-semaphore
-				}()
-
-				if c.ctx.Err() != nil {
-					logger.LogRed("cron_scheduler > ctx root err: " + c.ctx.Err().Error())
-					return
+			var job *Job
+			var fireTime time.Time
+			if chosen == 2 {
+				// job triggered manually
+				job = value.Interface().(*Job)
+			} else {
+				job = activeJobs[chosen-4]
+				if fireTime, ok = job.tick(value.Interface().(time.Time)); !ok {
+					continue
 				}
-				c.processJob(j)
-			}(job)
+			}
+
+			if !c.runJob(job, fireTime) {
+				continue
+			}
 
-			if c.consul != nil {
+			if c.locker != nil {
 				totalRunJobs++
 				// if already running n jobs, release lock so that run in another instance
 				if totalRunJobs == env.BaseEnv().ConsulMaxJobRebalance {
 					// recreate session
-					c.createConsulSession()
+					c.createLockSession()
 					<-releaseWorkerCh
 					goto START
 				}
@@ -157,8 +281,8 @@ START:
 func (c *cronWorker) Shutdown(ctx context.Context) {
 	log.Println("\x1b[33;1mStopping Cron Job Scheduler worker...\x1b[0m")
 	defer func() {
-		if c.consul != nil {
-			if err := c.consul.DestroySession(); err != nil {
+		if c.locker != nil {
+			if err := c.locker.DestroySession(); err != nil {
 				panic(err)
 			}
 		}
@@ -166,6 +290,9 @@ func (c *cronWorker) Shutdown(ctx context.Context) {
 	}()
 
 	c.ctxCancelFunc()
+	if c.adminServer != nil {
+		c.adminServer.Shutdown(ctx)
+	}
 	if len(activeJobs) == 0 {
 		return
 	}
@@ -183,38 +310,253 @@ func (c *cronWorker) Name() string {
 	return string(types.Scheduler)
 }
 
-func (c *cronWorker) createConsulSession() {
-	if c.consul == nil {
+func (c *cronWorker) createLockSession() {
+	if c.locker == nil {
 		go func() { startWorkerCh <- struct{}{} }()
 		return
 	}
-	c.consul.DestroySession()
+	c.locker.DestroySession()
 	stopAllJob()
 	hostname, _ := os.Hostname()
 	value := map[string]string{
 		"hostname": hostname,
 	}
-	go c.consul.RetryLockAcquire(value, startWorkerCh, releaseWorkerCh)
+	go c.locker.RetryLockAcquire(value, startWorkerCh, releaseWorkerCh)
 }
 
-func (c *cronWorker) processJob(job *Job) {
-	trace, ctx := tracer.StartTraceWithContext(c.ctx, "CronScheduler")
-	defer trace.Finish()
+// startRunSession create context of job executions if not exist or canceled by lost lock,
+// running jobs from previous session (released for rebalance) keep the same context
+func (c *cronWorker) startRunSession() {
+	c.runMu.Lock()
+	defer c.runMu.Unlock()
+	if c.runCtx == nil || c.runCtx.Err() != nil {
+		c.runCtx, c.cancelRuns = context.WithCancel(c.ctx)
+	}
+}
+
+// cancelRunSession cancel context of running jobs, so that jobs are not running beside the new lock holder
+func (c *cronWorker) cancelRunSession() {
+	c.runMu.Lock()
+	defer c.runMu.Unlock()
+	if c.cancelRuns != nil {
+		c.cancelRuns()
+	}
+}
+
+// runContext get context of job executions in current session, root context if session is not started
+func (c *cronWorker) runContext() context.Context {
+	c.runMu.Lock()
+	defer c.runMu.Unlock()
+	if c.runCtx == nil {
+		return c.ctx
+	}
+	return c.runCtx
+}
 
+// runJob start job execution in new goroutine, return false if execution is not started because of overlap policy.
+// fireTime is scheduled activation time of job, zero if job triggered manually
+func (c *cronWorker) runJob(job *Job, fireTime time.Time) bool {
+	switch job.startRun(fireTime) {
+	case runSkipped:
+		c.wg.Add(1)
+		go func(j *Job) {
+			defer c.wg.Done()
+			c.reportSkippedJob(j)
+		}(job)
+		return false
+	case runQueued:
+		// queued execution will be run after current execution is done
+		return false
+	}
+
+	ctx := c.runContext()
+	semaphore <- struct{}{}
+	c.wg.Add(1)
+	go func(j *Job) {
+		defer func() {
+			c.wg.Done()
+			<-semaphore
+		}()
+
+		if ctx.Err() != nil {
+			logger.LogRed("cron_scheduler > ctx root err: " + ctx.Err().Error())
+			j.cancelRun()
+			return
+		}
+		c.processJob(ctx, j, fireTime)
+		c.runQueuedJob(ctx, j)
+	}(job)
+	return true
+}
+
+// runQueuedJob finish job execution and run queued execution (by overlap policy) until queue is empty
+func (c *cronWorker) runQueuedJob(ctx context.Context, job *Job) {
+	for {
+		fireTime, queued := job.finishRun()
+		if !queued {
+			return
+		}
+		if ctx.Err() != nil {
+			logger.LogRed("cron_scheduler > ctx root err: " + ctx.Err().Error())
+			job.cancelRun()
+			return
+		}
+		c.processJob(ctx, job, fireTime)
+	}
+}
+
+// processJob execute job with ctx from current run session
+func (c *cronWorker) processJob(ctx context.Context, job *Job, fireTime time.Time) {
+	if job.Timeout > 0 {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, job.Timeout)
+		defer cancel()
+	}
+
+	trace, ctx := tracer.StartTraceWithContext(ctx, "CronScheduler")
+	executedAt := time.Now().In(job.location)
+	history := newRunHistory(job.HandlerName, executedAt)
+	history.TraceID = tracer.GetTraceID(ctx)
+
+	var err error
 	defer func() {
 		if r := recover(); r != nil {
-			trace.SetError(fmt.Errorf("%v", r))
+			err = fmt.Errorf("panic: %v", r)
+		}
+		if errors.Is(err, ErrJobTimeout) {
+			trace.SetTag("timeout", job.Timeout.String())
 		}
+
+		status := HistoryStatusSuccess
+		if err != nil {
+			status = HistoryStatusFailure
+			trace.SetError(err)
+			for _, errHandler := range job.ErrorHandlers {
+				errHandler(ctx, types.Scheduler, job.HandlerName, []byte(job.Params), err)
+			}
+		}
+		history.finish(status, err)
+		c.saveHistory(job, history)
+		if err == nil {
+			c.saveFireTime(job, fireTime)
+		}
+
 		logger.LogGreen("cron scheduler > trace_url: " + tracer.GetTraceURL(ctx))
+		trace.Finish()
+	}()
+
+	job.markLastRun(executedAt)
+	if env.BaseEnv().DebugMode {
+		log.Printf("\x1b[35;3mCron Scheduler: executing task '%s' (interval: %s, timezone: %s, time: %s)\x1b[0m",
+			job.HandlerName, job.Interval, job.Timezone, executedAt.Format(time.RFC3339))
+	}
+
+	tags := trace.Tags()
+	tags["job_name"] = job.HandlerName
+	tags["interval"] = job.Interval
+	tags["timezone"] = job.Timezone
+	tags["executed_at"] = executedAt.Format(time.RFC3339)
+	tags["overlap_policy"] = job.OverlapPolicy
+	if !fireTime.IsZero() {
+		tags["scheduled_at"] = fireTime.In(job.location).Format(time.RFC3339)
+	}
+	err = execHandler(ctx, job)
+}
+
+// execHandler run handler of job in new goroutine, return ErrJobTimeout when timeout is reached without wait handler
+// (handler which ignore context is left running in background), so that execution is reported and released at deadline.
+// Handler error caused by timeout context is also returned as ErrJobTimeout
+func execHandler(ctx context.Context, job *Job) error {
+	done := make(chan error, 1)
+	handlerFunc, params := job.HandlerFunc, []byte(job.Params)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		done <- handlerFunc(ctx, params)
+	}()
+
+	timeout := fmt.Errorf("%w after %s", ErrJobTimeout, job.Timeout)
+	select {
+	case err := <-done:
+		if err != nil && ctx.Err() == context.DeadlineExceeded {
+			return timeout
+		}
+		return err
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return timeout
+		}
+		// root context is canceled (shutdown), wait handler until done
+		return <-done
+	}
+}
+
+func (c *cronWorker) reportSkippedJob(job *Job) {
+	trace, ctx := tracer.StartTraceWithContext(c.ctx, "CronScheduler")
+	defer func() {
+		if r := recover(); r != nil {
+			trace.SetError(fmt.Errorf("%v", r))
+		}
+		trace.Finish()
 	}()
 
 	if env.BaseEnv().DebugMode {
-		log.Printf("\x1b[35;3mCron Scheduler: executing task '%s' (interval: %s)\x1b[0m", job.HandlerName, job.Interval)
+		log.Printf("\x1b[33;3mCron Scheduler: skip task '%s', previous execution is still running\x1b[0m", job.HandlerName)
 	}
 
 	tags := trace.Tags()
 	tags["job_name"] = job.HandlerName
-	if err := job.HandlerFunc(ctx, []byte(job.Params)); err != nil {
-		trace.SetError(err)
+	tags["overlap_policy"] = job.OverlapPolicy
+	tags["skipped"] = true
+
+	err := fmt.Errorf("%w (overlap policy: %s)", ErrJobSkipped, job.OverlapPolicy)
+	history := newRunHistory(job.HandlerName, time.Now().In(job.location))
+	history.TraceID = tracer.GetTraceID(ctx)
+	history.finish(HistoryStatusSkipped, err)
+	c.saveHistory(job, history)
+
+	trace.SetError(err)
+	for _, errHandler := range job.ErrorHandlers {
+		errHandler(ctx, types.Scheduler, job.HandlerName, []byte(job.Params), err)
+	}
+}
+
+func (c *cronWorker) saveHistory(job *Job, history *RunHistory) {
+	job.markFinished(history)
+	if historyStore == nil {
+		return
+	}
+	if err := historyStore.Save(context.Background(), history); err != nil {
+		logger.LogRed("cron_scheduler > failed save run history: " + err.Error())
+	}
+}
+
+// saveFireTime persist scheduled activation time of success execution, used for catch up missed execution.
+// Failed execution is not saved, so that it is executed again by catch up after restart
+func (c *cronWorker) saveFireTime(job *Job, fireTime time.Time) {
+	if job.CatchUpMode == "" || fireTimeStore == nil || fireTime.IsZero() {
+		return
+	}
+	if err := fireTimeStore.SetLastFireTime(context.Background(), job.HandlerName, fireTime); err != nil {
+		logger.LogRed("cron_scheduler > failed save last fire time: " + err.Error())
+	}
+}
+
+// redisWritePool get redis write pool from dependency for store configured in envName, panic if redis is not configured
+func redisWritePool(service factory.ServiceFactory, envName string) *redis.Pool {
+	if redisPool := service.GetDependency().GetRedisPool(); redisPool != nil && redisPool.WritePool() != nil {
+		return redisPool.WritePool()
+	}
+	panic(fmt.Errorf("Cron Worker: %s environment is \"redis\" but redis is not configured in dependency", envName))
+}
+
+// sqlWriteDB get sql write database from dependency for store configured in envName, panic if sql database is not configured
+func sqlWriteDB(service factory.ServiceFactory, envName string) *sql.DB {
+	if sqlDB := service.GetDependency().GetSQLDatabase(); sqlDB != nil && sqlDB.WriteDB() != nil {
+		return sqlDB.WriteDB()
 	}
+	panic(fmt.Errorf("Cron Worker: %s environment is \"postgres\" but sql database is not configured in dependency", envName))
 }
@@ -0,0 +1,197 @@
+package cronworker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	// HistoryStatusSuccess job execution success
+	HistoryStatusSuccess = "SUCCESS"
+	// HistoryStatusFailure job execution return error, panic, or timeout
+	HistoryStatusFailure = "FAILURE"
+	// HistoryStatusSkipped job execution skipped by overlap policy
+	HistoryStatusSkipped = "SKIPPED"
+
+	defaultHistoryLimit = 100
+)
+
+// RunHistory model, record of job execution
+type RunHistory struct {
+	JobName    string    `json:"job_name"`
+	Status     string    `json:"status"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Duration   string    `json:"duration"`
+	Error      string    `json:"error,omitempty"`
+	TraceID    string    `json:"trace_id,omitempty"`
+}
+
+// HistoryStore abstraction for persist job run history
+type HistoryStore interface {
+	Save(ctx context.Context, history *RunHistory) error
+	// FindByJobName find latest run histories of job, ordered from the newest
+	FindByJobName(ctx context.Context, jobName string, limit int) ([]RunHistory, error)
+	// FindLastSuccess find latest success run history of job, return nil if job never succeeded
+	FindLastSuccess(ctx context.Context, jobName string) (*RunHistory, error)
+}
+
+func newRunHistory(jobName string, startedAt time.Time) *RunHistory {
+	return &RunHistory{JobName: jobName, StartedAt: startedAt}
+}
+
+func (h *RunHistory) finish(status string, err error) {
+	h.Status = status
+	h.FinishedAt = time.Now().In(h.StartedAt.Location())
+	h.Duration = h.FinishedAt.Sub(h.StartedAt).String()
+	if err != nil {
+		h.Error = err.Error()
+	}
+}
+
+// inMemoryHistoryStore store run history in memory, history will lost when service restarted
+type inMemoryHistoryStore struct {
+	mu          sync.RWMutex
+	limit       int
+	histories   map[string][]RunHistory
+	lastSuccess map[string]RunHistory
+}
+
+// NewInMemoryHistoryStore constructor, maxHistoryPerJob for maximum stored history of each job (default 100)
+func NewInMemoryHistoryStore(maxHistoryPerJob int) HistoryStore {
+	if maxHistoryPerJob <= 0 {
+		maxHistoryPerJob = defaultHistoryLimit
+	}
+	return &inMemoryHistoryStore{
+		limit:       maxHistoryPerJob,
+		histories:   make(map[string][]RunHistory),
+		lastSuccess: make(map[string]RunHistory),
+	}
+}
+
+func (s *inMemoryHistoryStore) Save(ctx context.Context, history *RunHistory) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	histories := append([]RunHistory{*history}, s.histories[history.JobName]...)
+	if len(histories) > s.limit {
+		histories = histories[:s.limit]
+	}
+	s.histories[history.JobName] = histories
+	if history.Status == HistoryStatusSuccess {
+		s.lastSuccess[history.JobName] = *history
+	}
+	return nil
+}
+
+func (s *inMemoryHistoryStore) FindByJobName(ctx context.Context, jobName string, limit int) ([]RunHistory, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	histories := s.histories[jobName]
+	if limit > 0 && len(histories) > limit {
+		histories = histories[:limit]
+	}
+	return append([]RunHistory{}, histories...), nil
+}
+
+func (s *inMemoryHistoryStore) FindLastSuccess(ctx context.Context, jobName string) (*RunHistory, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	history, ok := s.lastSuccess[jobName]
+	if !ok {
+		return nil, nil
+	}
+	return &history, nil
+}
+
+// redisHistoryStore store run history in redis list, shared between multiple instance
+type redisHistoryStore struct {
+	pool      *redis.Pool
+	keyPrefix string
+	limit     int
+}
+
+// NewRedisHistoryStore constructor, history stored in "{keyPrefix}:{{jobName}}" list key (job name as hash tag,
+// so that all keys of job in the same slot in redis cluster), maxHistoryPerJob for maximum stored history of each job (default 100)
+func NewRedisHistoryStore(pool *redis.Pool, keyPrefix string, maxHistoryPerJob int) HistoryStore {
+	if maxHistoryPerJob <= 0 {
+		maxHistoryPerJob = defaultHistoryLimit
+	}
+	return &redisHistoryStore{
+		pool: pool, keyPrefix: keyPrefix, limit: maxHistoryPerJob,
+	}
+}
+
+func (s *redisHistoryStore) Save(ctx context.Context, history *RunHistory) error {
+	payload, err := json.Marshal(history)
+	if err != nil {
+		return err
+	}
+
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	key := s.key(history.JobName)
+	conn.Send("MULTI")
+	conn.Send("LPUSH", key, payload)
+	conn.Send("LTRIM", key, 0, s.limit-1)
+	if history.Status == HistoryStatusSuccess {
+		conn.Send("SET", key+":last_success", payload)
+	}
+	_, err = conn.Do("EXEC")
+	return err
+}
+
+func (s *redisHistoryStore) FindByJobName(ctx context.Context, jobName string, limit int) ([]RunHistory, error) {
+	if limit <= 0 || limit > s.limit {
+		limit = s.limit
+	}
+
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	values, err := redis.ByteSlices(conn.Do("LRANGE", s.key(jobName), 0, limit-1))
+	if err != nil {
+		return nil, err
+	}
+
+	histories := make([]RunHistory, 0, len(values))
+	for _, value := range values {
+		var history RunHistory
+		if err := json.Unmarshal(value, &history); err != nil {
+			return nil, err
+		}
+		histories = append(histories, history)
+	}
+	return histories, nil
+}
+
+func (s *redisHistoryStore) FindLastSuccess(ctx context.Context, jobName string) (*RunHistory, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	value, err := redis.Bytes(conn.Do("GET", s.key(jobName)+":last_success"))
+	if err == redis.ErrNil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var history RunHistory
+	if err := json.Unmarshal(value, &history); err != nil {
+		return nil, err
+	}
+	return &history, nil
+}
+
+func (s *redisHistoryStore) key(jobName string) string {
+	return fmt.Sprintf("%s:{%s}", s.keyPrefix, jobName)
+}
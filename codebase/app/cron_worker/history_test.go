@@ -0,0 +1,60 @@
+package cronworker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	mocks "github.com/golangid/candi/mocks/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInMemoryHistoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemoryHistoryStore(2)
+
+	lastSuccess, err := store.FindLastSuccess(ctx, "daily-report")
+	assert.NoError(t, err)
+	assert.Nil(t, lastSuccess)
+
+	startedAt := time.Date(2021, time.May, 14, 2, 0, 0, 0, time.UTC)
+	for i, status := range []string{HistoryStatusSuccess, HistoryStatusFailure, HistoryStatusSkipped} {
+		history := newRunHistory("daily-report", startedAt.Add(time.Duration(i)*time.Hour))
+		var err error
+		if status != HistoryStatusSuccess {
+			err = errors.New(status)
+		}
+		history.finish(status, err)
+		assert.NoError(t, store.Save(ctx, history))
+	}
+
+	histories, err := store.FindByJobName(ctx, "daily-report", 10)
+	assert.NoError(t, err)
+	assert.Len(t, histories, 2)
+	assert.Equal(t, HistoryStatusSkipped, histories[0].Status)
+	assert.Equal(t, HistoryStatusFailure, histories[1].Status)
+
+	lastSuccess, err = store.FindLastSuccess(ctx, "daily-report")
+	assert.NoError(t, err)
+	assert.Equal(t, startedAt, lastSuccess.StartedAt)
+	assert.Empty(t, lastSuccess.Error)
+}
+
+func TestRedisHistoryStoreSaveKeys(t *testing.T) {
+	conn := mocks.NewConn(nil)
+	store := NewRedisHistoryStore(conn.Pool(), "service:cron_worker:history", 0)
+
+	history := newRunHistory("daily-report", time.Now())
+	history.finish(HistoryStatusSuccess, nil)
+	assert.NoError(t, store.Save(context.Background(), history))
+
+	// all keys in transaction must in the same slot (hash tag) for redis cluster
+	assert.Equal(t, []string{
+		"MULTI",
+		"LPUSH service:cron_worker:history:{daily-report}",
+		"LTRIM service:cron_worker:history:{daily-report}",
+		"SET service:cron_worker:history:{daily-report}:last_success",
+		"EXEC",
+	}, conn.CommandKeys())
+}
@@ -1,32 +1,92 @@
 package cronworker
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"reflect"
 	"sync"
 	"time"
 
+	"github.com/golangid/candi/candihelper"
 	"github.com/golangid/candi/codebase/factory/types"
+	"github.com/golangid/candi/config/env"
 )
 
 // Job model
 type Job struct {
-	HandlerName     string                  `json:"handler_name"`
-	Interval        string                  `json:"interval"`
-	HandlerFunc     types.WorkerHandlerFunc `json:"-"`
-	Params          string                  `json:"params"`
-	WorkerIndex     int                     `json:"worker_index"`
+	HandlerName     string                     `json:"handler_name"`
+	Interval        string                     `json:"interval"`
+	HandlerFunc     types.WorkerHandlerFunc    `json:"-"`
+	Params          string                     `json:"params"`
+	Timezone        string                     `json:"timezone"`
+	OverlapPolicy   string                     `json:"overlap_policy"`
+	Timeout         time.Duration              `json:"timeout"`
+	CatchUpMode     string                     `json:"catch_up_mode"`
+	CatchUpLookback time.Duration              `json:"catch_up_lookback"`
+	ErrorHandlers   []types.WorkerErrorHandler `json:"-"`
+	WorkerIndex     int                        `json:"worker_index"`
+	location        *time.Location
 	ticker          *time.Ticker
 	currentDuration time.Duration
-	nextDuration    *time.Duration
+	schedule        schedule
+	paused          bool
+	nextRunAt       time.Time
+	lastRunAt       time.Time
+	lastSuccessAt   time.Time
+	lastError       string
+	running         int
+	queued          bool
+	queuedFireTime  time.Time
 }
 
+// JobStatus runtime status of registered job
+type JobStatus struct {
+	HandlerName   string     `json:"handler_name"`
+	Interval      string     `json:"interval"`
+	Params        string     `json:"params"`
+	Timezone      string     `json:"timezone"`
+	OverlapPolicy string     `json:"overlap_policy"`
+	Timeout       string     `json:"timeout,omitempty"`
+	CatchUpMode   string     `json:"catch_up_mode,omitempty"`
+	Paused        bool       `json:"paused"`
+	Running       int        `json:"running"`
+	NextRunAt     *time.Time `json:"next_run_at,omitempty"`
+	LastRunAt     *time.Time `json:"last_run_at,omitempty"`
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+}
+
+type runState int
+
+const (
+	runStart runState = iota
+	runQueued
+	runSkipped
+)
+
+var (
+	// ErrJobSkipped error when job execution is skipped because previous execution is still running (by overlap policy)
+	ErrJobSkipped = errors.New("execution skipped, previous execution is still running")
+	// ErrJobTimeout error when job execution exceeds timeout
+	ErrJobTimeout = errors.New("execution timeout")
+	// ErrJobNotFound error when job name is not registered
+	ErrJobNotFound = errors.New("job not found")
+	// ErrHistoryStoreNotSet error when get run histories but history store is not configured
+	ErrHistoryStoreNotSet = errors.New("history store is not configured")
+	// ErrWorkerInactive error when cron worker is not running jobs in this instance (lock is held by another instance)
+	ErrWorkerInactive = errors.New("cron worker is not active in this instance")
+)
+
 var (
 	activeJobs                                                              []*Job
 	workers                                                                 []reflect.SelectCase
 	refreshWorkerNotif, shutdown, semaphore, startWorkerCh, releaseWorkerCh chan struct{}
-	mutex                                                                   sync.Mutex
+	triggerJobCh                                                            chan *Job
+	historyStore                                                            HistoryStore
+	fireTimeStore                                                           FireTimeStore
+	isWorkerActive                                                          bool
+	mutex, runMutex                                                         sync.Mutex
 )
 
 // GetActiveJobs get registered jobs
@@ -45,26 +105,109 @@ func UpdateIntervalActiveJob(jobNumber int, newInterval string) (err error) {
 	mutex.Lock()
 	defer mutex.Unlock()
 
-	job := activeJobs[jobNumber]
-	job.Interval = newInterval
-	job.nextDuration = nil
+	return activeJobs[jobNumber].updateInterval(newInterval)
+}
 
-	duration, errParse := time.ParseDuration(newInterval)
-	if errParse != nil {
-		durationParser, nextDuration, errParse := parseAtTime(newInterval)
-		if errParse != nil {
-			return errParse
-		}
-		job.nextDuration = &nextDuration
-		duration = durationParser
+// GetJobHistories get latest run histories of job from history store, ordered from the newest
+func GetJobHistories(ctx context.Context, jobName string, limit int) ([]RunHistory, error) {
+	mutex.Lock()
+	_, err := findJob(jobName)
+	mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if historyStore == nil {
+		return nil, ErrHistoryStoreNotSet
+	}
+	return historyStore.FindByJobName(ctx, jobName, limit)
+}
+
+// GetJobStatuses get runtime status of all registered jobs
+func GetJobStatuses() []JobStatus {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	statuses := make([]JobStatus, 0, len(activeJobs))
+	for _, job := range activeJobs {
+		statuses = append(statuses, job.status())
+	}
+	return statuses
+}
+
+// GetJobStatus get runtime status of job by name
+func GetJobStatus(jobName string) (JobStatus, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	job, err := findJob(jobName)
+	if err != nil {
+		return JobStatus{}, err
+	}
+	return job.status(), nil
+}
+
+// TriggerJob execute job immediately (still follow overlap policy of job), without change next activation time
+func TriggerJob(jobName string) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	job, err := findJob(jobName)
+	if err != nil {
+		return err
+	}
+	if !isWorkerActive {
+		return ErrWorkerInactive
+	}
+
+	select {
+	case triggerJobCh <- job:
+		return nil
+	default:
+		return errors.New("too many triggered jobs waiting to be executed")
 	}
+}
+
+// PauseJob stop activation of job until resumed, running execution is not canceled
+func PauseJob(jobName string) error {
+	mutex.Lock()
+	defer mutex.Unlock()
 
+	job, err := findJob(jobName)
+	if err != nil {
+		return err
+	}
+	job.paused = true
 	job.ticker.Stop()
-	job.ticker = time.NewTicker(duration)
-	workers[job.WorkerIndex].Chan = reflect.ValueOf(job.ticker.C)
-	refreshWorkerNotif <- struct{}{}
+	job.nextRunAt = time.Time{}
+	return nil
+}
 
-	return
+// ResumeJob continue activation of paused job, next activation time calculated from now
+func ResumeJob(jobName string) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	job, err := findJob(jobName)
+	if err != nil {
+		return err
+	}
+	job.paused = false
+	if isWorkerActive {
+		job.resetTicker()
+	}
+	return nil
+}
+
+// RescheduleJob change interval of job by name, allowed interval format same with candihelper.CronJobKeyToString
+func RescheduleJob(jobName, newInterval string) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	job, err := findJob(jobName)
+	if err != nil {
+		return err
+	}
+	return job.updateInterval(newInterval)
 }
 
 // AddJob to cron worker
@@ -78,19 +221,51 @@ func AddJob(job Job) error {
 	if job.HandlerName == "" {
 		return errors.New("handler name cannot empty")
 	}
+	if _, err := findJob(job.HandlerName); err == nil {
+		return fmt.Errorf(`job name "%s" already registered`, job.HandlerName)
+	}
 
-	duration, err := time.ParseDuration(job.Interval)
-	if err != nil {
-		durationParser, nextDuration, err := parseAtTime(job.Interval)
-		if err != nil {
-			return err
+	switch job.OverlapPolicy {
+	case "":
+		job.OverlapPolicy = candihelper.CronOverlapAllow
+	case candihelper.CronOverlapAllow, candihelper.CronOverlapSkip, candihelper.CronOverlapQueue:
+	default:
+		return fmt.Errorf(`invalid overlap policy "%s" (must one of "%s", "%s", "%s")`, job.OverlapPolicy,
+			candihelper.CronOverlapAllow, candihelper.CronOverlapSkip, candihelper.CronOverlapQueue)
+	}
+	if job.Timeout < 0 {
+		return errors.New("timeout cannot negative")
+	}
+	switch job.CatchUpMode {
+	case "":
+	case candihelper.CronCatchUpLatest, candihelper.CronCatchUpAll:
+		if job.CatchUpLookback < 0 {
+			return errors.New("catch up lookback cannot negative")
+		}
+		if job.CatchUpLookback == 0 {
+			job.CatchUpLookback = defaultCatchUpLookback
 		}
-		job.nextDuration = &nextDuration
-		duration = durationParser
+	default:
+		return fmt.Errorf(`invalid catch up mode "%s" (must one of "%s", "%s")`, job.CatchUpMode,
+			candihelper.CronCatchUpLatest, candihelper.CronCatchUpAll)
+	}
+
+	location, err := loadJobLocation(job.Timezone)
+	if err != nil {
+		return err
+	}
+	job.location = location
+	job.Timezone = location.String()
+
+	duration, sched, err := parseInterval(job.Interval, time.Now().In(location))
+	if err != nil {
+		return err
 	}
 
 	job.currentDuration = duration
-	job.ticker = time.NewTicker(duration)
+	job.schedule = sched
+	job.ticker = time.NewTicker(job.nextTickDuration())
+	job.nextRunAt = job.nextActivationTime()
 	job.WorkerIndex = len(workers)
 
 	activeJobs = append(activeJobs, &job)
@@ -102,9 +277,18 @@ func AddJob(job Job) error {
 }
 
 func startAllJob() {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	isWorkerActive = true
 	for _, job := range activeJobs {
-		job.ticker = time.NewTicker(job.currentDuration)
+		job.ticker = time.NewTicker(job.nextTickDuration())
 		workers[job.WorkerIndex].Chan = reflect.ValueOf(job.ticker.C)
+		job.nextRunAt = job.nextActivationTime()
+		if job.paused {
+			job.ticker.Stop()
+			job.nextRunAt = time.Time{}
+		}
 	}
 	go func() {
 		refreshWorkerNotif <- struct{}{}
@@ -112,7 +296,205 @@ func startAllJob() {
 }
 
 func stopAllJob() {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	isWorkerActive = false
 	for _, job := range activeJobs {
 		job.ticker.Stop()
 	}
 }
+
+// loadLastSuccess load last success time of all jobs from history store
+func loadLastSuccess() {
+	if historyStore == nil {
+		return
+	}
+	for _, job := range activeJobs {
+		history, err := historyStore.FindLastSuccess(context.Background(), job.HandlerName)
+		if err != nil || history == nil {
+			continue
+		}
+		job.markFinished(history)
+	}
+}
+
+func findJob(jobName string) (*Job, error) {
+	for _, job := range activeJobs {
+		if job.HandlerName == jobName {
+			return job, nil
+		}
+	}
+	return nil, ErrJobNotFound
+}
+
+// tick is called when job ticker is activated, return scheduled activation time and false if job is paused (tick received before paused)
+func (job *Job) tick(tickAt time.Time) (fireTime time.Time, ok bool) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if job.paused {
+		return fireTime, false
+	}
+	// interval job use actual time from ticker, because ticker may drop or delay tick so that nextRunAt is not exact
+	fireTime = tickAt.In(job.location)
+	if job.schedule == nil {
+		job.nextRunAt = fireTime.Add(job.currentDuration)
+		return fireTime, true
+	}
+
+	// activation time from schedule is not constant, calculate next tick from scheduled fire time (not from now),
+	// so that tick which fired slightly early is not scheduled again to the same activation time
+	fireTime = job.nextRunAt
+	next := job.schedule.Next(fireTime)
+	if now := time.Now().In(job.location); !next.IsZero() && !next.After(now) {
+		next = job.schedule.Next(now) // activations missed while tick is delayed are skipped
+	}
+	job.nextRunAt = next
+	if next.IsZero() {
+		job.ticker.Stop() // schedule never reach activation time again
+	} else {
+		job.ticker.Reset(time.Until(next))
+	}
+	return fireTime, true
+}
+
+func (job *Job) updateInterval(newInterval string) error {
+	duration, sched, err := parseInterval(newInterval, time.Now().In(job.location))
+	if err != nil {
+		return err
+	}
+	job.Interval = newInterval
+	job.currentDuration = duration
+	job.schedule = sched
+
+	if !job.paused && isWorkerActive {
+		job.resetTicker()
+	}
+	return nil
+}
+
+func (job *Job) resetTicker() {
+	job.ticker.Reset(job.nextTickDuration())
+	job.nextRunAt = job.nextActivationTime()
+}
+
+func (job *Job) status() JobStatus {
+	runMutex.Lock()
+	defer runMutex.Unlock()
+
+	status := JobStatus{
+		HandlerName: job.HandlerName, Interval: job.Interval, Params: job.Params, Timezone: job.Timezone,
+		OverlapPolicy: job.OverlapPolicy, CatchUpMode: job.CatchUpMode, Paused: job.paused, Running: job.running,
+	}
+	if job.Timeout > 0 {
+		status.Timeout = job.Timeout.String()
+	}
+	if !job.nextRunAt.IsZero() {
+		nextRunAt := job.nextRunAt
+		status.NextRunAt = &nextRunAt
+	}
+	if !job.lastRunAt.IsZero() {
+		lastRunAt := job.lastRunAt
+		status.LastRunAt = &lastRunAt
+	}
+	if !job.lastSuccessAt.IsZero() {
+		lastSuccessAt := job.lastSuccessAt
+		status.LastSuccessAt = &lastSuccessAt
+	}
+	status.LastError = job.lastError
+	return status
+}
+
+// nextActivationTime get next activation time from now in job timezone
+func (job *Job) nextActivationTime() time.Time {
+	now := time.Now().In(job.location)
+	if job.schedule == nil {
+		return now.Add(job.currentDuration)
+	}
+	return job.schedule.Next(now)
+}
+
+// nextTickDuration get duration until next activation time, constant duration if job not using schedule
+func (job *Job) nextTickDuration() time.Duration {
+	if job.schedule == nil {
+		return job.currentDuration
+	}
+	return time.Until(job.nextActivationTime())
+}
+
+// loadJobLocation load timezone of job, fallback to CRON_SCHEDULER_TIMEZONE environment and then local timezone
+func loadJobLocation(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		timezone = env.BaseEnv().CronSchedulerTimezone
+	}
+	if timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(timezone)
+}
+
+// startRun check overlap policy when job activated, job marked as running if execution can be started
+func (job *Job) startRun(fireTime time.Time) runState {
+	runMutex.Lock()
+	defer runMutex.Unlock()
+
+	if job.running > 0 {
+		switch job.OverlapPolicy {
+		case candihelper.CronOverlapSkip:
+			return runSkipped
+		case candihelper.CronOverlapQueue:
+			if job.queued {
+				return runSkipped
+			}
+			job.queued, job.queuedFireTime = true, fireTime
+			return runQueued
+		}
+	}
+
+	job.running++
+	return runStart
+}
+
+// markFinished set last result of job execution
+func (job *Job) markFinished(history *RunHistory) {
+	runMutex.Lock()
+	defer runMutex.Unlock()
+
+	switch history.Status {
+	case HistoryStatusSuccess:
+		job.lastSuccessAt, job.lastError = history.StartedAt, ""
+	case HistoryStatusFailure:
+		job.lastError = history.Error
+	}
+}
+
+// markLastRun set last execution start time of job
+func (job *Job) markLastRun(t time.Time) {
+	runMutex.Lock()
+	defer runMutex.Unlock()
+
+	job.lastRunAt = t
+}
+
+// finishRun mark job execution as done, return activation time of queued execution and true if there is queued execution must be run
+func (job *Job) finishRun() (fireTime time.Time, queued bool) {
+	runMutex.Lock()
+	defer runMutex.Unlock()
+
+	if job.queued {
+		job.queued = false
+		return job.queuedFireTime, true
+	}
+	job.running--
+	return fireTime, false
+}
+
+// cancelRun mark job execution as done without run queued execution, used when root context is canceled
+func (job *Job) cancelRun() {
+	runMutex.Lock()
+	defer runMutex.Unlock()
+
+	job.queued = false
+	job.running--
+}
@@ -0,0 +1,170 @@
+package cronworker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golangid/candi/candihelper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobOverlapPolicy(t *testing.T) {
+	fireTime := time.Date(2021, time.May, 14, 2, 0, 0, 0, time.UTC)
+
+	job := &Job{OverlapPolicy: candihelper.CronOverlapAllow}
+	assert.Equal(t, runStart, job.startRun(fireTime))
+	assert.Equal(t, runStart, job.startRun(fireTime))
+	assert.Equal(t, 2, job.running)
+
+	job = &Job{OverlapPolicy: candihelper.CronOverlapSkip}
+	assert.Equal(t, runStart, job.startRun(fireTime))
+	assert.Equal(t, runSkipped, job.startRun(fireTime))
+	_, queued := job.finishRun()
+	assert.False(t, queued)
+	assert.Equal(t, 0, job.running)
+	assert.Equal(t, runStart, job.startRun(fireTime))
+
+	job = &Job{OverlapPolicy: candihelper.CronOverlapQueue}
+	assert.Equal(t, runStart, job.startRun(fireTime))
+	assert.Equal(t, runQueued, job.startRun(fireTime.Add(time.Hour)))
+	assert.Equal(t, runSkipped, job.startRun(fireTime.Add(2*time.Hour)))
+	queuedFireTime, queued := job.finishRun()
+	assert.True(t, queued)
+	assert.Equal(t, fireTime.Add(time.Hour), queuedFireTime)
+	assert.Equal(t, 1, job.running) // queued execution is running
+	_, queued = job.finishRun()
+	assert.False(t, queued)
+	assert.Equal(t, 0, job.running)
+}
+
+func TestJobCanceledRootContext(t *testing.T) {
+	if semaphore == nil {
+		semaphore = make(chan struct{}, 1)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c := &cronWorker{ctx: ctx}
+
+	var executed bool
+	job := &Job{
+		HandlerName: "report", OverlapPolicy: candihelper.CronOverlapQueue, location: time.UTC,
+		HandlerFunc: func(context.Context, []byte) error { executed = true; return nil },
+	}
+	// execution is queued while waiting semaphore
+	semaphore <- struct{}{}
+	started := make(chan bool)
+	go func() { started <- c.runJob(job, time.Time{}) }()
+	for !isRunning(job) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, runQueued, job.startRun(time.Time{}))
+	<-semaphore
+	assert.True(t, <-started)
+	c.wg.Wait()
+	assert.Equal(t, 0, job.running)
+	assert.False(t, job.queued)
+
+	// queued execution is not run and not leaked when root context is canceled
+	assert.Equal(t, runStart, job.startRun(time.Time{}))
+	assert.False(t, c.runJob(job, time.Time{}))
+	c.runQueuedJob(c.ctx, job)
+	assert.Equal(t, 0, job.running)
+	assert.False(t, job.queued)
+	assert.False(t, executed)
+}
+
+func TestJobTimeout(t *testing.T) {
+	c := &cronWorker{ctx: context.Background()}
+	job := &Job{
+		HandlerName: "report", Timeout: 10 * time.Millisecond, location: time.UTC,
+		HandlerFunc: func(ctx context.Context, _ []byte) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	}
+
+	c.processJob(c.ctx, job, time.Time{})
+	assert.Contains(t, job.lastError, ErrJobTimeout.Error())
+	assert.True(t, job.lastSuccessAt.IsZero())
+}
+
+func TestJobTimeoutHandlerIgnoreContext(t *testing.T) {
+	c := &cronWorker{ctx: context.Background()}
+	release := make(chan struct{})
+	defer close(release)
+	job := &Job{
+		HandlerName: "report", Timeout: 10 * time.Millisecond, location: time.UTC,
+		HandlerFunc: func(ctx context.Context, _ []byte) error {
+			<-release
+			return nil
+		},
+	}
+
+	// execution is reported at deadline, not blocked by handler
+	done := make(chan struct{})
+	go func() {
+		c.processJob(c.ctx, job, time.Time{})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("execution is not released at deadline")
+	}
+	assert.Contains(t, job.lastError, ErrJobTimeout.Error())
+}
+
+func TestExecHandlerErrorAfterDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+
+	// handler error caused by timeout context is reported as timeout
+	job := &Job{HandlerName: "report", Timeout: time.Millisecond, HandlerFunc: func(context.Context, []byte) error { return errors.New("failed") }}
+	assert.True(t, errors.Is(execHandler(ctx, job), ErrJobTimeout))
+
+	// handler success is not rewritten to timeout
+	job.HandlerFunc = func(context.Context, []byte) error { return nil }
+	assert.NoError(t, execHandler(context.Background(), job))
+}
+
+func TestJobCanceledLostLock(t *testing.T) {
+	if semaphore == nil {
+		semaphore = make(chan struct{}, 1)
+	}
+	c := &cronWorker{ctx: context.Background()}
+	c.startRunSession()
+
+	started, canceled := make(chan struct{}), make(chan error, 1)
+	job := &Job{
+		HandlerName: "report", location: time.UTC,
+		HandlerFunc: func(ctx context.Context, _ []byte) error {
+			close(started)
+			<-ctx.Done()
+			canceled <- ctx.Err()
+			return ctx.Err()
+		},
+	}
+	assert.True(t, c.runJob(job, time.Time{}))
+	<-started
+
+	// running job is canceled when lock is lost, next session has new context
+	c.cancelRunSession()
+	select {
+	case err := <-canceled:
+		assert.Equal(t, context.Canceled, err)
+	case <-time.After(time.Second):
+		t.Fatal("running job is not canceled when lock is lost")
+	}
+	c.wg.Wait()
+	c.startRunSession()
+	assert.NoError(t, c.runContext().Err())
+}
+
+func isRunning(job *Job) bool {
+	runMutex.Lock()
+	defer runMutex.Unlock()
+	return job.running > 0
+}
@@ -9,84 +9,128 @@ import (
 )
 
 const (
-	oneDay   = 24 * time.Hour
-	oneWeek  = 7 * oneDay
-	oneMonth = 30 * oneDay
-	oneYear  = 12 * oneMonth
-
 	daily   = "daily"
 	weekly  = "weekly"
 	monthly = "monthly"
 	yearly  = "yearly"
 )
 
-// parseAtTime with input format HH:mm:ss, will repeat every day (24 hours) in the same time
-func parseAtTime(t string) (duration, nextDuration time.Duration, err error) {
+// parseInterval parse job interval, return constant duration for standard time duration string (and "@every <duration>"),
+// or schedule for cron expression and custom start time
+func parseInterval(interval string, now time.Time) (duration time.Duration, sched schedule, err error) {
+	if duration, err = time.ParseDuration(interval); err == nil {
+		return duration, nil, nil
+	}
+
+	if strings.HasPrefix(interval, "@every ") {
+		duration, err = time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(interval, "@every ")))
+		return duration, nil, err
+	}
+
+	if isCronExpression(interval) {
+		sched, err = parseCronExpression(interval)
+	} else {
+		sched, err = parseAtTime(interval, now)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if sched.Next(now).IsZero() {
+		return 0, nil, fmt.Errorf(`interval "%s" never reach activation time`, interval)
+	}
+	return 0, sched, nil
+}
+
+// parseAtTime with input format HH:mm[:ss]@descriptor, will repeat every day (24 hours) in the same time if descriptor is empty
+func parseAtTime(t string, now time.Time) (schedule, error) {
 
 	withDescriptors := strings.Split(t, "@")
 
 	ts := strings.Split(withDescriptors[0], ":")
 	if len(ts) < 2 || len(ts) > 3 {
-		return 0, 0, errors.New("time format error")
+		return nil, errors.New("time format error")
 	}
 
-	var hour, min, sec int
-	hour, err = strconv.Atoi(ts[0])
+	hour, err := strconv.Atoi(ts[0])
 	if err != nil {
-		return
+		return nil, err
 	}
 
-	min, err = strconv.Atoi(ts[1])
+	min, err := strconv.Atoi(ts[1])
 	if err != nil {
-		return
+		return nil, err
 	}
 
+	var sec int
 	if len(ts) == 3 {
 		if sec, err = strconv.Atoi(ts[2]); err != nil {
-			return
+			return nil, err
 		}
 	}
 
 	if hour < 0 || hour > 23 || min < 0 || min > 59 || sec < 0 || sec > 59 {
-		return 0, 0, errors.New("time format error")
+		return nil, errors.New("time format error")
 	}
 
 	// default value
-	repeatDuration := oneDay
-
+	descriptor := daily
 	if len(withDescriptors) > 1 {
-
-		switch withDescriptors[1] {
-		case daily:
-			repeatDuration = oneDay
-		case weekly:
-			repeatDuration = oneWeek
-		case monthly:
-			repeatDuration = oneMonth
-		case yearly:
-			repeatDuration = oneYear
-		default:
-			repeatDuration, err = time.ParseDuration(withDescriptors[1])
-			if err != nil {
-				return 0, 0, fmt.Errorf(`invalid descriptor "%s" (must one of "daily", "weekly", "monthly", "yearly") or duration string`,
-					withDescriptors[1])
-			}
-		}
+		descriptor = withDescriptors[1]
 	}
 
-	now := time.Now()
-	atTime := time.Date(now.Year(), now.Month(), now.Day(), hour, min, sec, 0, now.Location())
-	if now.Before(atTime) {
-		duration = atTime.Sub(now)
-	} else {
-		duration = repeatDuration - now.Sub(atTime)
+	// daily, weekly, monthly and yearly descriptor follow calendar (start from current day) instead of constant duration
+	atTime := fmt.Sprintf("%d %d %d", sec, min, hour)
+	switch descriptor {
+	case daily:
+		return parseCronExpression(atTime + " * * *")
+	case weekly:
+		return parseCronExpression(fmt.Sprintf("%s * * %d", atTime, now.Weekday()))
+	case monthly:
+		return &monthlySchedule{day: now.Day(), hour: hour, min: min, sec: sec}, nil
+	case yearly:
+		return &monthlySchedule{month: now.Month(), day: now.Day(), hour: hour, min: min, sec: sec}, nil
 	}
 
-	if duration < 0 {
-		duration *= -1
+	repeatDuration, err := time.ParseDuration(descriptor)
+	if err != nil || repeatDuration <= 0 {
+		return nil, fmt.Errorf(`invalid descriptor "%s" (must one of "daily", "weekly", "monthly", "yearly") or duration string`,
+			descriptor)
 	}
 
-	nextDuration = repeatDuration
+	return &atTimeSchedule{
+		start:  time.Date(now.Year(), now.Month(), now.Day(), hour, min, sec, 0, now.Location()),
+		repeat: repeatDuration,
+	}, nil
+}
+
+// monthlySchedule repeat every month (or every year if month is set) at the same day of month and time.
+// Day is clamped to the last day of short month, example: day 31 run on 30 April and 28 (or 29) February
+type monthlySchedule struct {
+	month               time.Month // zero for every month
+	day, hour, min, sec int
+}
 
-	return
+// Next implement schedule
+func (s *monthlySchedule) Next(t time.Time) time.Time {
+	year, month := t.Year(), t.Month()
+	if s.month != 0 {
+		month = s.month
+	}
+	for {
+		lastDay := time.Date(year, month+1, 0, 0, 0, 0, 0, t.Location()).Day()
+		day := s.day
+		if day > lastDay {
+			day = lastDay
+		}
+		if next := time.Date(year, month, day, s.hour, s.min, s.sec, 0, t.Location()); next.After(t) {
+			return next
+		}
+
+		if s.month != 0 {
+			year++
+		} else if month++; month > time.December {
+			month, year = time.January, year+1
+		}
+	}
 }
@@ -0,0 +1,167 @@
+package kafkaworker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/golangid/candi/codebase/factory/types"
+	"github.com/golangid/candi/config/env"
+	"github.com/golangid/candi/logger"
+	"github.com/golangid/candi/tracer"
+)
+
+const (
+	defaultBatchSize = 100
+	defaultBatchWait = time.Second
+)
+
+// consumeBatchClaim collect messages of claim until batch size or batch wait time since first message in batch,
+// offsets of batch are marked after batch handler success (or failed messages have been republished)
+func (c *consumerHandler) consumeBatchClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim, handler topicHandler) error {
+	batch := make([]*sarama.ConsumerMessage, 0, handler.batchSize)
+	timer := time.NewTimer(handler.batchWait)
+	stopTimer(timer)
+	defer timer.Stop()
+
+	flush := func() bool {
+		stopTimer(timer)
+		if len(batch) == 0 {
+			return true
+		}
+		ok := c.consumeBatch(session, handler, batch)
+		batch = make([]*sarama.ConsumerMessage, 0, handler.batchSize)
+		return ok
+	}
+
+	for {
+		select {
+		case message, ok := <-claim.Messages():
+			if !ok {
+				flush()
+				return nil
+			}
+
+			batch = append(batch, message)
+			if len(batch) == 1 {
+				timer.Reset(handler.batchWait)
+			}
+			if len(batch) >= handler.batchSize && !flush() {
+				return nil
+			}
+
+		case <-timer.C:
+			if !flush() {
+				return nil
+			}
+
+		case <-session.Context().Done():
+			return nil // unprocessed batch will be consumed again in next session
+
+		}
+	}
+}
+
+// consumeBatch process batch and mark the offsets, return false if session is closed before batch is marked
+func (c *consumerHandler) consumeBatch(session sarama.ConsumerGroupSession, handler topicHandler, messages []*sarama.ConsumerMessage) bool {
+	// messages in retry topic is ordered by retry time, wait until retry time of last message
+	if _, attempt, retryAt := retryMetadata(messages[len(messages)-1]); attempt > 0 && !waitRetryTime(session.Context(), retryAt) {
+		return false
+	}
+
+	var processed int
+	if !c.processWithBackoff(session.Context(), func() bool {
+		processed++
+		republished, ok := c.processBatch(session.Context(), handler, messages, c.canRepublish(processed))
+		// mark republished messages, so that only remaining messages are processed again and not republished twice
+		for _, message := range messages[:republished] {
+			c.markMessage(session, message)
+		}
+		messages = messages[republished:]
+		return ok
+	}) {
+		return false
+	}
+	for _, message := range messages {
+		c.markMessage(session, message)
+	}
+	return true
+}
+
+// processBatch return true if batch handler success or all messages in failed batch have been republished to retry or dead letter topic,
+// republished is total messages from start of batch which have been republished when republish is failed in the middle of batch.
+// Failed batch is not republished if republish is false
+func (c *consumerHandler) processBatch(sessionCtx context.Context, handler topicHandler, messages []*sarama.ConsumerMessage, republish bool) (republished int, ok bool) {
+	trace, ctx := tracer.StartTraceWithContext(sessionCtx, "KafkaConsumerBatch")
+	defer func() {
+		logger.LogGreen("kafka_consumer > trace_url: " + tracer.GetTraceURL(ctx))
+		trace.Finish()
+	}()
+
+	first, last := messages[0], messages[len(messages)-1]
+	if env.BaseEnv().DebugMode {
+		log.Printf("\x1b[35;3mKafka Consumer: batch consumed, size = %d, topic = %s\x1b[0m", len(messages), first.Topic)
+	}
+
+	trace.SetTag("topic", first.Topic)
+	trace.SetTag("partition", first.Partition)
+	trace.SetTag("batch_size", len(messages))
+	trace.SetTag("first_offset", first.Offset)
+	trace.SetTag("last_offset", last.Offset)
+
+	values := make([][]byte, len(messages))
+	for i, message := range messages {
+		values[i] = message.Value
+	}
+	handlerErr := execBatchHandler(ctx, handler.batchHandlerFunc, values)
+	if handlerErr == nil {
+		return 0, true
+	}
+
+	originalTopic, _, _ := retryMetadata(first)
+	for _, message := range messages {
+		for _, errHandler := range handler.errorHandlers {
+			errHandler(ctx, types.Kafka, originalTopic, message.Value, handlerErr)
+		}
+	}
+	trace.SetError(handlerErr)
+
+	if !c.retryPolicy.enabled() || !republish {
+		return 0, false
+	}
+	for i, message := range messages {
+		originalTopic, attempt, _ := retryMetadata(message)
+		destination, err := c.republishFailedMessage(message, originalTopic, attempt, handlerErr)
+		if err != nil {
+			logger.LogRed(fmt.Sprintf("kafka_consumer > failed republish message to %s: %v", destination, err))
+			trace.Log("republish_error", err.Error())
+			return i, false
+		}
+		if destination == "" {
+			return i, false
+		}
+	}
+	return len(messages), true
+}
+
+// execBatchHandler call batch handler func, panic in handler is returned as error
+func execBatchHandler(ctx context.Context, handlerFunc types.WorkerBatchHandlerFunc, messages [][]byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handlerFunc(ctx, messages)
+}
+
+// stopTimer stop timer and drain the channel, so that timer can be reset safely
+func stopTimer(timer *time.Timer) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+}
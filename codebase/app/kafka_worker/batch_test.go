@@ -0,0 +1,69 @@
+package kafkaworker
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSession record marked offsets
+type fakeSession struct {
+	sarama.ConsumerGroupSession
+	ctx    context.Context
+	marked []int64
+}
+
+func (s *fakeSession) Context() context.Context { return s.ctx }
+func (s *fakeSession) Commit()                  {}
+func (s *fakeSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.marked = append(s.marked, msg.Offset)
+}
+
+// flakyProducer failed send message at given call number
+type flakyProducer struct {
+	sarama.SyncProducer
+	calls  int
+	failAt int
+	sent   []int64
+}
+
+func (p *flakyProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	if p.calls++; p.calls == p.failAt {
+		return 0, 0, errors.New("leader not available")
+	}
+	for _, header := range msg.Headers {
+		if string(header.Key) == HeaderOriginalOffset {
+			offset, _ := strconv.ParseInt(string(header.Value), 10, 64)
+			p.sent = append(p.sent, offset)
+		}
+	}
+	return 0, 0, nil
+}
+
+func TestConsumeBatchPartialRepublish(t *testing.T) {
+	producer := &flakyProducer{failAt: 2}
+	handler := &consumerHandler{
+		producer:     producer,
+		retryPolicy:  RetryPolicy{Delays: []time.Duration{time.Minute}},
+		commitPolicy: &CommitPolicy{},
+	}
+	session := &fakeSession{ctx: context.Background()}
+	messages := []*sarama.ConsumerMessage{
+		{Topic: "orders", Offset: 0}, {Topic: "orders", Offset: 1}, {Topic: "orders", Offset: 2},
+	}
+	var handled [][]byte
+	topicHandler := topicHandler{batchHandlerFunc: func(ctx context.Context, values [][]byte) error {
+		handled = append(handled, values...)
+		return errors.New("failed")
+	}}
+
+	assert.True(t, handler.consumeBatch(session, topicHandler, messages))
+	assert.Equal(t, []int64{0, 1, 2}, producer.sent, "republished message is not republished again")
+	assert.Equal(t, []int64{0, 1, 2}, session.marked)
+	assert.Len(t, handled, 5, "only remaining messages are processed again")
+}
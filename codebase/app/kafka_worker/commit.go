@@ -0,0 +1,84 @@
+package kafkaworker
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+const (
+	minProcessBackoff = time.Second
+	maxProcessBackoff = time.Minute
+)
+
+// CommitPolicy offset commit strategy in at-least-once mode, marked offsets are committed
+// every interval or after total marked messages reach batch size (whichever comes first)
+type CommitPolicy struct {
+	// Interval commit interval, default is 1 second
+	Interval time.Duration
+	// BatchSize commit after total marked messages reach batch size, zero for only commit by interval
+	BatchSize int
+	// MaxAttempts failed message is processed again with backoff until processed MaxAttempts times, then published to
+	// retry or dead letter topic. Require RetryPolicy with DeadLetter (worker panic) so that failed message is never dropped,
+	// zero for republish on first failure
+	MaxAttempts int
+}
+
+// markMessage mark offset of processed message, commit marked offsets if total marked messages reach batch size
+func (c *consumerHandler) markMessage(session sarama.ConsumerGroupSession, message *sarama.ConsumerMessage) {
+	session.MarkMessage(message, "")
+	if c.commitPolicy == nil || c.commitPolicy.BatchSize <= 0 {
+		return
+	}
+	if atomic.AddInt64(&c.totalMarked, 1)%int64(c.commitPolicy.BatchSize) == 0 {
+		session.Commit()
+	}
+}
+
+// commitPeriodically commit marked offsets every interval until session is closed
+func (c *consumerHandler) commitPeriodically(session sarama.ConsumerGroupSession) {
+	interval := c.commitPolicy.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			session.Commit()
+		case <-session.Context().Done():
+			return
+		}
+	}
+}
+
+// processWithBackoff in at-least-once mode, call process again with backoff until return true.
+// Return false if context is done before process success, so that offset is not marked
+func (c *consumerHandler) processWithBackoff(ctx context.Context, process func() bool) bool {
+	backoff := minProcessBackoff
+	for !process() {
+		if ctx.Err() != nil {
+			return false
+		}
+		if c.commitPolicy == nil {
+			return true
+		}
+		if !waitRetryTime(ctx, time.Now().Add(backoff)) {
+			return false
+		}
+		if backoff *= 2; backoff > maxProcessBackoff {
+			backoff = maxProcessBackoff
+		}
+	}
+	return true
+}
+
+// canRepublish check failed message which processed total times can be republished to retry or dead letter topic,
+// in at-least-once mode with MaxAttempts failed message is processed again until MaxAttempts
+func (c *consumerHandler) canRepublish(processed int) bool {
+	return c.commitPolicy == nil || c.commitPolicy.MaxAttempts <= 0 || processed >= c.commitPolicy.MaxAttempts
+}
@@ -0,0 +1,51 @@
+package kafkaworker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessWithBackoff(t *testing.T) {
+	handler := &consumerHandler{commitPolicy: &CommitPolicy{}}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var attempts int
+	assert.False(t, handler.processWithBackoff(ctx, func() bool {
+		attempts++
+		return false
+	}), "failed message is processed again until context is done")
+	assert.Equal(t, 1, attempts)
+}
+
+func TestMaxAttemptsRepublish(t *testing.T) {
+	producer := &fakeSyncProducer{}
+	handler := &consumerHandler{
+		producer:     producer,
+		retryPolicy:  RetryPolicy{DeadLetter: true},
+		commitPolicy: &CommitPolicy{MaxAttempts: 3},
+		handlerFuncs: map[string]topicHandler{"orders": {handlerFunc: func(ctx context.Context, message []byte) error {
+			return errors.New("failed")
+		}}},
+	}
+	assert.False(t, handler.canRepublish(1))
+	assert.False(t, handler.canRepublish(2))
+	assert.True(t, handler.canRepublish(3), "failed message is published to dead letter topic after max attempts")
+
+	message := &sarama.ConsumerMessage{Topic: "orders", Value: []byte("value")}
+	assert.False(t, handler.processMessage(context.Background(), message, "orders", 0, handler.canRepublish(1)))
+	assert.Empty(t, producer.messages, "failed message is processed again before max attempts")
+	assert.True(t, handler.processMessage(context.Background(), message, "orders", 0, handler.canRepublish(3)))
+	if assert.Len(t, producer.messages, 1) {
+		assert.Equal(t, "orders.dlq", producer.messages[0].Topic)
+	}
+
+	handler.commitPolicy.MaxAttempts = 0
+	assert.True(t, handler.canRepublish(1), "republish on first failure")
+	handler.commitPolicy = nil
+	assert.True(t, handler.canRepublish(1))
+}
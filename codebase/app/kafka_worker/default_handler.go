@@ -1,12 +1,15 @@
 package kafkaworker
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/Shopify/sarama"
 	"github.com/golangid/candi/candishared"
 	"github.com/golangid/candi/codebase/factory/types"
+	"github.com/golangid/candi/config/broker"
 	"github.com/golangid/candi/config/env"
 	"github.com/golangid/candi/logger"
 	"github.com/golangid/candi/tracer"
@@ -15,32 +18,62 @@ import (
 // consumerHandler represents a Sarama consumer group consumer
 type consumerHandler struct {
 	topics       []string
-	handlerFuncs map[string]struct { // mapping topic to handler func in delivery layer
-		handlerFunc   types.WorkerHandlerFunc
-		errorHandlers []types.WorkerErrorHandler
-	}
-	ready chan struct{}
+	handlerFuncs map[string]topicHandler // mapping topic to handler func in delivery layer
+	ready        chan struct{}
+	retryPolicy  RetryPolicy
+	producer     sarama.SyncProducer
+	commitPolicy *CommitPolicy
+	totalMarked  int64
+	concurrency  int
+}
+
+type topicHandler struct {
+	handlerFunc      types.WorkerHandlerFunc
+	batchHandlerFunc types.WorkerBatchHandlerFunc
+	batchSize        int
+	batchWait        time.Duration
+	errorHandlers    []types.WorkerErrorHandler
 }
 
 // Setup is run at the beginning of a new session, before ConsumeClaim
-func (c *consumerHandler) Setup(sarama.ConsumerGroupSession) error {
+func (c *consumerHandler) Setup(session sarama.ConsumerGroupSession) error {
+	if c.commitPolicy != nil {
+		go c.commitPeriodically(session)
+	}
+	broker.SetKafkaConsumerAssignment(session.Claims())
 	close(c.ready)
 	return nil
 }
 
 // Cleanup is run at the end of a session, once all ConsumeClaim goroutines have exited
-func (c *consumerHandler) Cleanup(sarama.ConsumerGroupSession) error {
+func (c *consumerHandler) Cleanup(session sarama.ConsumerGroupSession) error {
+	broker.SetKafkaConsumerAssignment(nil)
+	if c.commitPolicy != nil {
+		session.Commit()
+	}
 	return nil
 }
 
 // ConsumeClaim must start a consumer loop of ConsumerGroupClaim's Messages().
 func (c *consumerHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	if handler := c.handlerFuncs[claim.Topic()]; handler.batchHandlerFunc != nil {
+		return c.consumeBatchClaim(session, claim, handler)
+	}
+	if c.concurrency > 1 {
+		return c.consumeParallelClaim(session, claim)
+	}
 
 	for {
 		select {
-		case message := <-claim.Messages():
+		case message, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
 
-			c.processMessage(session, message)
+			if !c.consumeMessage(session, message) {
+				return nil
+			}
+			c.markMessage(session, message)
 
 		case <-session.Context().Done():
 			return nil
@@ -49,13 +82,28 @@ func (c *consumerHandler) ConsumeClaim(session sarama.ConsumerGroupSession, clai
 	}
 }
 
-func (c *consumerHandler) processMessage(session sarama.ConsumerGroupSession, message *sarama.ConsumerMessage) {
-	trace, ctx := tracer.StartTraceWithContext(session.Context(), "KafkaConsumer")
+// consumeMessage process message until the offset can be marked, return false if session is closed before that.
+// In at-least-once mode, failed message which not republished to retry or dead letter topic is processed again with backoff
+// (republished after processed MaxAttempts times)
+func (c *consumerHandler) consumeMessage(session sarama.ConsumerGroupSession, message *sarama.ConsumerMessage) bool {
+	originalTopic, attempt, retryAt := retryMetadata(message)
+	if attempt > 0 && !waitRetryTime(session.Context(), retryAt) {
+		return false // session is closed, message will be consumed again in next session
+	}
+
+	var processed int
+	return c.processWithBackoff(session.Context(), func() bool {
+		processed++
+		return c.processMessage(session.Context(), message, originalTopic, attempt, c.canRepublish(processed))
+	})
+}
+
+// processMessage return true if handler success or failed message has been republished to retry or dead letter topic,
+// failed message is not republished if republish is false
+func (c *consumerHandler) processMessage(sessionCtx context.Context, message *sarama.ConsumerMessage, originalTopic string, attempt int, republish bool) bool {
+	header := messageHeader(message)
+	trace, ctx := tracer.StartTraceFromHeader(sessionCtx, "KafkaConsumer", header)
 	defer func() {
-		if r := recover(); r != nil {
-			trace.SetError(fmt.Errorf("%v", r))
-		}
-		session.MarkMessage(message, "")
 		logger.LogGreen("kafka_consumer > trace_url: " + tracer.GetTraceURL(ctx))
 		trace.Finish()
 	}()
@@ -68,14 +116,58 @@ func (c *consumerHandler) processMessage(session sarama.ConsumerGroupSession, me
 	trace.SetTag("key", string(message.Key))
 	trace.SetTag("partition", message.Partition)
 	trace.SetTag("offset", message.Offset)
+	if attempt > 0 {
+		trace.SetTag("original_topic", originalTopic)
+		trace.SetTag("retry_attempt", attempt)
+	}
+	trace.Log("header", header)
 	trace.Log("message", message.Value)
 
 	ctx = candishared.SetToContext(ctx, candishared.ContextKeyWorkerKey, message.Key)
+	ctx = candishared.SetToContext(ctx, candishared.ContextKeyWorkerHeader, header)
 	handler := c.handlerFuncs[message.Topic]
-	if err := handler.handlerFunc(ctx, message.Value); err != nil {
-		for _, errHandler := range handler.errorHandlers {
-			errHandler(ctx, types.Kafka, message.Topic, message.Value, err)
+	err := execHandler(ctx, handler.handlerFunc, message.Value)
+	if err == nil {
+		return true
+	}
+
+	for _, errHandler := range handler.errorHandlers {
+		errHandler(ctx, types.Kafka, originalTopic, message.Value, err)
+	}
+	trace.SetError(err)
+
+	if !c.retryPolicy.enabled() || !republish {
+		return false
+	}
+	destination, err := c.republishWithBackoff(sessionCtx, message, originalTopic, attempt, err)
+	if err != nil {
+		// session is closed before republished, message will be consumed again in next session
+		trace.Log("republish_error", err.Error())
+		return false
+	}
+	if destination == "" {
+		return false
+	}
+	trace.SetTag("republish_topic", destination)
+	return true
+}
+
+// execHandler call handler func, panic in handler is returned as error
+func execHandler(ctx context.Context, handlerFunc types.WorkerHandlerFunc, message []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handlerFunc(ctx, message)
+}
+
+func messageHeader(message *sarama.ConsumerMessage) map[string]string {
+	header := make(map[string]string, len(message.Headers))
+	for _, h := range message.Headers {
+		if h != nil {
+			header[string(h.Key)] = string(h.Value)
 		}
-		trace.SetError(err)
 	}
+	return header
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -11,6 +12,7 @@ import (
 	"github.com/Shopify/sarama"
 	"github.com/golangid/candi/codebase/factory"
 	"github.com/golangid/candi/codebase/factory/types"
+	"github.com/golangid/candi/config/broker"
 	"github.com/golangid/candi/config/env"
 	"github.com/golangid/candi/logger"
 )
@@ -18,69 +20,227 @@ import (
 type kafkaWorker struct {
 	engine          sarama.ConsumerGroup
 	service         factory.ServiceFactory
+	client          sarama.Client
 	consumerHandler *consumerHandler
+	subscription    *topicSubscription
 	cancelFunc      func()
 }
 
+// OptionFunc type
+type OptionFunc func(*consumerHandler)
+
+// SetRetryPolicy option func, set retry strategy for failed message (default from KAFKA_RETRY_DELAYS and KAFKA_DEAD_LETTER environment)
+func SetRetryPolicy(policy RetryPolicy) OptionFunc {
+	return func(c *consumerHandler) {
+		c.retryPolicy = policy
+	}
+}
+
+// SetAtLeastOnce option func, activate at-least-once mode with commit policy (default from KAFKA_AT_LEAST_ONCE,
+// KAFKA_COMMIT_INTERVAL, KAFKA_COMMIT_BATCH_SIZE and KAFKA_MAX_ATTEMPTS environment). Offset is marked only after handler success
+// or failed message has been republished to retry/dead letter topic, otherwise message is processed again with backoff.
+// With MaxAttempts, failed message is processed again until MaxAttempts before republished (require dead letter topic, worker panic if not set).
+// Require auto commit disabled in sarama config (Consumer.Offsets.AutoCommit.Enable), set KAFKA_AT_LEAST_ONCE=true
+// or disable in custom config, worker panic if auto commit is enabled
+func SetAtLeastOnce(policy CommitPolicy) OptionFunc {
+	return func(c *consumerHandler) {
+		c.commitPolicy = &policy
+	}
+}
+
+// SetConcurrency option func, process messages of each partition concurrently in total worker goroutines
+// (default from KAFKA_CONSUMER_CONCURRENCY environment). Messages are sharded by key to worker,
+// so messages with the same key are still processed in order
+func SetConcurrency(concurrency int) OptionFunc {
+	return func(c *consumerHandler) {
+		c.concurrency = concurrency
+	}
+}
+
 // NewWorker create new kafka consumer
-func NewWorker(service factory.ServiceFactory) factory.AppServerFactory {
+func NewWorker(service factory.ServiceFactory, opts ...OptionFunc) factory.AppServerFactory {
 	// init kafka consumer
 	if service.GetDependency().GetBroker().GetConfiguration(types.Kafka) == nil {
 		log.Panic("Missing kafka configuration")
 	}
-	consumerEngine, err := sarama.NewConsumerGroupFromClient(
-		env.BaseEnv().Kafka.ConsumerGroup,
-		service.GetDependency().GetBroker().GetConfiguration(types.Kafka).(sarama.Client),
-	)
+	client := service.GetDependency().GetBroker().GetConfiguration(types.Kafka).(sarama.Client)
+
+	var consumerHandler consumerHandler
+	consumerHandler.concurrency = env.BaseEnv().Kafka.Concurrency
+	consumerHandler.retryPolicy = RetryPolicy{
+		Delays: env.BaseEnv().Kafka.RetryDelays, DeadLetter: env.BaseEnv().Kafka.DeadLetter,
+	}
+	if env.BaseEnv().Kafka.AtLeastOnce {
+		consumerHandler.commitPolicy = &CommitPolicy{
+			Interval: env.BaseEnv().Kafka.CommitInterval, BatchSize: env.BaseEnv().Kafka.CommitBatchSize,
+			MaxAttempts: env.BaseEnv().Kafka.MaxAttempts,
+		}
+	}
+	for _, opt := range opts {
+		opt(&consumerHandler)
+	}
+	if consumerHandler.commitPolicy != nil && client.Config().Consumer.Offsets.AutoCommit.Enable {
+		// marked offset of message which not processed yet may be committed by auto commit
+		log.Panic("Kafka: at-least-once mode require auto commit disabled, set KAFKA_AT_LEAST_ONCE=true " +
+			"or Consumer.Offsets.AutoCommit.Enable=false in custom sarama config")
+	}
+	if consumerHandler.commitPolicy != nil && consumerHandler.commitPolicy.MaxAttempts > 0 && !consumerHandler.retryPolicy.DeadLetter {
+		// failed message must be published to dead letter topic after max attempts, instead of dropped
+		log.Panic("Kafka: max attempts in at-least-once mode require dead letter topic, set KAFKA_DEAD_LETTER=true " +
+			"or RetryPolicy.DeadLetter in option")
+	}
+	consumerEngine, err := sarama.NewConsumerGroupFromClient(env.BaseEnv().Kafka.ConsumerGroup, client)
 	if err != nil {
 		log.Panicf("Error creating kafka consumer group client: %v", err)
 	}
+	if consumerHandler.retryPolicy.enabled() {
+		consumerHandler.producer, err = sarama.NewSyncProducerFromClient(client)
+		if err != nil {
+			log.Panicf("Error creating kafka producer for retry topic: %v", err)
+		}
+	}
 
-	var consumerHandler consumerHandler
-	consumerHandler.handlerFuncs = make(map[string]struct {
-		handlerFunc   types.WorkerHandlerFunc
-		errorHandlers []types.WorkerErrorHandler
-	})
+	worker := &kafkaWorker{
+		engine:          consumerEngine,
+		service:         service,
+		client:          client,
+		consumerHandler: &consumerHandler,
+		subscription:    newTopicSubscription(),
+	}
+	worker.subscription.retryPolicy = consumerHandler.retryPolicy
+	consumerHandler.handlerFuncs = make(map[string]topicHandler)
 	for _, m := range service.GetModules() {
 		if h := m.WorkerHandler(types.Kafka); h != nil {
 			var handlerGroup types.WorkerHandlerGroup
 			h.MountHandlers(&handlerGroup)
 			for _, handler := range handlerGroup.Handlers {
+				handlerFunc := topicHandler{
+					handlerFunc: handler.HandlerFunc, errorHandlers: handler.ErrorHandler,
+					batchHandlerFunc: handler.BatchHandlerFunc, batchSize: handler.BatchSize, batchWait: handler.BatchWait,
+				}
+				if handlerFunc.batchSize <= 0 {
+					handlerFunc.batchSize = defaultBatchSize
+				}
+				if handlerFunc.batchWait <= 0 {
+					handlerFunc.batchWait = defaultBatchWait
+				}
+
+				if isTopicPattern(handler.Pattern) {
+					if err := worker.subscription.addPattern(handler.Pattern, handlerFunc, string(m.Name())); err != nil {
+						log.Panicf("Kafka: invalid topic pattern %s: %v", handler.Pattern, err)
+					}
+					logger.LogYellow(fmt.Sprintf(`[KAFKA-CONSUMER] (pattern): %-13s  --> (module): "%s"`, `"`+handler.Pattern+`"`, m.Name()))
+					continue
+				}
 				if _, ok := consumerHandler.handlerFuncs[handler.Pattern]; ok {
 					logger.LogYellow(fmt.Sprintf("Kafka: warning, topic %s has been used in another module, overwrite handler func", handler.Pattern))
 				}
-				consumerHandler.handlerFuncs[handler.Pattern] = struct {
-					handlerFunc   types.WorkerHandlerFunc
-					errorHandlers []types.WorkerErrorHandler
-				}{
-					handlerFunc: handler.HandlerFunc, errorHandlers: handler.ErrorHandler,
-				}
-				consumerHandler.topics = append(consumerHandler.topics, handler.Pattern)
-				logger.LogYellow(fmt.Sprintf(`[KAFKA-CONSUMER] (topic): %-15s  --> (module): "%s"`, `"`+handler.Pattern+`"`, m.Name()))
+				consumerHandler.registerTopic(handler.Pattern, handlerFunc, string(m.Name()))
+				worker.subscription.subscribed[handler.Pattern] = true
 			}
 		}
 	}
+	if len(worker.subscription.patterns) > 0 || consumerHandler.retryPolicy.enabled() {
+		topics, err := client.Topics()
+		if err != nil {
+			log.Panicf("Error getting kafka topics: %v", err)
+		}
+		worker.checkRetryTopics(topics)
+		worker.subscription.discover(topics)
+		worker.subscribePendingTopics()
+	}
+	broker.RegisterKafkaConsumer(env.BaseEnv().Kafka.ConsumerGroup, consumerHandler.topics)
 	fmt.Printf("\x1b[34;1m⇨ Kafka consumer running with %d topics. Brokers: "+strings.Join(env.BaseEnv().Kafka.Brokers, ", ")+"\x1b[0m\n\n",
 		len(consumerHandler.topics))
 
 	consumerHandler.ready = make(chan struct{})
-	return &kafkaWorker{
-		engine:          consumerEngine,
-		service:         service,
-		consumerHandler: &consumerHandler,
+	return worker
+}
+
+// registerTopic register handler for topic and retry topics of the topic
+func (c *consumerHandler) registerTopic(topic string, handler topicHandler, module string) {
+	c.handlerFuncs[topic] = handler
+	c.topics = append(c.topics, topic)
+	logger.LogYellow(fmt.Sprintf(`[KAFKA-CONSUMER] (topic): %-15s  --> (module): "%s"`, `"`+topic+`"`, module))
+
+	// consume retry topics with the same handler
+	for attempt := 1; attempt <= len(c.retryPolicy.Delays); attempt++ {
+		retryTopic := RetryTopic(topic, attempt)
+		c.handlerFuncs[retryTopic] = handler
+		c.topics = append(c.topics, retryTopic)
+		logger.LogYellow(fmt.Sprintf(`[KAFKA-CONSUMER] (topic): %-15s  --> (module): "%s"`, `"`+retryTopic+`"`, module))
+	}
+}
+
+// checkRetryTopics panic if retry or dead letter topics of registered topics are not exist, because failed message
+// cannot be republished and block the partition
+func (h *kafkaWorker) checkRetryTopics(topics []string) {
+	existing := make(map[string]bool, len(topics))
+	for _, topic := range topics {
+		existing[topic] = true
 	}
+
+	var missing []string
+	for topic := range h.subscription.subscribed {
+		missing = append(missing, h.consumerHandler.retryPolicy.missingTopics(topic, existing)...)
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		log.Panicf("Kafka: retry topics are not exist: %s (create retry and dead letter topics before start consumer)",
+			strings.Join(missing, ", "))
+	}
+}
+
+// subscribePendingTopics register new topics matching pattern, must be called when not in consumer group session
+func (h *kafkaWorker) subscribePendingTopics() {
+	pending := h.subscription.takePending()
+	if len(pending) == 0 {
+		return
+	}
+	for topic, pattern := range pending {
+		h.consumerHandler.registerTopic(topic, pattern.handler, pattern.module)
+	}
+	broker.RegisterKafkaConsumer(env.BaseEnv().Kafka.ConsumerGroup, h.consumerHandler.topics)
 }
 
 func (h *kafkaWorker) Serve() {
 	ctx, cancel := context.WithCancel(context.Background())
 	h.cancelFunc = cancel
 
+	if len(h.subscription.patterns) > 0 {
+		go h.refreshTopics(ctx, h.client, env.BaseEnv().Kafka.TopicRefreshInterval)
+	}
+
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
 		for {
-			if err := h.engine.Consume(ctx, h.consumerHandler.topics, h.consumerHandler); err != nil {
+			h.subscribePendingTopics()
+			if len(h.consumerHandler.topics) == 0 {
+				// wait until topic matching pattern is created
+				select {
+				case <-ctx.Done():
+					return
+				case <-h.subscription.changed:
+					continue
+				}
+			}
+
+			// end current session when new topics matching pattern are found, so that consumer group is resubscribed
+			sessionCtx, cancelSession := context.WithCancel(ctx)
+			go func() {
+				select {
+				case <-h.subscription.changed:
+					logger.LogYellow("Kafka: found new topics matching pattern, resubscribe consumer group")
+					cancelSession()
+				case <-sessionCtx.Done():
+				}
+			}()
+
+			err := h.engine.Consume(sessionCtx, h.consumerHandler.topics, h.consumerHandler)
+			cancelSession()
+			if err != nil {
 				logger.LogRed("Error from kafka consumer: " + err.Error())
 				if errCode, ok := err.(sarama.KError); ok {
 					switch errCode {
@@ -98,7 +258,6 @@ func (h *kafkaWorker) Serve() {
 		}
 	}()
 
-	<-h.consumerHandler.ready
 	wg.Wait()
 }
 
@@ -108,6 +267,9 @@ func (h *kafkaWorker) Shutdown(ctx context.Context) {
 
 	h.cancelFunc()
 	h.engine.Close()
+	if h.consumerHandler.producer != nil {
+		h.consumerHandler.producer.Close()
+	}
 }
 
 func (h *kafkaWorker) Name() string {
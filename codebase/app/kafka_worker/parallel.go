@@ -0,0 +1,103 @@
+package kafkaworker
+
+import (
+	"hash/fnv"
+	"sync"
+
+	"github.com/Shopify/sarama"
+)
+
+// consumeParallelClaim process messages of claim concurrently in worker goroutines (total is concurrency).
+// Messages are sharded by key, so messages with the same key are processed in order by the same worker.
+// Offset is marked only up to the lowest unfinished message
+func (c *consumerHandler) consumeParallelClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	var (
+		wg      sync.WaitGroup
+		tracker offsetTracker
+		shards  = make([]chan *sarama.ConsumerMessage, c.concurrency)
+	)
+	for i := range shards {
+		shards[i] = make(chan *sarama.ConsumerMessage, 1)
+		wg.Add(1)
+		go func(messages <-chan *sarama.ConsumerMessage) {
+			defer wg.Done()
+			for message := range messages {
+				// skip remaining messages if session is closed, unmarked messages will be consumed again in next session
+				if session.Context().Err() != nil || !c.consumeMessage(session, message) {
+					continue
+				}
+				if committable := tracker.done(message); committable != nil {
+					c.markMessage(session, committable)
+				}
+			}
+		}(shards[i])
+	}
+	defer func() {
+		for _, shard := range shards {
+			close(shard)
+		}
+		wg.Wait()
+	}()
+
+	for {
+		select {
+		case message, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+
+			tracker.add(message)
+			select {
+			case shards[shardIndex(message, len(shards))] <- message:
+			case <-session.Context().Done():
+				return nil
+			}
+
+		case <-session.Context().Done():
+			return nil
+
+		}
+	}
+}
+
+// shardIndex get worker index of message by key, message without key is distributed by offset
+func shardIndex(message *sarama.ConsumerMessage, total int) int {
+	if len(message.Key) == 0 {
+		return int(message.Offset % int64(total))
+	}
+	h := fnv.New32a()
+	h.Write(message.Key)
+	return int(h.Sum32() % uint32(total))
+}
+
+// offsetTracker track in-flight messages of claim in offset order
+type offsetTracker struct {
+	mu       sync.Mutex
+	inFlight []*sarama.ConsumerMessage
+	finished map[int64]bool
+}
+
+func (t *offsetTracker) add(message *sarama.ConsumerMessage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.inFlight = append(t.inFlight, message)
+}
+
+// done set message as finished, return the highest message which all messages before it are finished
+// (nil if there is unfinished message before the message)
+func (t *offsetTracker) done(message *sarama.ConsumerMessage) (committable *sarama.ConsumerMessage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.finished == nil {
+		t.finished = make(map[int64]bool)
+	}
+	t.finished[message.Offset] = true
+	for len(t.inFlight) > 0 && t.finished[t.inFlight[0].Offset] {
+		committable = t.inFlight[0]
+		delete(t.finished, committable.Offset)
+		t.inFlight[0] = nil
+		t.inFlight = t.inFlight[1:]
+	}
+	return committable
+}
@@ -0,0 +1,29 @@
+package kafkaworker
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOffsetTracker(t *testing.T) {
+	var tracker offsetTracker
+	messages := []*sarama.ConsumerMessage{{Offset: 10}, {Offset: 11}, {Offset: 13}, {Offset: 14}}
+	for _, message := range messages {
+		tracker.add(message)
+	}
+
+	assert.Nil(t, tracker.done(messages[1]))
+	assert.Nil(t, tracker.done(messages[2]))
+	assert.Equal(t, messages[2], tracker.done(messages[0]))
+	assert.Equal(t, messages[3], tracker.done(messages[3]))
+	assert.Empty(t, tracker.inFlight)
+}
+
+func TestShardIndex(t *testing.T) {
+	a := shardIndex(&sarama.ConsumerMessage{Key: []byte("order-1"), Offset: 1}, 4)
+	b := shardIndex(&sarama.ConsumerMessage{Key: []byte("order-1"), Offset: 2}, 4)
+	assert.Equal(t, a, b)
+	assert.Equal(t, 3, shardIndex(&sarama.ConsumerMessage{Offset: 7}, 4))
+}
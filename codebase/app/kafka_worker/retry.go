@@ -0,0 +1,173 @@
+package kafkaworker
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/golangid/candi/logger"
+)
+
+// Header key of failure metadata in retry and dead letter message
+const (
+	HeaderOriginalTopic     = "x-original-topic"
+	HeaderOriginalPartition = "x-original-partition"
+	HeaderOriginalOffset    = "x-original-offset"
+	HeaderRetryAttempt      = "x-retry-attempt"
+	HeaderRetryAt           = "x-retry-at"
+	HeaderErrorMessage      = "x-error-message"
+	HeaderFailedAt          = "x-failed-at"
+)
+
+// RetryPolicy strategy for failed message (handler return error or panic)
+type RetryPolicy struct {
+	// Delays delay of each retry attempt, failed message is republished to "{topic}.retry.{attempt}" topic
+	// and processed again by the same handler after delay. Total retry attempt is length of delays
+	Delays []time.Duration
+	// DeadLetter publish message to "{topic}.dlq" topic after all retry attempts are failed
+	DeadLetter bool
+}
+
+func (p RetryPolicy) enabled() bool {
+	return len(p.Delays) > 0 || p.DeadLetter
+}
+
+// topics get retry and dead letter topics of topic, must exist before consumed
+func (p RetryPolicy) topics(topic string) (topics []string) {
+	for attempt := 1; attempt <= len(p.Delays); attempt++ {
+		topics = append(topics, RetryTopic(topic, attempt))
+	}
+	if p.DeadLetter {
+		topics = append(topics, DeadLetterTopic(topic))
+	}
+	return topics
+}
+
+// missingTopics get retry and dead letter topics of topic which not exist in existing topics
+func (p RetryPolicy) missingTopics(topic string, existing map[string]bool) (missing []string) {
+	for _, t := range p.topics(topic) {
+		if !existing[t] {
+			missing = append(missing, t)
+		}
+	}
+	return missing
+}
+
+// RetryTopic get retry topic name of attempt
+func RetryTopic(topic string, attempt int) string {
+	return fmt.Sprintf("%s.retry.%d", topic, attempt)
+}
+
+// DeadLetterTopic get dead letter topic name
+func DeadLetterTopic(topic string) string {
+	return topic + ".dlq"
+}
+
+// retryMetadata get original topic and retry attempt of consumed message, attempt is zero for message from original topic
+func retryMetadata(message *sarama.ConsumerMessage) (originalTopic string, attempt int, retryAt time.Time) {
+	originalTopic = message.Topic
+	for _, header := range message.Headers {
+		if header == nil {
+			continue
+		}
+		switch string(header.Key) {
+		case HeaderOriginalTopic:
+			originalTopic = string(header.Value)
+		case HeaderRetryAttempt:
+			attempt, _ = strconv.Atoi(string(header.Value))
+		case HeaderRetryAt:
+			retryAt, _ = time.Parse(time.RFC3339Nano, string(header.Value))
+		}
+	}
+	return
+}
+
+// waitRetryTime block until retry time of message, return false if context is done before retry time
+func waitRetryTime(ctx context.Context, retryAt time.Time) bool {
+	wait := time.Until(retryAt)
+	if wait <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// republishWithBackoff republish failed message with backoff until success, so that offset of failed message is never marked
+// before republished. Return error only if context is done before success
+func (c *consumerHandler) republishWithBackoff(ctx context.Context, message *sarama.ConsumerMessage, originalTopic string, attempt int, handlerErr error) (string, error) {
+	backoff := minProcessBackoff
+	for {
+		destination, err := c.republishFailedMessage(message, originalTopic, attempt, handlerErr)
+		if err == nil {
+			return destination, nil
+		}
+		logger.LogRed(fmt.Sprintf("kafka_consumer > failed republish message to %s, retry in %s: %v", destination, backoff, err))
+		if !waitRetryTime(ctx, time.Now().Add(backoff)) {
+			return destination, err
+		}
+		if backoff *= 2; backoff > maxProcessBackoff {
+			backoff = maxProcessBackoff
+		}
+	}
+}
+
+// republishFailedMessage publish failed message to next retry topic, or to dead letter topic if all retry attempts are failed.
+// Return destination topic, empty if message is dropped
+func (c *consumerHandler) republishFailedMessage(message *sarama.ConsumerMessage, originalTopic string, attempt int, handlerErr error) (string, error) {
+	now := time.Now()
+	headers := []sarama.RecordHeader{
+		{Key: []byte(HeaderOriginalTopic), Value: []byte(originalTopic)},
+		{Key: []byte(HeaderErrorMessage), Value: []byte(handlerErr.Error())},
+		{Key: []byte(HeaderFailedAt), Value: []byte(now.Format(time.RFC3339Nano))},
+	}
+	if attempt == 0 {
+		headers = append(headers,
+			sarama.RecordHeader{Key: []byte(HeaderOriginalPartition), Value: []byte(strconv.Itoa(int(message.Partition)))},
+			sarama.RecordHeader{Key: []byte(HeaderOriginalOffset), Value: []byte(strconv.FormatInt(message.Offset, 10))},
+		)
+	}
+
+	var destination string
+	if attempt < len(c.retryPolicy.Delays) {
+		destination = RetryTopic(originalTopic, attempt+1)
+		headers = append(headers,
+			sarama.RecordHeader{Key: []byte(HeaderRetryAttempt), Value: []byte(strconv.Itoa(attempt + 1))},
+			sarama.RecordHeader{Key: []byte(HeaderRetryAt), Value: []byte(now.Add(c.retryPolicy.Delays[attempt]).Format(time.RFC3339Nano))},
+		)
+	} else if c.retryPolicy.DeadLetter {
+		destination = DeadLetterTopic(originalTopic)
+		headers = append(headers, sarama.RecordHeader{Key: []byte(HeaderRetryAttempt), Value: []byte(strconv.Itoa(attempt))})
+	} else {
+		return "", nil
+	}
+
+	// keep another headers from consumed message (include original partition & offset from first failure)
+	for _, header := range message.Headers {
+		if header == nil {
+			continue
+		}
+		switch string(header.Key) {
+		case HeaderOriginalTopic, HeaderErrorMessage, HeaderFailedAt, HeaderRetryAttempt, HeaderRetryAt:
+			continue
+		}
+		headers = append(headers, *header)
+	}
+
+	_, _, err := c.producer.SendMessage(&sarama.ProducerMessage{
+		Topic:     destination,
+		Key:       sarama.ByteEncoder(message.Key),
+		Value:     sarama.ByteEncoder(message.Value),
+		Headers:   headers,
+		Timestamp: now,
+	})
+	return destination, err
+}
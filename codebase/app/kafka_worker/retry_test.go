@@ -0,0 +1,119 @@
+package kafkaworker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSyncProducer record sent messages, return err for every message
+type fakeSyncProducer struct {
+	sarama.SyncProducer
+	messages []*sarama.ProducerMessage
+	err      error
+}
+
+func (p *fakeSyncProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	p.messages = append(p.messages, msg)
+	return 0, 0, p.err
+}
+
+func producerHeaders(msg *sarama.ProducerMessage) map[string]string {
+	headers := make(map[string]string)
+	for _, header := range msg.Headers {
+		headers[string(header.Key)] = string(header.Value)
+	}
+	return headers
+}
+
+func TestRetryMetadata(t *testing.T) {
+	originalTopic, attempt, retryAt := retryMetadata(&sarama.ConsumerMessage{Topic: "orders"})
+	assert.Equal(t, "orders", originalTopic)
+	assert.Equal(t, 0, attempt)
+	assert.True(t, retryAt.IsZero())
+
+	at := time.Date(2021, time.May, 14, 2, 0, 0, 0, time.UTC)
+	originalTopic, attempt, retryAt = retryMetadata(&sarama.ConsumerMessage{Topic: "orders.retry.2", Headers: []*sarama.RecordHeader{
+		nil,
+		{Key: []byte(HeaderOriginalTopic), Value: []byte("orders")},
+		{Key: []byte(HeaderRetryAttempt), Value: []byte("2")},
+		{Key: []byte(HeaderRetryAt), Value: []byte(at.Format(time.RFC3339Nano))},
+	}})
+	assert.Equal(t, "orders", originalTopic)
+	assert.Equal(t, 2, attempt)
+	assert.True(t, at.Equal(retryAt))
+}
+
+func TestRepublishFailedMessage(t *testing.T) {
+	producer := &fakeSyncProducer{}
+	handler := &consumerHandler{
+		producer:    producer,
+		retryPolicy: RetryPolicy{Delays: []time.Duration{time.Minute, time.Hour}, DeadLetter: true},
+	}
+	message := &sarama.ConsumerMessage{Topic: "orders", Partition: 1, Offset: 10, Key: []byte("key"), Value: []byte("value"),
+		Headers: []*sarama.RecordHeader{{Key: []byte("trace-id"), Value: []byte("abc")}},
+	}
+
+	destination, err := handler.republishFailedMessage(message, "orders", 0, errors.New("failed"))
+	assert.NoError(t, err)
+	assert.Equal(t, "orders.retry.1", destination)
+	headers := producerHeaders(producer.messages[0])
+	assert.Equal(t, "orders", headers[HeaderOriginalTopic])
+	assert.Equal(t, "1", headers[HeaderOriginalPartition])
+	assert.Equal(t, "10", headers[HeaderOriginalOffset])
+	assert.Equal(t, "1", headers[HeaderRetryAttempt])
+	assert.Equal(t, "failed", headers[HeaderErrorMessage])
+	assert.Equal(t, "abc", headers["trace-id"])
+	assert.Equal(t, sarama.ByteEncoder("value"), producer.messages[0].Value)
+
+	// retry message keep original position and replace failure metadata
+	retried := &sarama.ConsumerMessage{Topic: "orders.retry.1", Value: []byte("value")}
+	for _, header := range producer.messages[0].Headers {
+		header := header
+		retried.Headers = append(retried.Headers, &header)
+	}
+	destination, err = handler.republishFailedMessage(retried, "orders", 1, errors.New("failed again"))
+	assert.NoError(t, err)
+	assert.Equal(t, "orders.retry.2", destination)
+	headers = producerHeaders(producer.messages[1])
+	assert.Equal(t, "2", headers[HeaderRetryAttempt])
+	assert.Equal(t, "10", headers[HeaderOriginalOffset])
+	assert.Equal(t, "failed again", headers[HeaderErrorMessage])
+	assert.Len(t, producer.messages[1].Headers, len(producer.messages[0].Headers))
+
+	destination, err = handler.republishFailedMessage(retried, "orders", 2, errors.New("failed"))
+	assert.NoError(t, err)
+	assert.Equal(t, "orders.dlq", destination)
+
+	handler.retryPolicy.DeadLetter = false
+	destination, err = handler.republishFailedMessage(retried, "orders", 2, errors.New("failed"))
+	assert.NoError(t, err)
+	assert.Equal(t, "", destination, "message is dropped")
+	assert.Len(t, producer.messages, 3)
+}
+
+func TestRepublishWithBackoff(t *testing.T) {
+	producer := &fakeSyncProducer{err: errors.New("leader not available")}
+	handler := &consumerHandler{producer: producer, retryPolicy: RetryPolicy{Delays: []time.Duration{time.Minute}}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// session is closed before republished, offset must not be marked even if not in at-least-once mode
+	message := &sarama.ConsumerMessage{Topic: "orders"}
+	_, err := handler.republishWithBackoff(ctx, message, "orders", 0, errors.New("failed"))
+	assert.Error(t, err)
+	assert.False(t, handler.processWithBackoff(ctx, func() bool { return false }))
+
+	assert.True(t, handler.processWithBackoff(context.Background(), func() bool { return false }), "failed message is marked without at-least-once")
+}
+
+func TestRetryPolicyMissingTopics(t *testing.T) {
+	policy := RetryPolicy{Delays: []time.Duration{time.Minute, time.Hour}, DeadLetter: true}
+	assert.Equal(t, []string{"orders.retry.1", "orders.retry.2", "orders.dlq"}, policy.topics("orders"))
+	assert.Equal(t, []string{"orders.retry.2", "orders.dlq"}, policy.missingTopics("orders", map[string]bool{"orders.retry.1": true}))
+	assert.Empty(t, RetryPolicy{}.missingTopics("orders", nil))
+}
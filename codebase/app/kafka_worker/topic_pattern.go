@@ -0,0 +1,131 @@
+package kafkaworker
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/golangid/candi/logger"
+)
+
+// topic name which republished by retry policy, not matched by pattern because already subscribed with the original topic
+var retryTopicRegex = regexp.MustCompile(`\.(retry\.\d+|dlq)$`)
+
+// isTopicPattern check handler pattern is regex, dot is not included because valid character in topic name
+func isTopicPattern(pattern string) bool {
+	return strings.ContainsAny(pattern, `\^$*+?()[]{}|`)
+}
+
+type topicPattern struct {
+	regex   *regexp.Regexp
+	handler topicHandler
+	module  string
+}
+
+// topicSubscription track topics matching handler pattern, new matched topics is pending until subscribed in next session
+type topicSubscription struct {
+	mu         sync.Mutex
+	patterns   []topicPattern
+	subscribed map[string]bool
+	pending    map[string]topicPattern
+	changed    chan struct{}
+	// retryPolicy matched topic is not subscribed until retry and dead letter topics exist
+	retryPolicy RetryPolicy
+}
+
+func newTopicSubscription() *topicSubscription {
+	return &topicSubscription{
+		subscribed: make(map[string]bool), pending: make(map[string]topicPattern), changed: make(chan struct{}, 1),
+	}
+}
+
+func (s *topicSubscription) addPattern(pattern string, handler topicHandler, module string) error {
+	regex, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return err
+	}
+	s.patterns = append(s.patterns, topicPattern{regex: regex, handler: handler, module: module})
+	return nil
+}
+
+// discover find new topics matching pattern from all topics in cluster, return true if found
+func (s *topicSubscription) discover(topics []string) (found bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing := make(map[string]bool, len(topics))
+	for _, topic := range topics {
+		existing[topic] = true
+	}
+	for _, topic := range topics {
+		if _, ok := s.pending[topic]; ok || s.subscribed[topic] || retryTopicRegex.MatchString(topic) {
+			continue
+		}
+		for _, pattern := range s.patterns {
+			if pattern.regex.MatchString(topic) {
+				if missing := s.retryPolicy.missingTopics(topic, existing); len(missing) > 0 {
+					logger.LogRed(fmt.Sprintf("Kafka: topic %s matching pattern is not subscribed, retry topics are not exist: %s",
+						topic, strings.Join(missing, ", ")))
+					break
+				}
+				s.pending[topic] = pattern
+				found = true
+				break
+			}
+		}
+	}
+	if found {
+		select {
+		case s.changed <- struct{}{}:
+		default:
+		}
+	}
+	return found
+}
+
+// takePending get new matched topics and mark as subscribed, changed signal is drained because all pending topics
+// are taken, so that next session is not ended by stale signal
+func (s *topicSubscription) takePending() map[string]topicPattern {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.changed:
+	default:
+	}
+	pending := s.pending
+	for topic := range pending {
+		s.subscribed[topic] = true
+	}
+	s.pending = make(map[string]topicPattern)
+	return pending
+}
+
+// refreshTopics refresh metadata periodically for discover new topics matching pattern
+func (h *kafkaWorker) refreshTopics(ctx context.Context, client sarama.Client, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := client.RefreshMetadata(); err != nil {
+			logger.LogRed("Kafka: cannot refresh metadata for discover topics: " + err.Error())
+			continue
+		}
+		topics, err := client.Topics()
+		if err != nil {
+			logger.LogRed("Kafka: cannot get topics: " + err.Error())
+			continue
+		}
+		h.subscription.discover(topics)
+	}
+}
@@ -0,0 +1,106 @@
+package kafkaworker
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/golangid/candi/config/env"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsTopicPattern(t *testing.T) {
+	assert.False(t, isTopicPattern("orders.tenant-1"))
+	assert.True(t, isTopicPattern(`orders\..*`))
+	assert.True(t, isTopicPattern("orders|payments"))
+}
+
+func TestTopicSubscriptionDiscover(t *testing.T) {
+	subscription := newTopicSubscription()
+	assert.NoError(t, subscription.addPattern(`orders\..*`, topicHandler{}, "order"))
+	assert.Error(t, subscription.addPattern(`orders\.(`, topicHandler{}, "order"))
+	subscription.subscribed["orders.tenant-1"] = true
+
+	found := subscription.discover([]string{"orders.tenant-1", "orders.tenant-2", "orders.tenant-2.retry.1", "orders.tenant-2.dlq", "xorders.tenant-3", "payments"})
+	assert.True(t, found)
+	assert.Len(t, subscription.changed, 1)
+
+	pending := subscription.takePending()
+	assert.Len(t, subscription.changed, 0, "changed signal is drained")
+	assert.Len(t, pending, 1)
+	assert.Contains(t, pending, "orders.tenant-2")
+	assert.True(t, subscription.subscribed["orders.tenant-2"])
+	assert.False(t, subscription.discover([]string{"orders.tenant-2"}))
+}
+
+func TestTopicSubscriptionDiscoverMissingRetryTopics(t *testing.T) {
+	subscription := newTopicSubscription()
+	subscription.retryPolicy = RetryPolicy{DeadLetter: true}
+	assert.NoError(t, subscription.addPattern(`orders\..*`, topicHandler{}, "order"))
+
+	// topic is not subscribed until dead letter topic exist
+	assert.False(t, subscription.discover([]string{"orders.tenant-1"}))
+	assert.True(t, subscription.discover([]string{"orders.tenant-1", "orders.tenant-1.dlq"}))
+	assert.Contains(t, subscription.takePending(), "orders.tenant-1")
+}
+
+// fakeConsumerGroup send subscribed topics of each session, session is blocked until context is done
+type fakeConsumerGroup struct {
+	sarama.ConsumerGroup
+	sessions chan []string
+}
+
+func (g *fakeConsumerGroup) Consume(ctx context.Context, topics []string, handler sarama.ConsumerGroupHandler) error {
+	g.sessions <- append([]string{}, topics...)
+	<-ctx.Done()
+	return nil
+}
+func (g *fakeConsumerGroup) Close() error { return nil }
+
+func TestServeResubscribeNewTopics(t *testing.T) {
+	baseEnv := env.BaseEnv()
+	defer env.SetEnv(baseEnv)
+	newEnv := baseEnv
+	newEnv.Kafka.TopicRefreshInterval = time.Hour
+	env.SetEnv(newEnv)
+
+	engine := &fakeConsumerGroup{sessions: make(chan []string, 10)}
+	consumerHandler := &consumerHandler{handlerFuncs: make(map[string]topicHandler), ready: make(chan struct{})}
+	worker := &kafkaWorker{engine: engine, consumerHandler: consumerHandler, subscription: newTopicSubscription()}
+	assert.NoError(t, worker.subscription.addPattern(`orders\..*`, topicHandler{}, "order"))
+
+	// initial discover in constructor
+	worker.subscription.discover([]string{"orders.tenant-1"})
+	worker.subscribePendingTopics()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		worker.Serve()
+	}()
+
+	waitSession := func() []string {
+		select {
+		case topics := <-engine.sessions:
+			return topics
+		case <-time.After(time.Second):
+			t.Fatal("consumer group session is not started")
+		}
+		return nil
+	}
+	assert.Equal(t, []string{"orders.tenant-1"}, waitSession())
+	select {
+	case topics := <-engine.sessions:
+		t.Fatalf("first session is ended by stale signal, resubscribed with %v", topics)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	worker.subscription.discover([]string{"orders.tenant-1", "orders.tenant-2"})
+	assert.Equal(t, []string{"orders.tenant-1", "orders.tenant-2"}, waitSession())
+
+	worker.Shutdown(context.Background())
+	wg.Wait()
+}
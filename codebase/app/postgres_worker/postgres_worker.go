@@ -33,7 +33,7 @@ type (
 		ctx           context.Context
 		ctxCancelFunc func()
 
-		consul   *candiutils.Consul
+		locker   candiutils.DistributedLocker
 		listener *pq.Listener
 		handlers map[string]types.WorkerHandlerFunc
 		wg       sync.WaitGroup
@@ -55,6 +55,7 @@ func NewWorker(service factory.ServiceFactory) factory.AppServerFactory {
 			var handlerGroup types.WorkerHandlerGroup
 			h.MountHandlers(&handlerGroup)
 			for _, handler := range handlerGroup.Handlers {
+				handler.RejectBatchHandler(types.PostgresListener)
 				logger.LogYellow(fmt.Sprintf(`[POSTGRES-LISTENER] (table): %-15s  --> (module): "%s"`, `"`+handler.Pattern+`"`, m.Name()))
 				worker.handlers[handler.Pattern] = handler.HandlerFunc
 				execTriggerQuery(db, handler.Pattern)
@@ -68,16 +69,19 @@ func NewWorker(service factory.ServiceFactory) factory.AppServerFactory {
 		fmt.Printf("\x1b[34;1m⇨ Postgres Event Listener running with %d table\x1b[0m\n\n", len(worker.handlers))
 	}
 
-	if env.BaseEnv().UseConsul {
-		consul, err := candiutils.NewConsul(&candiutils.ConsulConfig{
-			ConsulAgentHost:   env.BaseEnv().ConsulAgentHost,
-			ConsulKey:         fmt.Sprintf("%s_postgres_event_listener", service.Name()),
+	if env.BaseEnv().DistributedLockBackend != "" {
+		locker, err := candiutils.NewDistributedLocker(&candiutils.DistributedLockerConfig{
+			Backend:           env.BaseEnv().DistributedLockBackend,
+			Key:               fmt.Sprintf("%s_postgres_event_listener", service.Name()),
 			LockRetryInterval: 1 * time.Second,
+			ConsulAgentHost:   env.BaseEnv().ConsulAgentHost,
+			RedisPool:         service.GetDependency().GetRedisPool(),
+			SQLDatabase:       service.GetDependency().GetSQLDatabase(),
 		})
 		if err != nil {
 			panic(err)
 		}
-		worker.consul = consul
+		worker.locker = locker
 	}
 
 	worker.listener = listener
@@ -86,7 +90,7 @@ func NewWorker(service factory.ServiceFactory) factory.AppServerFactory {
 }
 
 func (p *postgresWorker) Serve() {
-	p.createConsulSession()
+	p.createLockSession()
 
 START:
 	<-startWorkerCh
@@ -128,19 +132,26 @@ START:
 				}
 			}(e)
 
-			// rebalance worker if run in multiple instance and using consul
-			if p.consul != nil {
+			// rebalance worker if run in multiple instance and using distributed lock
+			if p.locker != nil {
 				totalRunJobs++
 				// if already running n jobs, release lock so that run in another instance
 				if totalRunJobs == env.BaseEnv().ConsulMaxJobRebalance {
 					p.listener.Unlisten(eventsConst)
 					// recreate session
-					p.createConsulSession()
+					p.createLockSession()
 					<-releaseWorkerCh
 					goto START
 				}
 			}
 
+		case <-releaseWorkerCh:
+			// distributed lock is lost, stop listen events and wait until lock acquired again
+			logger.LogYellow("Postgres Event Listener: distributed lock is lost, stop listener")
+			p.listener.Unlisten(eventsConst)
+			p.createLockSession()
+			goto START
+
 		case <-time.After(2 * time.Minute):
 			p.listener.Ping()
 
@@ -153,8 +164,8 @@ START:
 func (p *postgresWorker) Shutdown(ctx context.Context) {
 	log.Println("\x1b[33;1mStopping Postgres Event Listener worker...\x1b[0m")
 	defer func() {
-		if p.consul != nil {
-			if err := p.consul.DestroySession(); err != nil {
+		if p.locker != nil {
+			if err := p.locker.DestroySession(); err != nil {
 				panic(err)
 			}
 		}
@@ -181,15 +192,15 @@ func (p *postgresWorker) Name() string {
 	return string(types.PostgresListener)
 }
 
-func (p *postgresWorker) createConsulSession() {
-	if p.consul == nil {
+func (p *postgresWorker) createLockSession() {
+	if p.locker == nil {
 		go func() { startWorkerCh <- struct{}{} }()
 		return
 	}
-	p.consul.DestroySession()
+	p.locker.DestroySession()
 	hostname, _ := os.Hostname()
 	value := map[string]string{
 		"hostname": hostname,
 	}
-	go p.consul.RetryLockAcquire(value, startWorkerCh, releaseWorkerCh)
+	go p.locker.RetryLockAcquire(value, startWorkerCh, releaseWorkerCh)
 }
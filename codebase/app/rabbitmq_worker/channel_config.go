@@ -1,27 +1,125 @@
 package rabbitmqworker
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 
+	"github.com/golangid/candi/codebase/factory/types"
 	"github.com/golangid/candi/config/env"
 	"github.com/streadway/amqp"
 )
 
-func setupQueueConfig(ch *amqp.Channel, queueName string) (<-chan amqp.Delivery, error) {
-	queue, err := ch.QueueDeclare(queueName, true, false, false, false, nil)
+func setupQueueConfig(ch *amqp.Channel, queueName string, config types.RabbitMQQueueConfig) (<-chan amqp.Delivery, error) {
+	args := amqp.Table{}
+	for k, v := range config.Args {
+		args[k] = v
+	}
+	if env.BaseEnv().RabbitMQ.DeadLetter {
+		if err := setupDeadLetterQueue(ch, queueName); err != nil {
+			return nil, err
+		}
+		args["x-dead-letter-exchange"] = DeadLetterExchange(env.BaseEnv().RabbitMQ.ExchangeName)
+		args["x-dead-letter-routing-key"] = queueName
+	}
+	queue, err := ch.QueueDeclare(queueName, true, false, false, false, args)
 	if err != nil {
-		return nil, fmt.Errorf("error in declaring the queue %s", err)
+		return nil, fmt.Errorf("error in declaring the queue %w", err)
 	}
+
+	// default binding, used for retry message
 	if err := ch.QueueBind(queue.Name, queue.Name, env.BaseEnv().RabbitMQ.ExchangeName, false, nil); err != nil {
 		return nil, fmt.Errorf("Queue bind error: %s", err)
 	}
+	routingKeys := config.RoutingKeys
+	if exchange := bindingExchange(config); exchange != env.BaseEnv().RabbitMQ.ExchangeName {
+		if err := ch.ExchangeDeclare(exchange, exchangeType(config), true, false, false, false, nil); err != nil {
+			return nil, fmt.Errorf("error in declaring exchange %s: %s", exchange, err)
+		}
+		if len(routingKeys) == 0 {
+			routingKeys = []string{queue.Name}
+		}
+	}
+	for _, routingKey := range routingKeys {
+		if err := ch.QueueBind(queue.Name, routingKey, bindingExchange(config), false, nil); err != nil {
+			return nil, fmt.Errorf("Queue bind error: %s", err)
+		}
+	}
+
 	return ch.Consume(
 		queue.Name,
-		env.BaseEnv().RabbitMQ.ConsumerGroup+"_"+queue.Name, // consumer
-		env.BaseEnv().RabbitMQ.AutoACK,                      // auto-ack
-		false,                                               // exclusive
-		false,                                               // no-local
-		false,                                               // no-wait
-		nil,                                                 // args
+		consumerTag(queue.Name),        // consumer
+		env.BaseEnv().RabbitMQ.AutoACK, // auto-ack
+		false,                          // exclusive
+		false,                          // no-local
+		false,                          // no-wait
+		nil,                            // args
 	)
 }
+
+func bindingExchange(config types.RabbitMQQueueConfig) string {
+	if config.Exchange == "" {
+		return env.BaseEnv().RabbitMQ.ExchangeName
+	}
+	return config.Exchange
+}
+
+func exchangeType(config types.RabbitMQQueueConfig) string {
+	if config.ExchangeType == "" {
+		return amqp.ExchangeDirect
+	}
+	return config.ExchangeType
+}
+
+// setupDeadLetterQueue declare dead letter exchange and queue "{queue}.dlq" for rejected message from queue
+func setupDeadLetterQueue(ch *amqp.Channel, queueName string) error {
+	exchange := DeadLetterExchange(env.BaseEnv().RabbitMQ.ExchangeName)
+	if err := ch.ExchangeDeclare(exchange, "direct", true, false, false, false, nil); err != nil {
+		return fmt.Errorf("error in declaring dead letter exchange %s", err)
+	}
+	queue, err := ch.QueueDeclare(DeadLetterQueue(queueName), true, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("error in declaring dead letter queue %w", err)
+	}
+	if err := ch.QueueBind(queue.Name, queueName, exchange, false, nil); err != nil {
+		return fmt.Errorf("Dead letter queue bind error: %s", err)
+	}
+	return nil
+}
+
+// isPreconditionFailed check error is 406 PRECONDITION_FAILED, returned when queue is declared with
+// different arguments from existing queue (example x-dead-letter-* or custom Args is added to existing queue)
+func isPreconditionFailed(err error) bool {
+	var amqpErr *amqp.Error
+	return errors.As(err, &amqpErr) && amqpErr.Code == amqp.PreconditionFailed
+}
+
+// withDefaultConsumeConfig set default concurrency (1) and prefetch (2 or concurrency if greater) of queue
+func withDefaultConsumeConfig(config types.RabbitMQQueueConfig) types.RabbitMQQueueConfig {
+	if config.Concurrency <= 0 {
+		config.Concurrency = 1
+	}
+	if config.Prefetch <= 0 {
+		config.Prefetch = defaultPrefetch
+		if config.Concurrency > defaultPrefetch {
+			config.Prefetch = config.Concurrency
+		}
+	}
+	return config
+}
+
+// bindingInfo info of custom exchange and routing keys for startup log
+func bindingInfo(config types.RabbitMQQueueConfig) string {
+	if config.Exchange == "" && len(config.RoutingKeys) == 0 {
+		return ""
+	}
+	info := " (exchange): " + bindingExchange(config)
+	if len(config.RoutingKeys) > 0 {
+		info += " (routing): " + strings.Join(config.RoutingKeys, ", ")
+	}
+	return info
+}
+
+func consumerTag(queueName string) string {
+	return env.BaseEnv().RabbitMQ.ConsumerGroup + "_" + queueName
+}
@@ -0,0 +1,43 @@
+package rabbitmqworker
+
+import (
+	"testing"
+
+	"github.com/golangid/candi/codebase/factory/types"
+	"github.com/golangid/candi/config/env"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBindingConfig(t *testing.T) {
+	defaultExchange := env.BaseEnv().RabbitMQ.ExchangeName
+
+	assert.Equal(t, defaultExchange, bindingExchange(types.RabbitMQQueueConfig{}))
+	assert.Equal(t, "direct", exchangeType(types.RabbitMQQueueConfig{}))
+	assert.Equal(t, "", bindingInfo(types.RabbitMQQueueConfig{}))
+
+	config := types.RabbitMQQueueConfig{Exchange: "orders", ExchangeType: "topic", RoutingKeys: []string{"order.*.created", "order.*.paid"}}
+	assert.Equal(t, "orders", bindingExchange(config))
+	assert.Equal(t, "topic", exchangeType(config))
+	assert.Equal(t, " (exchange): orders (routing): order.*.created, order.*.paid", bindingInfo(config))
+}
+
+func TestWithDefaultConsumeConfig(t *testing.T) {
+	tests := []struct {
+		name                  string
+		config                types.RabbitMQQueueConfig
+		concurrency, prefetch int
+	}{
+		{name: "default", config: types.RabbitMQQueueConfig{}, concurrency: 1, prefetch: defaultPrefetch},
+		{name: "negative value", config: types.RabbitMQQueueConfig{Concurrency: -1, Prefetch: -1}, concurrency: 1, prefetch: defaultPrefetch},
+		{name: "concurrency lower than default prefetch", config: types.RabbitMQQueueConfig{Concurrency: 2}, concurrency: 2, prefetch: defaultPrefetch},
+		{name: "prefetch follow concurrency", config: types.RabbitMQQueueConfig{Concurrency: 10}, concurrency: 10, prefetch: 10},
+		{name: "custom prefetch", config: types.RabbitMQQueueConfig{Concurrency: 10, Prefetch: 4}, concurrency: 10, prefetch: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := withDefaultConsumeConfig(tt.config)
+			assert.Equal(t, tt.concurrency, config.Concurrency)
+			assert.Equal(t, tt.prefetch, config.Prefetch)
+		})
+	}
+}
@@ -4,50 +4,67 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"reflect"
 	"sync"
+	"time"
 
 	"github.com/golangid/candi/candihelper"
 	"github.com/golangid/candi/codebase/factory"
 	"github.com/golangid/candi/codebase/factory/types"
+	"github.com/golangid/candi/config/broker"
 	"github.com/golangid/candi/config/env"
 	"github.com/golangid/candi/logger"
 	"github.com/golangid/candi/tracer"
 	"github.com/streadway/amqp"
 )
 
+const (
+	// reconsumeInterval interval for retry consume when consumer is closed but no signal from broker,
+	// doubled for each consecutive failed consume up to maxReconsumeInterval
+	reconsumeInterval    = 10 * time.Second
+	maxReconsumeInterval = 5 * time.Minute
+	defaultPrefetch      = 2
+)
+
 type handlerType struct {
 	handlerFunc   types.WorkerHandlerFunc
 	errorHandlers []types.WorkerErrorHandler
+	config        types.RabbitMQQueueConfig
 }
 
 type rabbitmqWorker struct {
 	ctx           context.Context
 	ctxCancelFunc func()
 
-	ch        *amqp.Channel
+	broker    *broker.RabbitMQBroker
 	shutdown  chan struct{}
-	semaphore []chan struct{}
 	wg        sync.WaitGroup
-	channels  []reflect.SelectCase
-	handlers  map[string]handlerType
+	consumers []*queueConsumer
+	handlers  map[string]handlerType // mapping queue to handler func in delivery layer
+}
+
+// queueConsumer consumer of queue with dedicated channel, so that prefetch (QoS) is applied per queue
+type queueConsumer struct {
+	queue       string
+	config      types.RabbitMQQueueConfig
+	semaphore   chan struct{}
+	reconnected chan struct{}
+
+	mu sync.Mutex
+	ch *amqp.Channel
 }
 
 // NewWorker create new rabbitmq consumer
 func NewWorker(service factory.ServiceFactory) factory.AppServerFactory {
-	if service.GetDependency().GetBroker().GetConfiguration(types.RabbitMQ) == nil {
+	bk, ok := service.GetDependency().GetBroker().(interface{ RabbitMQ() *broker.RabbitMQBroker })
+	if !ok || bk.RabbitMQ() == nil {
 		panic("Missing RabbitMQ configuration")
 	}
 
 	worker := new(rabbitmqWorker)
 	worker.ctx, worker.ctxCancelFunc = context.WithCancel(context.Background())
-	worker.ch = service.GetDependency().GetBroker().GetConfiguration(types.RabbitMQ).(*amqp.Channel)
+	worker.broker = bk.RabbitMQ()
 
 	worker.shutdown = make(chan struct{})
-	// add shutdown channel to first index
-	worker.channels = append(worker.channels, reflect.SelectCase{
-		Dir: reflect.SelectRecv, Chan: reflect.ValueOf(worker.shutdown),
-	})
 	worker.handlers = make(map[string]handlerType)
 
 	for _, m := range service.GetModules() {
@@ -55,27 +72,31 @@ func NewWorker(service factory.ServiceFactory) factory.AppServerFactory {
 			var handlerGroup types.WorkerHandlerGroup
 			h.MountHandlers(&handlerGroup)
 			for _, handler := range handlerGroup.Handlers {
-				logger.LogYellow(fmt.Sprintf(`[RABBITMQ-CONSUMER] (queue): %-15s  --> (module): "%s"`, `"`+handler.Pattern+`"`, m.Name()))
-				queueChan, err := setupQueueConfig(worker.ch, handler.Pattern)
-				if err != nil {
-					panic(err)
-				}
-
-				worker.channels = append(worker.channels, reflect.SelectCase{
-					Dir: reflect.SelectRecv, Chan: reflect.ValueOf(queueChan),
-				})
-				worker.handlers[handler.Pattern] = handlerType{
+				handler.RejectBatchHandler(types.RabbitMQ)
+				handlerFunc := handlerType{
 					handlerFunc: handler.HandlerFunc, errorHandlers: handler.ErrorHandler,
 				}
-				worker.semaphore = append(worker.semaphore, make(chan struct{}, 1))
+				if handler.RabbitMQ != nil {
+					handlerFunc.config = *handler.RabbitMQ
+				}
+				handlerFunc.config = withDefaultConsumeConfig(handlerFunc.config)
+				logger.LogYellow(fmt.Sprintf(`[RABBITMQ-CONSUMER] (queue): %-15s  --> (module): "%s" (concurrency): %d (prefetch): %d%s`,
+					`"`+handler.Pattern+`"`, m.Name(), handlerFunc.config.Concurrency, handlerFunc.config.Prefetch, bindingInfo(handlerFunc.config)))
+				worker.handlers[handler.Pattern] = handlerFunc
+				worker.consumers = append(worker.consumers, &queueConsumer{
+					queue:       handler.Pattern,
+					config:      handlerFunc.config,
+					semaphore:   make(chan struct{}, handlerFunc.config.Concurrency),
+					reconnected: worker.broker.NotifyReconnect(make(chan struct{}, 1)),
+				})
 			}
 		}
 	}
 
-	if len(worker.channels) == 1 {
+	if len(worker.consumers) == 0 {
 		log.Println("rabbitmq consumer: no queue provided")
 	} else {
-		fmt.Printf("\x1b[34;1m⇨ RabbitMQ consumer running with %d queue. Broker: %s\x1b[0m\n\n", len(worker.channels)-1,
+		fmt.Printf("\x1b[34;1m⇨ RabbitMQ consumer running with %d queue. Broker: %s\x1b[0m\n\n", len(worker.consumers),
 			candihelper.MaskingPasswordURL(env.BaseEnv().RabbitMQ.Broker))
 	}
 
@@ -83,54 +104,134 @@ func NewWorker(service factory.ServiceFactory) factory.AppServerFactory {
 }
 
 func (r *rabbitmqWorker) Serve() {
+	var wg sync.WaitGroup
+	for _, consumer := range r.consumers {
+		wg.Add(1)
+		go func(consumer *queueConsumer) {
+			defer wg.Done()
+			r.consumeQueue(consumer)
+		}(consumer)
+	}
+	<-r.shutdown
+	wg.Wait()
+}
 
+// consumeQueue consume queue until shutdown, consume again after connection or channel is reopened
+func (r *rabbitmqWorker) consumeQueue(consumer *queueConsumer) {
+	interval := reconsumeInterval
 	for {
-		chosen, value, ok := reflect.Select(r.channels)
-		if !ok {
-			continue
+		deliveries, err := r.consume(consumer)
+		switch {
+		case isPreconditionFailed(err):
+			logger.LogRed(fmt.Sprintf("rabbitmq_consumer > cannot consume queue %s, existing queue is declared with different arguments "+
+				"(dead letter or custom args), delete the queue or apply the arguments with policy, retry in %s: %s", consumer.queue, interval, err.Error()))
+		case err != nil:
+			logger.LogRed(fmt.Sprintf("rabbitmq_consumer > cannot consume queue %s, retry in %s: %s", consumer.queue, interval, err.Error()))
+		default:
+			if r.receive(consumer, deliveries) {
+				return
+			}
+			interval = reconsumeInterval
 		}
 
-		// if shutdown channel captured, break loop (no more jobs will run)
-		if chosen == 0 {
+		// wait connection or channel is reopened by broker
+		select {
+		case <-r.shutdown:
 			return
+		case <-consumer.reconnected:
+		case <-time.After(interval):
 		}
+		if err != nil {
+			if interval *= 2; interval > maxReconsumeInterval {
+				interval = maxReconsumeInterval
+			}
+		}
+	}
+}
 
-		// exec handler
-		if msg, ok := value.Interface().(amqp.Delivery); ok {
-			r.semaphore[chosen-1] <- struct{}{}
+// consume open dedicated channel with prefetch of queue, setup queue and start consumer
+func (r *rabbitmqWorker) consume(consumer *queueConsumer) (<-chan amqp.Delivery, error) {
+	consumer.close()
+
+	ch, err := r.broker.Connection().Channel()
+	if err != nil {
+		return nil, err
+	}
+	consumer.mu.Lock()
+	consumer.ch = ch
+	consumer.mu.Unlock()
+
+	if err := ch.Qos(consumer.config.Prefetch, 0, false); err != nil {
+		return nil, fmt.Errorf("Qos error: %s", err)
+	}
+	return setupQueueConfig(ch, consumer.queue, consumer.config)
+}
+
+// receive exec handler for each message with maximum concurrency of queue,
+// until shutdown (return true) or channel of consumer is closed
+func (r *rabbitmqWorker) receive(consumer *queueConsumer, deliveries <-chan amqp.Delivery) bool {
+	for {
+		select {
+		// if shutdown channel captured, break loop (no more jobs will run)
+		case <-r.shutdown:
+			return true
+
+		case message, ok := <-deliveries:
+			if !ok {
+				logger.LogRed(fmt.Sprintf("rabbitmq_consumer > consumer of queue %s is closed, waiting for reconnect", consumer.queue))
+				return false
+			}
+
+			select {
+			case consumer.semaphore <- struct{}{}:
+			case <-r.shutdown:
+				return true
+			}
 			r.wg.Add(1)
-			go func(message amqp.Delivery, idx int) {
-				r.processMessage(message)
+			go func(message amqp.Delivery) {
+				r.processMessage(consumer.queue, message)
 				r.wg.Done()
-				<-r.semaphore[idx-1]
-			}(msg, chosen)
+				<-consumer.semaphore
+			}(message)
 		}
 	}
 }
 
+// close channel of consumer, unacked messages are requeued. Error is ignored because channel may be closed
+func (c *queueConsumer) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ch != nil {
+		c.ch.Close()
+		c.ch = nil
+	}
+}
+
 func (r *rabbitmqWorker) Shutdown(ctx context.Context) {
 	log.Println("\x1b[33;1mStopping RabbitMQ Worker...\x1b[0m")
 	defer func() { recover(); log.Println("\x1b[33;1mStopping RabbitMQ Worker:\x1b[0m \x1b[32;1mSUCCESS\x1b[0m") }()
 
 	r.ctxCancelFunc()
-	r.shutdown <- struct{}{}
+	close(r.shutdown)
 	var runningJob int
-	for _, sem := range r.semaphore {
-		runningJob += len(sem)
+	for _, consumer := range r.consumers {
+		runningJob += len(consumer.semaphore)
 	}
 	if runningJob != 0 {
 		fmt.Printf("\x1b[34;1mRabbitMQ Worker:\x1b[0m waiting %d job until done...\x1b[0m\n", runningJob)
 	}
 
 	r.wg.Wait()
-	r.ch.Close()
+	for _, consumer := range r.consumers {
+		consumer.close()
+	}
 }
 
 func (r *rabbitmqWorker) Name() string {
 	return string(types.RabbitMQ)
 }
 
-func (r *rabbitmqWorker) processMessage(message amqp.Delivery) {
+func (r *rabbitmqWorker) processMessage(queue string, message amqp.Delivery) {
 	if r.ctx.Err() != nil {
 		logger.LogRed("rabbitmq_consumer > ctx root err: " + r.ctx.Err().Error())
 		return
@@ -143,8 +244,12 @@ func (r *rabbitmqWorker) processMessage(message amqp.Delivery) {
 			err = fmt.Errorf("panic: %v", r)
 		}
 
-		if err == nil && !env.BaseEnv().RabbitMQ.AutoACK {
-			message.Ack(false)
+		if !env.BaseEnv().RabbitMQ.AutoACK {
+			if err == nil {
+				message.Ack(false)
+			} else {
+				r.handleFailedMessage(queue, message, err)
+			}
 		}
 
 		trace.SetError(err)
@@ -154,15 +259,17 @@ func (r *rabbitmqWorker) processMessage(message amqp.Delivery) {
 
 	trace.SetTag("broker", candihelper.MaskingPasswordURL(env.BaseEnv().RabbitMQ.Broker))
 	trace.SetTag("exchange", message.Exchange)
+	trace.SetTag("queue", queue)
 	trace.SetTag("routing_key", message.RoutingKey)
+	trace.SetTag("retry_count", retryCount(message.Headers))
 	trace.Log("header", message.Headers)
 	trace.Log("body", message.Body)
 
-	selectedHandler := r.handlers[message.RoutingKey]
+	selectedHandler := r.handlers[queue]
 	err = selectedHandler.handlerFunc(ctx, message.Body)
 	if err != nil {
 		for _, errHandler := range selectedHandler.errorHandlers {
-			errHandler(ctx, types.RabbitMQ, message.RoutingKey, message.Body, err)
+			errHandler(ctx, types.RabbitMQ, queue, message.Body, err)
 		}
 	}
 }
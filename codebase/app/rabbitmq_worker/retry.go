@@ -0,0 +1,128 @@
+package rabbitmqworker
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golangid/candi/config/env"
+	"github.com/golangid/candi/logger"
+	"github.com/golangid/candi/publisher"
+	"github.com/streadway/amqp"
+)
+
+// Header key of failure metadata in retry message
+const (
+	HeaderRetryCount   = "x-retry-count"
+	HeaderErrorMessage = "x-error-message"
+)
+
+// republishConfirmTimeout maximum time waiting broker confirm of republished message
+const republishConfirmTimeout = 10 * time.Second
+
+// DeadLetterExchange get dead letter exchange name of exchange
+func DeadLetterExchange(exchange string) string {
+	return exchange + ".dlx"
+}
+
+// DeadLetterQueue get dead letter queue name of queue
+func DeadLetterQueue(queue string) string {
+	return queue + ".dlq"
+}
+
+// retryCount get total retry of message from header
+func retryCount(headers amqp.Table) int {
+	switch count := headers[HeaderRetryCount].(type) {
+	case int:
+		return count
+	case int8:
+		return int(count)
+	case int16:
+		return int(count)
+	case int32:
+		return int(count)
+	case int64:
+		return int(count)
+	}
+	return 0
+}
+
+// handleFailedMessage retry failed message up to RABBITMQ_MAX_RETRY, then message is rejected without requeue
+// so that routed to dead letter queue (if RABBITMQ_DEAD_LETTER is active). Retry is republished with incremented
+// retry count header instead of nack with requeue, because requeued message cannot be counted
+func (r *rabbitmqWorker) handleFailedMessage(queue string, message amqp.Delivery, handlerErr error) {
+	count := retryCount(message.Headers)
+	if count >= env.BaseEnv().RabbitMQ.MaxRetry {
+		logger.LogYellow(fmt.Sprintf("rabbitmq_consumer > message in queue %s is rejected after %d retry", queue, count))
+		message.Nack(false, false)
+		return
+	}
+
+	if err := r.republish(queue, message, count+1, handlerErr); err != nil {
+		logger.LogRed("rabbitmq_consumer > cannot republish failed message, requeue message: " + err.Error())
+		message.Nack(false, true)
+		return
+	}
+	message.Ack(false)
+}
+
+// republish message to queue through delayed exchange, delayed with RABBITMQ_RETRY_DELAY. Return after publish is confirmed
+// by broker, so that original message is acked only when retry message is not lost
+func (r *rabbitmqWorker) republish(queue string, message amqp.Delivery, count int, handlerErr error) error {
+	ch, err := r.broker.Connection().Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	headers := amqp.Table{}
+	for k, v := range message.Headers {
+		headers[k] = v
+	}
+	headers[HeaderRetryCount] = int32(count)
+	headers[HeaderErrorMessage] = handlerErr.Error()
+	if delay := env.BaseEnv().RabbitMQ.RetryDelay; delay > 0 {
+		headers[publisher.RabbitMQDelayHeader] = delay.Milliseconds()
+	}
+
+	return publishWithConfirm(ch, env.BaseEnv().RabbitMQ.ExchangeName, queue, amqp.Publishing{
+		Headers:       headers,
+		ContentType:   message.ContentType,
+		DeliveryMode:  message.DeliveryMode,
+		CorrelationId: message.CorrelationId,
+		MessageId:     message.MessageId,
+		Timestamp:     time.Now(),
+		Body:          message.Body,
+	})
+}
+
+// confirmChannel channel in publisher confirm mode, implemented by *amqp.Channel
+type confirmChannel interface {
+	Confirm(noWait bool) error
+	NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+}
+
+// publishWithConfirm publish message with confirm mode and wait ack from broker until republishConfirmTimeout
+func publishWithConfirm(ch confirmChannel, exchange, key string, msg amqp.Publishing) error {
+	if err := ch.Confirm(false); err != nil {
+		return fmt.Errorf("cannot set confirm mode: %w", err)
+	}
+	confirms := ch.NotifyPublish(make(chan amqp.Confirmation, 1))
+	if err := ch.Publish(exchange, key, false, false, msg); err != nil {
+		return err
+	}
+
+	select {
+	case confirm, ok := <-confirms:
+		if !ok {
+			return errors.New("channel is closed before publish is confirmed")
+		}
+		if !confirm.Ack {
+			return errors.New("publish is nacked by broker")
+		}
+		return nil
+	case <-time.After(republishConfirmTimeout):
+		return errors.New("timeout waiting publish confirm")
+	}
+}
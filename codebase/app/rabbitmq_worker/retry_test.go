@@ -0,0 +1,70 @@
+package rabbitmqworker
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryCount(t *testing.T) {
+	assert.Equal(t, 0, retryCount(nil))
+	assert.Equal(t, 2, retryCount(amqp.Table{HeaderRetryCount: int32(2)}))
+	assert.Equal(t, 3, retryCount(amqp.Table{HeaderRetryCount: int64(3)}))
+	assert.Equal(t, 0, retryCount(amqp.Table{HeaderRetryCount: "3"}))
+}
+
+func TestDeadLetterName(t *testing.T) {
+	assert.Equal(t, "delayed.dlx", DeadLetterExchange("delayed"))
+	assert.Equal(t, "order-queue.dlq", DeadLetterQueue("order-queue"))
+}
+
+// fakeConfirmChannel send confirmation from confirm func after message is published
+type fakeConfirmChannel struct {
+	confirmErr error
+	confirm    func(notify chan amqp.Confirmation)
+	notify     chan amqp.Confirmation
+	published  []amqp.Publishing
+}
+
+func (c *fakeConfirmChannel) Confirm(noWait bool) error { return c.confirmErr }
+func (c *fakeConfirmChannel) NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation {
+	c.notify = confirm
+	return confirm
+}
+func (c *fakeConfirmChannel) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	c.published = append(c.published, msg)
+	c.confirm(c.notify)
+	return nil
+}
+
+func TestPublishWithConfirm(t *testing.T) {
+	tests := []struct {
+		name       string
+		confirmErr error
+		confirm    func(notify chan amqp.Confirmation)
+		wantErr    bool
+	}{
+		{name: "acked", confirm: func(notify chan amqp.Confirmation) { notify <- amqp.Confirmation{DeliveryTag: 1, Ack: true} }},
+		{name: "nacked", confirm: func(notify chan amqp.Confirmation) { notify <- amqp.Confirmation{DeliveryTag: 1} }, wantErr: true},
+		{name: "channel closed", confirm: func(notify chan amqp.Confirmation) { close(notify) }, wantErr: true},
+		{name: "confirm mode not supported", confirmErr: amqp.ErrClosed, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch := &fakeConfirmChannel{confirmErr: tt.confirmErr, confirm: tt.confirm}
+			err := publishWithConfirm(ch, "delayed", "order-queue", amqp.Publishing{Body: []byte("order")})
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func TestIsPreconditionFailed(t *testing.T) {
+	err := fmt.Errorf("error in declaring the queue %w", &amqp.Error{Code: amqp.PreconditionFailed, Reason: "PRECONDITION_FAILED - inequivalent arg 'x-dead-letter-exchange'"})
+	assert.True(t, isPreconditionFailed(err))
+	assert.False(t, isPreconditionFailed(amqp.ErrClosed))
+	assert.False(t, isPreconditionFailed(errors.New("Qos error")))
+	assert.False(t, isPreconditionFailed(nil))
+}
@@ -0,0 +1,93 @@
+package redisstreamworker
+
+import (
+	"fmt"
+
+	"github.com/golangid/candi/config/env"
+	"github.com/golangid/candi/logger"
+	"github.com/gomodule/redigo/redis"
+)
+
+// DeadLetterStream get dead letter stream name of stream
+func DeadLetterStream(stream string) string {
+	return stream + ":dlq"
+}
+
+// moveExceededDelivery move claimed messages which delivered more than REDIS_STREAM_MAX_DELIVERY to dead letter stream,
+// return remaining messages to be processed
+func (r *redisStreamWorker) moveExceededDelivery(stream string, messages []streamMessage) ([]streamMessage, error) {
+	if len(messages) == 0 {
+		return messages, nil
+	}
+
+	deliveries, err := r.deliveryCounts(stream, messages)
+	if err != nil {
+		return nil, err
+	}
+
+	remaining := messages[:0]
+	for _, message := range messages {
+		count := deliveries[message.id]
+		if _, inFlight := r.inFlight.Load(message.stream + ":" + message.id); inFlight || count <= env.BaseEnv().RedisStream.MaxDelivery {
+			remaining = append(remaining, message)
+			continue
+		}
+		if err := r.moveToDeadLetter(message); err != nil {
+			logger.LogRed("redis_stream_consumer > failed move message to dead letter stream: " + err.Error())
+			continue
+		}
+		logger.LogYellow(fmt.Sprintf("redis_stream_consumer > message %s in stream %s is moved to %s after %d delivery",
+			message.id, message.stream, DeadLetterStream(message.stream), count))
+	}
+	return remaining, nil
+}
+
+// deliveryCounts get delivery count of pending messages from XPENDING, pipelined for each message
+func (r *redisStreamWorker) deliveryCounts(stream string, messages []streamMessage) (map[string]int, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	for _, message := range messages {
+		conn.Send("XPENDING", stream, env.BaseEnv().RedisStream.ConsumerGroup, message.id, message.id, 1)
+	}
+	if err := conn.Flush(); err != nil {
+		return nil, err
+	}
+
+	deliveries := make(map[string]int, len(messages))
+	for range messages {
+		// reply format: [[id, consumer, idle, delivery count]], empty if already acknowledged
+		reply, err := redis.Values(conn.Receive())
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range reply {
+			values, err := redis.Values(entry, nil)
+			if err != nil || len(values) != 4 {
+				return nil, fmt.Errorf("invalid xpending reply: %v", err)
+			}
+			id, _ := redis.String(values[0], nil)
+			deliveries[id], _ = redis.Int(values[3], nil)
+		}
+	}
+	return deliveries, nil
+}
+
+// moveToDeadLetter append entry to dead letter stream then acknowledge from consumer group,
+// entry may be duplicated in dead letter stream if acknowledge failed
+func (r *redisStreamWorker) moveToDeadLetter(message streamMessage) error {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	if message.fields != nil {
+		args := redis.Args{DeadLetterStream(message.stream), "*"}
+		for field, value := range message.fields {
+			args = args.Add(field, value)
+		}
+		if _, err := conn.Do("XADD", args...); err != nil {
+			return err
+		}
+	}
+	_, err := conn.Do("XACK", message.stream, env.BaseEnv().RedisStream.ConsumerGroup, message.id)
+	return err
+}
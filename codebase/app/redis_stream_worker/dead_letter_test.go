@@ -0,0 +1,90 @@
+package redisstreamworker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/golangid/candi/codebase/factory/types"
+	"github.com/golangid/candi/config/env"
+	mocks "github.com/golangid/candi/mocks/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClaimPendingMessagesDeadLetter(t *testing.T) {
+	baseEnv := env.BaseEnv()
+	defer env.SetEnv(baseEnv)
+	newEnv := baseEnv
+	newEnv.RedisStream.ConsumerGroup, newEnv.RedisStream.MaxDelivery = "group", 3
+	env.SetEnv(newEnv)
+
+	deliveries := map[string]int64{"1-0": 4, "2-0": 2, "3-0": 5}
+	conn := mocks.NewConn(func(cmd string, args ...interface{}) (interface{}, error) {
+		switch cmd {
+		case "XAUTOCLAIM":
+			return []interface{}{[]byte("0-0"), []interface{}{
+				[]interface{}{[]byte("1-0"), []interface{}{[]byte("message"), []byte("exceeded")}},
+				[]interface{}{[]byte("2-0"), []interface{}{[]byte("message"), []byte("retried")}},
+				[]interface{}{[]byte("3-0"), nil},
+			}}, nil
+		case "XPENDING":
+			id := args[2].(string)
+			return []interface{}{[]interface{}{[]byte(id), []byte("consumer"), int64(60000), deliveries[id]}}, nil
+		case "XADD":
+			return []byte("4-0"), nil
+		}
+		return int64(1), nil
+	})
+
+	var processed []string
+	worker := &redisStreamWorker{
+		ctx:  context.Background(),
+		pool: conn.Pool(),
+		handlers: map[string]handlerType{"orders": {handlerFunc: func(ctx context.Context, message []byte) error {
+			processed = append(processed, string(message))
+			return nil
+		}}},
+		semaphore: make(chan struct{}, 1),
+	}
+	assert.NoError(t, worker.claimPendingMessages("orders"))
+	worker.wg.Wait()
+
+	assert.Equal(t, []string{"retried"}, processed)
+	cmds := conn.Commands()
+	assert.Contains(t, cmds, "XADD orders:dlq * message exceeded")
+	assert.Contains(t, cmds, "XACK orders group 1-0")
+	assert.Contains(t, cmds, "XACK orders group 2-0")
+	assert.Contains(t, cmds, "XACK orders group 3-0")
+	assert.Len(t, filterPrefix(cmds, "XADD"), 1, "deleted entry is only acknowledged")
+}
+
+func filterPrefix(cmds []string, prefix string) (filtered []string) {
+	for _, cmd := range cmds {
+		if strings.HasPrefix(cmd, prefix) {
+			filtered = append(filtered, cmd)
+		}
+	}
+	return filtered
+}
+
+func TestDispatchFailedMessageNotAcknowledged(t *testing.T) {
+	conn := mocks.NewConn(func(cmd string, args ...interface{}) (interface{}, error) { return int64(1), nil })
+	var handledErr error
+	worker := &redisStreamWorker{
+		ctx:  context.Background(),
+		pool: conn.Pool(),
+		handlers: map[string]handlerType{"orders": {
+			handlerFunc: func(ctx context.Context, message []byte) error { return fmt.Errorf("failed") },
+			errorHandlers: []types.WorkerErrorHandler{func(ctx context.Context, workerType types.Worker, stream string, message []byte, err error) {
+				handledErr = err
+			}},
+		}},
+		semaphore: make(chan struct{}, 1),
+	}
+	worker.dispatch(streamMessage{stream: "orders", id: "1-0", fields: map[string]string{"message": "hello"}})
+	worker.wg.Wait()
+
+	assert.EqualError(t, handledErr, "failed")
+	assert.Empty(t, conn.Commands(), "failed message is claimed again later")
+}
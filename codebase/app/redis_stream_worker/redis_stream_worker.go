@@ -0,0 +1,345 @@
+package redisstreamworker
+
+// Redis stream consumer group worker codebase
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golangid/candi/codebase/factory"
+	"github.com/golangid/candi/codebase/factory/types"
+	"github.com/golangid/candi/config/env"
+	"github.com/golangid/candi/logger"
+	"github.com/golangid/candi/publisher"
+	"github.com/golangid/candi/tracer"
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	blockTimeout = 2 * time.Second
+)
+
+type handlerType struct {
+	handlerFunc   types.WorkerHandlerFunc
+	errorHandlers []types.WorkerErrorHandler
+}
+
+type redisStreamWorker struct {
+	ctx           context.Context
+	ctxCancelFunc func()
+
+	pool      *redis.Pool
+	handlers  map[string]handlerType
+	streams   []string
+	inFlight  sync.Map
+	shutdown  chan struct{}
+	semaphore chan struct{}
+	wg        sync.WaitGroup
+}
+
+// NewWorker create new redis stream consumer, stream name from pattern in WorkerHandlerGroup
+func NewWorker(service factory.ServiceFactory) factory.AppServerFactory {
+	if service.GetDependency().GetRedisPool() == nil {
+		panic("Missing Redis configuration")
+	}
+
+	worker := &redisStreamWorker{
+		pool:      service.GetDependency().GetRedisPool().WritePool(),
+		handlers:  make(map[string]handlerType),
+		shutdown:  make(chan struct{}),
+		semaphore: make(chan struct{}, env.BaseEnv().MaxGoroutines),
+	}
+
+	for _, m := range service.GetModules() {
+		if h := m.WorkerHandler(types.RedisStream); h != nil {
+			var handlerGroup types.WorkerHandlerGroup
+			h.MountHandlers(&handlerGroup)
+			for _, handler := range handlerGroup.Handlers {
+				handler.RejectBatchHandler(types.RedisStream)
+				logger.LogYellow(fmt.Sprintf(`[REDIS-STREAM-CONSUMER] (stream): %-15s  --> (module): "%s"`, `"`+handler.Pattern+`"`, m.Name()))
+				if err := worker.createConsumerGroup(handler.Pattern); err != nil {
+					panic(fmt.Errorf("Redis stream %s: %v", handler.Pattern, err))
+				}
+				worker.streams = append(worker.streams, handler.Pattern)
+				worker.handlers[handler.Pattern] = handlerType{
+					handlerFunc: handler.HandlerFunc, errorHandlers: handler.ErrorHandler,
+				}
+			}
+		}
+	}
+
+	if len(worker.streams) == 0 {
+		log.Println("redis stream consumer: no stream provided")
+	} else {
+		fmt.Printf("\x1b[34;1m⇨ Redis stream consumer running with %d stream. Consumer group: %s\x1b[0m\n\n",
+			len(worker.streams), env.BaseEnv().RedisStream.ConsumerGroup)
+	}
+
+	worker.ctx, worker.ctxCancelFunc = context.WithCancel(context.Background())
+	return worker
+}
+
+func (r *redisStreamWorker) Serve() {
+	if len(r.streams) == 0 {
+		return
+	}
+
+	go r.runClaimer()
+
+	var wg sync.WaitGroup
+	for _, streams := range r.streamGroups() {
+		wg.Add(1)
+		go func(streams []string) {
+			defer wg.Done()
+			r.consume(streams)
+		}(streams)
+	}
+	wg.Wait()
+}
+
+// consume read new messages from streams until shutdown
+func (r *redisStreamWorker) consume(streams []string) {
+	for {
+		select {
+		case <-r.shutdown:
+			return
+		default:
+		}
+
+		messages, err := r.readGroup(streams)
+		if err != nil {
+			logger.LogRed("redis_stream_consumer > failed read stream: " + err.Error())
+			time.Sleep(time.Second)
+			continue
+		}
+		for _, message := range messages {
+			r.dispatch(message)
+		}
+	}
+}
+
+func (r *redisStreamWorker) Shutdown(ctx context.Context) {
+	log.Println("\x1b[33;1mStopping Redis Stream Consumer worker...\x1b[0m")
+	defer log.Println("\x1b[33;1mStopping Redis Stream Consumer:\x1b[0m \x1b[32;1mSUCCESS\x1b[0m")
+
+	r.ctxCancelFunc()
+	if len(r.streams) == 0 {
+		return
+	}
+
+	close(r.shutdown)
+	runningJob := len(r.semaphore)
+	if runningJob != 0 {
+		fmt.Printf("\x1b[34;1mRedis Stream Consumer:\x1b[0m waiting %d job until done...\n", runningJob)
+	}
+
+	r.wg.Wait()
+}
+
+func (r *redisStreamWorker) Name() string {
+	return string(types.RedisStream)
+}
+
+// createConsumerGroup create consumer group (and stream if not exist), start from the first entry of stream
+func (r *redisStreamWorker) createConsumerGroup(stream string) error {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("XGROUP", "CREATE", stream, env.BaseEnv().RedisStream.ConsumerGroup, "0", "MKSTREAM")
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		// consumer group already exist
+		return nil
+	}
+	return err
+}
+
+// streamGroups group streams which read in one XREADGROUP. In redis cluster mode each stream is read with its own connection,
+// because all streams in one command must be in the same slot (CROSSSLOT error)
+func (r *redisStreamWorker) streamGroups() [][]string {
+	if env.BaseEnv().Redis.Mode != "cluster" {
+		return [][]string{r.streams}
+	}
+	groups := make([][]string, 0, len(r.streams))
+	for _, stream := range r.streams {
+		groups = append(groups, []string{stream})
+	}
+	return groups
+}
+
+// readGroup read new messages (never delivered to other consumers) from streams
+func (r *redisStreamWorker) readGroup(streams []string) ([]streamMessage, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	args := redis.Args{
+		"GROUP", env.BaseEnv().RedisStream.ConsumerGroup, env.BaseEnv().RedisStream.ConsumerName,
+		"COUNT", env.BaseEnv().RedisStream.BatchSize, "BLOCK", blockTimeout.Milliseconds(), "STREAMS",
+	}
+	args = args.AddFlat(streams)
+	for range streams {
+		args = args.Add(">")
+	}
+
+	reply, err := redis.Values(conn.Do("XREADGROUP", args...))
+	if err == redis.ErrNil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []streamMessage
+	for _, streamReply := range reply {
+		values, err := redis.Values(streamReply, nil)
+		if err != nil || len(values) != 2 {
+			return nil, fmt.Errorf("invalid stream reply: %v", err)
+		}
+		stream, _ := redis.String(values[0], nil)
+		entries, err := parseStreamEntries(stream, values[1])
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, entries...)
+	}
+	return messages, nil
+}
+
+// runClaimer claim pending messages (handler return error or consumer is down) which idle more than REDIS_STREAM_CLAIM_MIN_IDLE,
+// message which delivered more than REDIS_STREAM_MAX_DELIVERY is moved to dead letter stream
+func (r *redisStreamWorker) runClaimer() {
+	interval := env.BaseEnv().RedisStream.ClaimMinIdle / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.shutdown:
+			return
+		case <-ticker.C:
+			for _, stream := range r.streams {
+				if err := r.claimPendingMessages(stream); err != nil {
+					logger.LogRed("redis_stream_consumer > failed claim pending message: " + err.Error())
+				}
+			}
+		}
+	}
+}
+
+func (r *redisStreamWorker) claimPendingMessages(stream string) error {
+	cursor := "0-0"
+	for {
+		messages, next, err := r.autoClaim(stream, cursor)
+		if err != nil {
+			return err
+		}
+		if messages, err = r.moveExceededDelivery(stream, messages); err != nil {
+			return err
+		}
+		for _, message := range messages {
+			r.dispatch(message)
+		}
+		if next == "0-0" || next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+func (r *redisStreamWorker) autoClaim(stream, cursor string) (messages []streamMessage, next string, err error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	reply, err := redis.Values(conn.Do("XAUTOCLAIM", stream,
+		env.BaseEnv().RedisStream.ConsumerGroup, env.BaseEnv().RedisStream.ConsumerName,
+		env.BaseEnv().RedisStream.ClaimMinIdle.Milliseconds(), cursor, "COUNT", env.BaseEnv().RedisStream.BatchSize))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(reply) < 2 {
+		return nil, "", fmt.Errorf("invalid xautoclaim reply")
+	}
+
+	next, _ = redis.String(reply[0], nil)
+	messages, err = parseStreamEntries(stream, reply[1])
+	return messages, next, err
+}
+
+// dispatch process message in new goroutine, skip if message is still processed by this consumer
+func (r *redisStreamWorker) dispatch(message streamMessage) {
+	key := message.stream + ":" + message.id
+	if _, loaded := r.inFlight.LoadOrStore(key, struct{}{}); loaded {
+		return
+	}
+
+	r.semaphore <- struct{}{}
+	r.wg.Add(1)
+	go func() {
+		defer func() {
+			r.inFlight.Delete(key)
+			r.wg.Done()
+			<-r.semaphore
+		}()
+
+		if r.ctx.Err() != nil {
+			logger.LogRed("redis_stream_consumer > ctx root err: " + r.ctx.Err().Error())
+			return
+		}
+		r.processMessage(message)
+	}()
+}
+
+func (r *redisStreamWorker) processMessage(message streamMessage) {
+	if message.fields == nil {
+		// entry already deleted from stream
+		r.ack(message)
+		return
+	}
+
+	var err error
+	trace, ctx := tracer.StartTraceWithContext(r.ctx, "RedisStreamConsumer")
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+		trace.SetError(err)
+		logger.LogGreen("redis_stream_consumer > trace_url: " + tracer.GetTraceURL(ctx))
+		trace.Finish()
+	}()
+
+	data := []byte(message.fields[publisher.RedisStreamMessageField])
+	if env.BaseEnv().DebugMode {
+		log.Printf("\x1b[35;3mRedis Stream Consumer: message consumed, stream = %s, id = %s\x1b[0m", message.stream, message.id)
+	}
+
+	trace.SetTag("stream", message.stream)
+	trace.SetTag("message_id", message.id)
+	trace.SetTag("consumer_group", env.BaseEnv().RedisStream.ConsumerGroup)
+	trace.SetTag("key", message.fields[publisher.RedisStreamKeyField])
+	trace.Log("fields", message.fields)
+
+	selectedHandler := r.handlers[message.stream]
+	if err = selectedHandler.handlerFunc(ctx, data); err != nil {
+		// message is not acknowledged, will be claimed again after REDIS_STREAM_CLAIM_MIN_IDLE until REDIS_STREAM_MAX_DELIVERY
+		for _, errHandler := range selectedHandler.errorHandlers {
+			errHandler(ctx, types.RedisStream, message.stream, data, err)
+		}
+		return
+	}
+	r.ack(message)
+}
+
+func (r *redisStreamWorker) ack(message streamMessage) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("XACK", message.stream, env.BaseEnv().RedisStream.ConsumerGroup, message.id); err != nil {
+		logger.LogRed("redis_stream_consumer > failed ack message: " + err.Error())
+	}
+}
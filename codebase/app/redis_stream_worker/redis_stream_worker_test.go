@@ -0,0 +1,44 @@
+package redisstreamworker
+
+import (
+	"testing"
+
+	"github.com/golangid/candi/config/env"
+	mocks "github.com/golangid/candi/mocks/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamGroups(t *testing.T) {
+	baseEnv := env.BaseEnv()
+	defer env.SetEnv(baseEnv)
+
+	worker := &redisStreamWorker{streams: []string{"orders", "payments"}}
+	assert.Equal(t, [][]string{{"orders", "payments"}}, worker.streamGroups())
+
+	newEnv := baseEnv
+	newEnv.Redis.Mode = "cluster"
+	env.SetEnv(newEnv)
+	assert.Equal(t, [][]string{{"orders"}, {"payments"}}, worker.streamGroups(), "streams in different slot cannot be read in one command")
+}
+
+func TestReadGroup(t *testing.T) {
+	baseEnv := env.BaseEnv()
+	defer env.SetEnv(baseEnv)
+	newEnv := baseEnv
+	newEnv.RedisStream.ConsumerGroup, newEnv.RedisStream.ConsumerName, newEnv.RedisStream.BatchSize = "group", "consumer", 10
+	env.SetEnv(newEnv)
+
+	conn := mocks.NewConn(func(cmd string, args ...interface{}) (interface{}, error) {
+		return []interface{}{
+			[]interface{}{[]byte("payments"), []interface{}{
+				[]interface{}{[]byte("1-0"), []interface{}{[]byte("message"), []byte("paid")}},
+			}},
+		}, nil
+	})
+	worker := &redisStreamWorker{pool: conn.Pool(), streams: []string{"orders", "payments"}}
+
+	messages, err := worker.readGroup([]string{"payments"})
+	assert.NoError(t, err)
+	assert.Equal(t, []streamMessage{{stream: "payments", id: "1-0", fields: map[string]string{"message": "paid"}}}, messages)
+	assert.Equal(t, []string{"XREADGROUP GROUP group consumer COUNT 10 BLOCK 2000 STREAMS payments >"}, conn.Commands())
+}
@@ -0,0 +1,46 @@
+package redisstreamworker
+
+import (
+	"fmt"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+type streamMessage struct {
+	stream string
+	id     string
+	// fields is nil if entry already deleted from stream (still in pending list)
+	fields map[string]string
+}
+
+// parseStreamEntries parse stream entries reply, format: [[id, [field, value, ...]], ...]
+func parseStreamEntries(stream string, reply interface{}) ([]streamMessage, error) {
+	entries, err := redis.Values(reply, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]streamMessage, 0, len(entries))
+	for _, entry := range entries {
+		if entry == nil {
+			// deleted entry in xautoclaim reply (redis < 7.0)
+			continue
+		}
+		values, err := redis.Values(entry, nil)
+		if err != nil || len(values) != 2 {
+			return nil, fmt.Errorf("invalid stream entry: %v", err)
+		}
+
+		message := streamMessage{stream: stream}
+		if message.id, err = redis.String(values[0], nil); err != nil {
+			return nil, err
+		}
+		if values[1] != nil {
+			if message.fields, err = redis.StringMap(values[1], nil); err != nil {
+				return nil, err
+			}
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
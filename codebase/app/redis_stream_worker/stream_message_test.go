@@ -0,0 +1,24 @@
+package redisstreamworker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStreamEntries(t *testing.T) {
+	reply := []interface{}{
+		[]interface{}{[]byte("1-0"), []interface{}{[]byte("message"), []byte("hello"), []byte("key"), []byte("order-1")}},
+		nil, // deleted entry in xautoclaim reply
+		[]interface{}{[]byte("2-0"), nil},
+	}
+	messages, err := parseStreamEntries("orders", reply)
+	assert.NoError(t, err)
+	assert.Equal(t, []streamMessage{
+		{stream: "orders", id: "1-0", fields: map[string]string{"message": "hello", "key": "order-1"}},
+		{stream: "orders", id: "2-0"},
+	}, messages)
+
+	_, err = parseStreamEntries("orders", []interface{}{[]interface{}{[]byte("1-0")}})
+	assert.Error(t, err)
+}
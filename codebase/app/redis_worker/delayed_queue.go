@@ -0,0 +1,159 @@
+package redisworker
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golangid/candi/candiutils"
+	"github.com/golangid/candi/codebase/factory/types"
+	"github.com/golangid/candi/config/env"
+	"github.com/golangid/candi/logger"
+)
+
+// ErrDeadLetter error passed to error handlers when delayed message is moved to dead letter list
+var ErrDeadLetter = errors.New("message is moved to dead letter")
+
+// serveDelayedQueue claim due messages from sorted set delayed queue periodically,
+// and recover claimed messages which not acknowledged until visibility timeout (instance is down when processing message)
+func (r *redisWorker) serveDelayedQueue() {
+	pollTicker := time.NewTicker(env.BaseEnv().RedisWorker.PollInterval)
+	defer pollTicker.Stop()
+	recoverTicker := time.NewTicker(r.queue.VisibilityTimeout() / 2)
+	defer recoverTicker.Stop()
+
+	for {
+		select {
+		case <-shutdown:
+			return
+
+		case <-recoverTicker.C:
+			total, err := r.queue.Recover(r.ctx, env.BaseEnv().MaxGoroutines)
+			if err != nil {
+				logger.LogRed("redis_subscriber > failed recover delayed queue: " + err.Error())
+			} else if total > 0 {
+				logger.LogYellow(fmt.Sprintf("redis_subscriber > recover %d unacknowledged messages", total))
+			}
+
+		case <-pollTicker.C:
+			r.claimDueMessages()
+		}
+	}
+}
+
+// claimDueMessages claim all due messages, limited by available goroutines in each claim
+func (r *redisWorker) claimDueMessages() {
+	for r.ctx.Err() == nil {
+		limit := cap(semaphore) - len(semaphore)
+		if limit <= 0 {
+			return
+		}
+
+		messages, err := r.queue.Claim(r.ctx, limit)
+		if err != nil {
+			logger.LogRed("redis_subscriber > failed claim delayed queue: " + err.Error())
+			return
+		}
+		for _, message := range messages {
+			r.dispatchDelayedMessage(message)
+		}
+		if len(messages) < limit {
+			return
+		}
+	}
+}
+
+// dispatchDelayedMessage process message in new goroutine, message is acknowledged when handler return nil or retried when error
+// (error from handler is passed to error handlers), unacknowledged message will be recovered after visibility timeout.
+// Message without handler or failed after REDIS_WORKER_MAX_RETRY is moved to dead letter list
+func (r *redisWorker) dispatchDelayedMessage(message candiutils.DelayedMessage) {
+	if message.Message == nil {
+		logger.LogYellow(fmt.Sprintf("redis_subscriber > drop message %s, payload already deleted", message.ID))
+		r.ackDelayedMessage(&message)
+		return
+	}
+	if _, ok := r.handlers[message.HandlerName]; !ok {
+		r.deadLetterDelayedMessage(&message, fmt.Errorf("handler \"%s\" not found", message.HandlerName))
+		return
+	}
+
+	semaphore <- struct{}{}
+	r.wg.Add(1)
+	go func() {
+		defer func() {
+			r.wg.Done()
+			<-semaphore
+		}()
+
+		if r.ctx.Err() != nil {
+			logger.LogRed("redis_subscriber > ctx root err: " + r.ctx.Err().Error())
+			return
+		}
+
+		stopExtend := r.extendVisibility(&message)
+		err := r.processMessage(message.HandlerName, message.Message)
+		stopExtend()
+
+		switch {
+		case err == nil:
+			r.ackDelayedMessage(&message)
+		case message.Attempt > env.BaseEnv().RedisWorker.MaxRetry:
+			r.deadLetterDelayedMessage(&message, err)
+		default:
+			if err := r.queue.Retry(r.ctx, &message, env.BaseEnv().RedisWorker.RetryDelay); err != nil {
+				logger.LogRed("redis_subscriber > failed retry message: " + err.Error())
+			}
+		}
+	}()
+}
+
+// extendVisibility extend visibility deadline of claimed message periodically until stopped,
+// so that message is not recovered and delivered twice while handler is running
+func (r *redisWorker) extendVisibility(message *candiutils.DelayedMessage) (stop func()) {
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(r.queue.VisibilityTimeout() / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := r.queue.Extend(r.ctx, message); err != nil {
+					logger.LogRed(fmt.Sprintf("redis_subscriber > failed extend visibility of message %s: %v", message.ID, err))
+					if err == candiutils.ErrDelayedMessageReclaimed {
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func (r *redisWorker) ackDelayedMessage(message *candiutils.DelayedMessage) {
+	if err := r.queue.Ack(r.ctx, message); err != nil {
+		logger.LogRed("redis_subscriber > failed ack message: " + err.Error())
+	}
+}
+
+// deadLetterDelayedMessage move message to dead letter list, error handlers are called with ErrDeadLetter
+func (r *redisWorker) deadLetterDelayedMessage(message *candiutils.DelayedMessage, reason error) {
+	if err := r.queue.DeadLetter(r.ctx, message, reason); err != nil {
+		logger.LogRed("redis_subscriber > failed move message to dead letter: " + err.Error())
+		return
+	}
+	logger.LogYellow(fmt.Sprintf("redis_subscriber > message %s is moved to %s after %d attempt: %v",
+		message.ID, r.queue.DeadLetterKey(), message.Attempt, reason))
+
+	err := fmt.Errorf("%w %s after %d attempt: %v", ErrDeadLetter, r.queue.DeadLetterKey(), message.Attempt, reason)
+	for _, errHandler := range r.handlers[message.HandlerName].errorHandlers {
+		errHandler(r.ctx, types.RedisSubscriber, message.HandlerName, message.Message, err)
+	}
+}
@@ -0,0 +1,158 @@
+package redisworker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golangid/candi/candiutils"
+	"github.com/golangid/candi/codebase/factory/types"
+	"github.com/golangid/candi/config/env"
+	mocks "github.com/golangid/candi/mocks/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+// newScriptConn redis connection for test, reply claimed for all delayed queue script
+func newScriptConn() *mocks.Conn {
+	return mocks.NewConn(func(cmd string, args ...interface{}) (interface{}, error) {
+		if cmd != "EVALSHA" {
+			return nil, nil
+		}
+		return int64(1), nil
+	})
+}
+
+// scripts get called script name from total keys of EVALSHA
+func scripts(conn *mocks.Conn) (scripts []string) {
+	for _, call := range conn.Calls() {
+		if call.Name != "EVALSHA" {
+			continue
+		}
+		switch call.Args[1].(int) {
+		case 1:
+			scripts = append(scripts, "extend")
+		case 2:
+			scripts = append(scripts, "retry")
+		case 3:
+			scripts = append(scripts, "ack")
+		case 4:
+			scripts = append(scripts, "dead letter")
+		}
+	}
+	return scripts
+}
+
+func newTestDelayedQueueWorker(conn *mocks.Conn, visibilityTimeout time.Duration, handlerFunc types.WorkerHandlerFunc) *redisWorker {
+	if semaphore == nil {
+		semaphore = make(chan struct{}, 1)
+	}
+	worker := &redisWorker{
+		ctx:   context.Background(),
+		queue: candiutils.NewRedisDelayedQueue(conn.Pool(), "queue", visibilityTimeout),
+		handlers: map[string]struct {
+			handlerFunc   types.WorkerHandlerFunc
+			errorHandlers []types.WorkerErrorHandler
+		}{},
+	}
+	worker.handlers["push-notif"] = struct {
+		handlerFunc   types.WorkerHandlerFunc
+		errorHandlers []types.WorkerErrorHandler
+	}{handlerFunc: handlerFunc}
+	return worker
+}
+
+func TestDispatchDelayedMessage(t *testing.T) {
+	baseEnv := env.BaseEnv()
+	defer env.SetEnv(baseEnv)
+	newEnv := baseEnv
+	newEnv.RedisWorker.MaxRetry = 2
+	env.SetEnv(newEnv)
+
+	failed := func(ctx context.Context, message []byte) error { return errors.New("failed") }
+	tests := []struct {
+		name        string
+		attempt     int
+		handlerFunc types.WorkerHandlerFunc
+		want        []string
+	}{
+		{name: "success", attempt: 1, handlerFunc: func(ctx context.Context, message []byte) error { return nil }, want: []string{"ack"}},
+		{name: "failed is retried", attempt: 2, handlerFunc: failed, want: []string{"retry"}},
+		{name: "failed after max retry is moved to dead letter", attempt: 3, handlerFunc: failed, want: []string{"dead letter"}},
+		{name: "panic is retried", attempt: 1, handlerFunc: func(ctx context.Context, message []byte) error { panic("nil pointer") }, want: []string{"retry"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newScriptConn()
+			worker := newTestDelayedQueueWorker(conn, time.Minute, tt.handlerFunc)
+			worker.dispatchDelayedMessage(candiutils.DelayedMessage{
+				ID: "id-1", HandlerName: "push-notif", Message: []byte("hello"), Attempt: tt.attempt,
+			})
+			worker.wg.Wait()
+			assert.Equal(t, tt.want, scripts(conn))
+		})
+	}
+
+	conn := newScriptConn()
+	worker := newTestDelayedQueueWorker(conn, time.Minute, failed)
+	worker.dispatchDelayedMessage(candiutils.DelayedMessage{ID: "id-1", HandlerName: "unknown", Message: []byte("hello")})
+	worker.wg.Wait()
+	assert.Equal(t, []string{"dead letter"}, scripts(conn), "message without handler is moved to dead letter")
+
+	conn = newScriptConn()
+	worker = newTestDelayedQueueWorker(conn, time.Minute, failed)
+	worker.dispatchDelayedMessage(candiutils.DelayedMessage{ID: "id-1", HandlerName: "push-notif"})
+	worker.wg.Wait()
+	assert.Equal(t, []string{"ack"}, scripts(conn), "message which payload already deleted is dropped")
+}
+
+func TestDispatchDelayedMessageDeadLetter(t *testing.T) {
+	baseEnv := env.BaseEnv()
+	defer env.SetEnv(baseEnv)
+	newEnv := baseEnv
+	newEnv.RedisWorker.MaxRetry = 0
+	env.SetEnv(newEnv)
+
+	conn := newScriptConn()
+	worker := newTestDelayedQueueWorker(conn, time.Minute, func(ctx context.Context, message []byte) error { return errors.New("failed") })
+	var handledErrs []error
+	handler := worker.handlers["push-notif"]
+	handler.errorHandlers = []types.WorkerErrorHandler{func(ctx context.Context, workerType types.Worker, handlerName string, message []byte, err error) {
+		handledErrs = append(handledErrs, err)
+	}}
+	worker.handlers["push-notif"] = handler
+
+	worker.dispatchDelayedMessage(candiutils.DelayedMessage{ID: "id-1", HandlerName: "push-notif", Message: []byte("hello"), Attempt: 1})
+	worker.wg.Wait()
+
+	if assert.Len(t, handledErrs, 2) {
+		assert.EqualError(t, handledErrs[0], "failed")
+		assert.True(t, errors.Is(handledErrs[1], ErrDeadLetter))
+		assert.EqualError(t, handledErrs[1], "message is moved to dead letter {queue}:dlq after 1 attempt: failed")
+	}
+
+	calls := conn.Calls()
+	deadLetter := calls[len(calls)-1]
+	assert.Equal(t, []interface{}{"{queue}:processing", "{queue}:payload", "{queue}:attempt", "{queue}:dlq"}, deadLetter.Args[2:6])
+	var entry candiutils.DelayedDeadLetter
+	assert.NoError(t, json.Unmarshal(deadLetter.Args[8].([]byte), &entry))
+	assert.Equal(t, "push-notif", entry.HandlerName)
+	assert.Equal(t, "hello", entry.Message)
+	assert.Equal(t, "failed", entry.Error)
+}
+
+func TestDispatchDelayedMessageExtendVisibility(t *testing.T) {
+	conn := newScriptConn()
+	worker := newTestDelayedQueueWorker(conn, 30*time.Millisecond, func(ctx context.Context, message []byte) error {
+		// long running handler, longer than visibility timeout
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	})
+	worker.dispatchDelayedMessage(candiutils.DelayedMessage{ID: "id-1", HandlerName: "push-notif", Message: []byte("hello"), Attempt: 1})
+	worker.wg.Wait()
+
+	scripts := scripts(conn)
+	assert.Contains(t, scripts, "extend")
+	assert.Equal(t, "ack", scripts[len(scripts)-1])
+}
@@ -37,7 +37,8 @@ type (
 			handlerFunc   types.WorkerHandlerFunc
 			errorHandlers []types.WorkerErrorHandler
 		}
-		consul *candiutils.Consul
+		locker candiutils.DistributedLocker
+		queue  *candiutils.RedisDelayedQueue
 		wg     sync.WaitGroup
 	}
 )
@@ -55,6 +56,7 @@ func NewWorker(service factory.ServiceFactory) factory.AppServerFactory {
 			var handlerGroup types.WorkerHandlerGroup
 			h.MountHandlers(&handlerGroup)
 			for _, handler := range handlerGroup.Handlers {
+				handler.RejectBatchHandler(types.RedisSubscriber)
 				logger.LogYellow(fmt.Sprintf(`[REDIS-SUBSCRIBER] (key prefix): %-15s  --> (module): "%s"`, `"`+handler.Pattern+`"`, m.Name()))
 				handlers[strings.Replace(handler.Pattern, "~", "", -1)] = struct {
 					handlerFunc   types.WorkerHandlerFunc
@@ -66,6 +68,10 @@ func NewWorker(service factory.ServiceFactory) factory.AppServerFactory {
 		}
 	}
 
+	if len(handlers) > 0 && env.BaseEnv().Redis.Mode == "cluster" && env.BaseEnv().RedisWorker.QueueMode == "keyspace" {
+		// key expired notification is published only in node of the key
+		panic("Redis Worker: keyspace mode is not supported in redis cluster, set REDIS_WORKER_QUEUE_MODE=sorted_set")
+	}
 	if len(handlers) == 0 {
 		log.Println("redis subscriber: no topic provided")
 	} else {
@@ -91,16 +97,24 @@ func NewWorker(service factory.ServiceFactory) factory.AppServerFactory {
 		isHaveJob: len(handlers) != 0,
 	}
 
-	if env.BaseEnv().UseConsul {
-		consul, err := candiutils.NewConsul(&candiutils.ConsulConfig{
-			ConsulAgentHost:   env.BaseEnv().ConsulAgentHost,
-			ConsulKey:         fmt.Sprintf("%s_redis_worker", service.Name()),
+	if env.BaseEnv().RedisWorker.QueueMode == "sorted_set" {
+		workerInstance.queue = candiutils.NewRedisDelayedQueue(redisPool, env.BaseEnv().RedisWorker.QueueKey,
+			env.BaseEnv().RedisWorker.VisibilityTimeout)
+	}
+
+	if env.BaseEnv().DistributedLockBackend != "" && workerInstance.queue == nil {
+		locker, err := candiutils.NewDistributedLocker(&candiutils.DistributedLockerConfig{
+			Backend:           env.BaseEnv().DistributedLockBackend,
+			Key:               fmt.Sprintf("%s_redis_worker", service.Name()),
 			LockRetryInterval: 1 * time.Second,
+			ConsulAgentHost:   env.BaseEnv().ConsulAgentHost,
+			RedisPool:         service.GetDependency().GetRedisPool(),
+			SQLDatabase:       service.GetDependency().GetSQLDatabase(),
 		})
 		if err != nil {
 			panic(err)
 		}
-		workerInstance.consul = consul
+		workerInstance.locker = locker
 	}
 	workerInstance.ctx, workerInstance.ctxCancelFunc = context.WithCancel(context.Background())
 
@@ -111,8 +125,13 @@ func (r *redisWorker) Serve() {
 	if !r.isHaveJob {
 		return
 	}
+	if r.queue != nil {
+		// claim in sorted set delayed queue is atomic, no need distributed lock
+		r.serveDelayedQueue()
+		return
+	}
 
-	r.createConsulSession()
+	r.createLockSession()
 	subFunc := r.pubSubConn()
 
 START:
@@ -127,15 +146,23 @@ START:
 		for {
 			select {
 			case count := <-countJobs:
-				if r.consul != nil && count == env.BaseEnv().ConsulMaxJobRebalance {
+				if r.locker != nil && count == env.BaseEnv().ConsulMaxJobRebalance {
 					// recreate session
-					r.createConsulSession()
+					r.createLockSession()
 					<-releaseWorkerCh
 					psc.PUnsubscribe()
 					go func() { stopListener <- struct{}{} }()
 					goto START
 				}
 
+			case <-releaseWorkerCh:
+				// distributed lock is lost, stop listener and wait until lock acquired again
+				logger.LogYellow("Redis Subscriber: distributed lock is lost, stop listener")
+				r.createLockSession()
+				psc.PUnsubscribe()
+				go func() { stopListener <- struct{}{} }()
+				goto START
+
 			case <-shutdown:
 				go func() { stopListener <- struct{}{} }()
 				return
@@ -150,8 +177,8 @@ START:
 func (r *redisWorker) Shutdown(ctx context.Context) {
 	log.Println("\x1b[33;1mStopping Redis Subscriber worker...\x1b[0m")
 	defer func() {
-		if r.consul != nil {
-			if err := r.consul.DestroySession(); err != nil {
+		if r.locker != nil {
+			if err := r.locker.DestroySession(); err != nil {
 				panic(err)
 			}
 		}
@@ -176,17 +203,17 @@ func (r *redisWorker) Name() string {
 	return string(types.RedisSubscriber)
 }
 
-func (r *redisWorker) createConsulSession() {
-	if r.consul == nil {
+func (r *redisWorker) createLockSession() {
+	if r.locker == nil {
 		go func() { startWorkerCh <- struct{}{} }()
 		return
 	}
-	r.consul.DestroySession()
+	r.locker.DestroySession()
 	hostname, _ := os.Hostname()
 	value := map[string]string{
 		"hostname": hostname,
 	}
-	go r.consul.RetryLockAcquire(value, startWorkerCh, releaseWorkerCh)
+	go r.locker.RetryLockAcquire(value, startWorkerCh, releaseWorkerCh)
 }
 
 func (r *redisWorker) runListener(stop <-chan struct{}, count chan<- int, psc *redis.PubSubConn) {
@@ -239,11 +266,12 @@ func (r *redisWorker) runListener(stop <-chan struct{}, count chan<- int, psc *r
 	}
 }
 
-func (r *redisWorker) processMessage(handlerName string, message []byte) {
+func (r *redisWorker) processMessage(handlerName string, message []byte) (err error) {
 	trace, ctx := tracer.StartTraceWithContext(r.ctx, "RedisSubscriber")
 	defer func() {
 		if r := recover(); r != nil {
-			tracer.SetError(ctx, fmt.Errorf("%v", r))
+			err = fmt.Errorf("%v", r)
+			tracer.SetError(ctx, err)
 		}
 		logger.LogGreen("redis_subscriber > trace_url: " + tracer.GetTraceURL(ctx))
 		trace.Finish()
@@ -257,10 +285,11 @@ func (r *redisWorker) processMessage(handlerName string, message []byte) {
 	trace.SetTag("message", string(message))
 
 	handler := r.handlers[handlerName]
-	if err := handler.handlerFunc(ctx, message); err != nil {
+	if err = handler.handlerFunc(ctx, message); err != nil {
 		for _, errHandler := range handler.errorHandlers {
 			errHandler(ctx, types.RedisSubscriber, handlerName, message, err)
 		}
 		tracer.SetError(ctx, err)
 	}
+	return err
 }
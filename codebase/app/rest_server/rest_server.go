@@ -9,15 +9,18 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/Shopify/sarama"
 	"github.com/labstack/echo"
 	echoMidd "github.com/labstack/echo/middleware"
 	"github.com/soheilhy/cmux"
 
 	"github.com/golangid/candi/candihelper"
 	"github.com/golangid/candi/candishared"
+	cronworker "github.com/golangid/candi/codebase/app/cron_worker"
 	graphqlserver "github.com/golangid/candi/codebase/app/graphql_server"
 	"github.com/golangid/candi/codebase/factory"
 	"github.com/golangid/candi/codebase/factory/types"
+	"github.com/golangid/candi/config/broker"
 	"github.com/golangid/candi/config/env"
 	"github.com/golangid/candi/logger"
 	"github.com/golangid/candi/wrapper"
@@ -50,6 +53,23 @@ func NewServer(service factory.ServiceFactory, muxListener cmux.CMux) factory.Ap
 		echo.WrapHandler(http.HandlerFunc(candishared.HTTPMemstatsHandler)),
 		echo.WrapMiddleware(service.GetDependency().GetMiddleware().HTTPBasicAuth))
 
+	// kafka consumer lag metrics in prometheus format
+	if env.BaseEnv().UseKafkaConsumer {
+		if client, ok := service.GetDependency().GetBroker().GetConfiguration(types.Kafka).(sarama.Client); ok {
+			server.serverEngine.GET("/metrics/kafka",
+				echo.WrapHandler(broker.NewKafkaMetricsHandler(client)),
+				echo.WrapMiddleware(service.GetDependency().GetMiddleware().HTTPBasicAuth))
+		}
+	}
+
+	// inject cron worker admin api to rest server
+	if env.BaseEnv().UseCronScheduler {
+		server.serverEngine.Any("/cron-scheduler/*",
+			echo.WrapHandler(http.StripPrefix("/cron-scheduler", cronworker.NewAdminHandler())),
+			echo.WrapMiddleware(service.GetDependency().GetMiddleware().HTTPBasicAuth))
+		logger.LogYellow("[CRON-SCHEDULER] admin api : /cron-scheduler/jobs")
+	}
+
 	restRootPath := server.serverEngine.Group("", echoRestTracerMiddleware)
 	if env.BaseEnv().DebugMode {
 		restRootPath.Use(echoMidd.Logger())
@@ -65,7 +85,7 @@ func NewServer(service factory.ServiceFactory, muxListener cmux.CMux) factory.Ap
 		return httpRoutes[i].Path < httpRoutes[j].Path
 	})
 	for _, route := range httpRoutes {
-		if !candihelper.StringInSlice(route.Path, []string{"/", "/memstats"}) && !strings.Contains(route.Name, "(*Group)") {
+		if !candihelper.StringInSlice(route.Path, []string{"/", "/memstats", "/metrics/kafka", "/cron-scheduler/*"}) && !strings.Contains(route.Name, "(*Group)") {
 			logger.LogGreen(fmt.Sprintf("[REST-ROUTE] %-6s %-30s --> %s", route.Method, route.Path, route.Name))
 		}
 	}
@@ -33,6 +33,7 @@ func NewWorker(service factory.ServiceFactory) factory.AppServerFactory {
 			var handlerGroup types.WorkerHandlerGroup
 			h.MountHandlers(&handlerGroup)
 			for _, handler := range handlerGroup.Handlers {
+				handler.RejectBatchHandler(types.TaskQueue)
 				workerIndex := len(workers)
 				registeredTask[handler.Pattern] = struct {
 					handlerFunc   types.WorkerHandlerFunc
@@ -7,6 +7,7 @@ import (
 	kafkaworker "github.com/golangid/candi/codebase/app/kafka_worker"
 	postgresworker "github.com/golangid/candi/codebase/app/postgres_worker"
 	rabbitmqworker "github.com/golangid/candi/codebase/app/rabbitmq_worker"
+	redisstreamworker "github.com/golangid/candi/codebase/app/redis_stream_worker"
 	redisworker "github.com/golangid/candi/codebase/app/redis_worker"
 	restserver "github.com/golangid/candi/codebase/app/rest_server"
 	taskqueueworker "github.com/golangid/candi/codebase/app/task_queue_worker"
@@ -40,6 +41,8 @@ USE_TASK_QUEUE_WORKER=[bool]
 USE_POSTGRES_LISTENER_WORKER=[bool]
 
 USE_RABBITMQ_CONSUMER=[bool] # event driven handler and dynamic scheduler
+
+USE_REDIS_STREAM_WORKER=[bool] # event driven handler with redis stream consumer group
 */
 func NewAppFromEnvironmentConfig(service factory.ServiceFactory) (apps []factory.AppServerFactory) {
 
@@ -61,6 +64,9 @@ func NewAppFromEnvironmentConfig(service factory.ServiceFactory) (apps []factory
 	if env.BaseEnv().UseRabbitMQWorker {
 		apps = append(apps, rabbitmqworker.NewWorker(service))
 	}
+	if env.BaseEnv().UseRedisStreamWorker {
+		apps = append(apps, redisstreamworker.NewWorker(service))
+	}
 
 	sharedListener := service.GetConfig().SharedListener
 	if env.BaseEnv().UseREST {
@@ -3,7 +3,7 @@ package types
 // Server is the type returned by a classifier server (REST, gRPC, GraphQL)
 type Server string
 
-// Worker is the type returned by a classifier worker (kafka, redis subscriber, rabbitmq, scheduler, task queue)
+// Worker is the type returned by a classifier worker (kafka, redis subscriber, redis stream, rabbitmq, scheduler, task queue)
 type Worker string
 
 const (
@@ -26,4 +26,6 @@ const (
 	TaskQueue Worker = "task_queue"
 	// PostgresListener worker
 	PostgresListener Worker = "postgres_listener"
+	// RedisStream worker
+	RedisStream Worker = "redis_stream"
 )
@@ -1,29 +1,85 @@
 package types
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"time"
+)
 
 // WorkerHandlerFunc types
 type WorkerHandlerFunc func(ctx context.Context, message []byte) error
 
+// WorkerBatchHandlerFunc types, handle batch of messages (currently supported in kafka worker)
+type WorkerBatchHandlerFunc func(ctx context.Context, messages [][]byte) error
+
 // WorkerErrorHandler types
 type WorkerErrorHandler func(ctx context.Context, workerType Worker, workerName string, message []byte, err error)
 
 // WorkerHandlerGroup group of worker handlers by pattern string
 type WorkerHandlerGroup struct {
-	Handlers []struct {
-		Pattern      string
-		HandlerFunc  WorkerHandlerFunc
-		ErrorHandler []WorkerErrorHandler
+	Handlers []WorkerHandler
+}
+
+// WorkerHandler handler of pattern in WorkerHandlerGroup
+type WorkerHandler struct {
+	Pattern      string
+	HandlerFunc  WorkerHandlerFunc
+	ErrorHandler []WorkerErrorHandler
+
+	BatchHandlerFunc WorkerBatchHandlerFunc
+	// BatchSize maximum messages in one batch
+	BatchSize int
+	// BatchWait maximum wait time for collecting messages since first message in batch is received
+	BatchWait time.Duration
+
+	// RabbitMQ queue configuration, nil for default configuration
+	RabbitMQ *RabbitMQQueueConfig
+}
+
+// RejectBatchHandler panic if handler is added with AddBatch, used by worker which not support batch handler
+func (h *WorkerHandler) RejectBatchHandler(workerType Worker) {
+	if h.BatchHandlerFunc != nil {
+		panic(fmt.Sprintf("%s worker: batch handler of \"%s\" is not supported, AddBatch is only supported in kafka worker", workerType, h.Pattern))
 	}
 }
 
-// Add method from WorkerHandlerGroup, pattern can contains unique topic name, key, and task name
+// RabbitMQQueueConfig queue configuration of rabbitmq handler
+type RabbitMQQueueConfig struct {
+	// Exchange name for binding, default from RABBITMQ_EXCHANGE_NAME environment (delayed exchange).
+	// Queue is always bound to RABBITMQ_EXCHANGE_NAME with queue name as routing key for retry message
+	Exchange string
+	// ExchangeType type of Exchange if not default exchange: "direct" (default), "topic", "fanout", or "headers"
+	ExchangeType string
+	// RoutingKeys binding routing keys or patterns (example: "order.*.created"), default is queue name
+	RoutingKeys []string
+	// Args queue arguments (example: "x-message-ttl", "x-max-length", "x-queue-type": "quorum")
+	Args map[string]interface{}
+	// Concurrency maximum messages of queue processed concurrently, default is 1
+	Concurrency int
+	// Prefetch maximum unacked messages delivered to consumer of queue (QoS), default is 2 or Concurrency if greater
+	Prefetch int
+}
+
+// Add method from WorkerHandlerGroup, pattern can contains unique topic name, key, and task name.
+// For kafka, pattern with regex meta character (example: `orders\..*`) is subscribed to all matching topics
 func (m *WorkerHandlerGroup) Add(pattern string, handlerFunc WorkerHandlerFunc, errHandlers ...WorkerErrorHandler) {
-	m.Handlers = append(m.Handlers, struct {
-		Pattern      string
-		HandlerFunc  WorkerHandlerFunc
-		ErrorHandler []WorkerErrorHandler
-	}{
+	m.Handlers = append(m.Handlers, WorkerHandler{
 		Pattern: pattern, HandlerFunc: handlerFunc, ErrorHandler: errHandlers,
 	})
 }
+
+// AddBatch method from WorkerHandlerGroup, handler receive up to batchSize messages or messages arrived within batchWait
+// (only supported in kafka worker, another worker panic when register batch handler)
+func (m *WorkerHandlerGroup) AddBatch(pattern string, batchHandlerFunc WorkerBatchHandlerFunc, batchSize int, batchWait time.Duration, errHandlers ...WorkerErrorHandler) {
+	m.Handlers = append(m.Handlers, WorkerHandler{
+		Pattern: pattern, ErrorHandler: errHandlers, BatchHandlerFunc: batchHandlerFunc, BatchSize: batchSize, BatchWait: batchWait,
+	})
+}
+
+// AddRabbitMQQueue method from WorkerHandlerGroup, consume queue with exchange, bindings and queue arguments from config
+// (only supported in rabbitmq worker)
+func (m *WorkerHandlerGroup) AddRabbitMQQueue(queue string, config RabbitMQQueueConfig, handlerFunc WorkerHandlerFunc, errHandlers ...WorkerErrorHandler) {
+	m.Handlers = append(m.Handlers, WorkerHandler{
+		Pattern: queue, HandlerFunc: handlerFunc, ErrorHandler: errHandlers, RabbitMQ: &config,
+	})
+}
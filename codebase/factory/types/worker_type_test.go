@@ -0,0 +1,23 @@
+package types
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkerHandlerGroup(t *testing.T) {
+	var group WorkerHandlerGroup
+	group.Add("push-notif", func(ctx context.Context, message []byte) error { return nil })
+	group.AddBatch("orders", func(ctx context.Context, messages [][]byte) error { return nil }, 10, time.Second)
+	group.AddRabbitMQQueue("payments", RabbitMQQueueConfig{Concurrency: 2}, func(ctx context.Context, message []byte) error { return nil })
+
+	assert.Len(t, group.Handlers, 3)
+	assert.NotPanics(t, func() { group.Handlers[0].RejectBatchHandler(RedisSubscriber) })
+	assert.PanicsWithValue(t, `redis_subscriber worker: batch handler of "orders" is not supported, AddBatch is only supported in kafka worker`, func() {
+		group.Handlers[1].RejectBatchHandler(RedisSubscriber)
+	})
+	assert.Equal(t, 2, group.Handlers[2].RabbitMQ.Concurrency)
+}
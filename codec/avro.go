@@ -0,0 +1,507 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// avroSchema parsed avro schema, support primitive, record, enum, array, map, union, and fixed type.
+// Logical type is encoded with the underlying type, except decimal and duration which is rejected when parsed
+type avroSchema struct {
+	typ      string
+	name     string
+	fields   []avroField
+	symbols  []string
+	items    *avroSchema
+	values   *avroSchema
+	size     int
+	branches []*avroSchema
+}
+
+type avroField struct {
+	name       string
+	schema     *avroSchema
+	hasDefault bool
+	def        interface{}
+}
+
+// unsupported logical type, value of decimal & duration is not encoded as the underlying bytes from json value
+var avroUnsupportedLogicalTypes = map[string]bool{"decimal": true, "duration": true}
+
+func parseAvroSchema(source string) (*avroSchema, error) {
+	var raw interface{}
+	if err := unmarshalJSONNumber([]byte(source), &raw); err != nil {
+		return nil, fmt.Errorf("codec: invalid avro schema: %v", err)
+	}
+	return (&avroSchemaParser{named: make(map[string]*avroSchema)}).parse(raw, "")
+}
+
+type avroSchemaParser struct {
+	named map[string]*avroSchema // mapping full name to named type (record, enum, fixed)
+}
+
+func (p *avroSchemaParser) parse(raw interface{}, namespace string) (*avroSchema, error) {
+	switch v := raw.(type) {
+	case string:
+		if isAvroPrimitive(v) {
+			return &avroSchema{typ: v}, nil
+		}
+		// reference to named type, name without dot is resolved in enclosing namespace
+		name := v
+		if !strings.Contains(name, ".") && namespace != "" {
+			name = namespace + "." + name
+		}
+		if s, ok := p.named[name]; ok {
+			return s, nil
+		}
+		return nil, fmt.Errorf("codec: unknown avro type %s", v)
+
+	case []interface{}:
+		union := &avroSchema{typ: "union"}
+		seen := make(map[string]bool, len(v))
+		for _, branch := range v {
+			s, err := p.parse(branch, namespace)
+			if err != nil {
+				return nil, err
+			}
+			key := s.typ
+			if s.name != "" {
+				key = s.name
+			}
+			if s.typ == "union" {
+				return nil, errors.New("codec: avro union cannot contain another union")
+			}
+			if seen[key] {
+				return nil, fmt.Errorf("codec: avro union contain duplicate type %s", key)
+			}
+			seen[key] = true
+			union.branches = append(union.branches, s)
+		}
+		return union, nil
+
+	case map[string]interface{}:
+		typ, _ := v["type"].(string)
+		if typ == "" {
+			// nested type definition, example: {"type": {"type": "array", ...}}
+			return p.parse(v["type"], namespace)
+		}
+		if logicalType, _ := v["logicalType"].(string); avroUnsupportedLogicalTypes[logicalType] {
+			return nil, fmt.Errorf("codec: avro logical type %s is not supported", logicalType)
+		}
+
+		s := &avroSchema{typ: typ}
+		switch typ {
+		case "record", "error", "enum", "fixed":
+			s.typ = strings.Replace(typ, "error", "record", 1)
+			s.name, _ = v["name"].(string)
+			if s.name == "" {
+				return nil, fmt.Errorf("codec: avro %s must have name", typ)
+			}
+			if ns, ok := v["namespace"].(string); ok && !strings.Contains(s.name, ".") {
+				namespace = ns
+			}
+			if i := strings.LastIndexByte(s.name, '.'); i >= 0 {
+				namespace = s.name[:i]
+			} else if namespace != "" {
+				s.name = namespace + "." + s.name
+			}
+			if isAvroPrimitive(s.name) {
+				return nil, fmt.Errorf("codec: avro %s cannot use primitive type name %s", typ, s.name)
+			}
+			if _, ok := p.named[s.name]; ok {
+				return nil, fmt.Errorf("codec: avro type %s is redefined", s.name)
+			}
+			p.named[s.name] = s
+		}
+
+		switch s.typ {
+		case "record":
+			fields, _ := v["fields"].([]interface{})
+			names := make(map[string]bool, len(fields))
+			for _, f := range fields {
+				fieldMap, ok := f.(map[string]interface{})
+				if !ok {
+					return nil, errors.New("codec: invalid avro record field")
+				}
+				var field avroField
+				field.name, _ = fieldMap["name"].(string)
+				if field.name == "" || names[field.name] {
+					return nil, fmt.Errorf("codec: avro record %s has empty or duplicate field name %q", s.name, field.name)
+				}
+				names[field.name] = true
+				fieldSchema, err := p.parse(fieldMap["type"], namespace)
+				if err != nil {
+					return nil, fmt.Errorf("codec: avro field %s: %v", field.name, err)
+				}
+				field.schema = fieldSchema
+				field.def, field.hasDefault = fieldMap["default"]
+				if field.hasDefault && !fieldSchema.acceptDefault(field.def) {
+					return nil, fmt.Errorf("codec: avro field %s: invalid default value %v", field.name, field.def)
+				}
+				s.fields = append(s.fields, field)
+			}
+		case "enum":
+			symbols, _ := v["symbols"].([]interface{})
+			seen := make(map[string]bool, len(symbols))
+			for _, symbol := range symbols {
+				str, _ := symbol.(string)
+				if str == "" || seen[str] {
+					return nil, fmt.Errorf("codec: avro enum %s has empty or duplicate symbol %v", s.name, symbol)
+				}
+				seen[str] = true
+				s.symbols = append(s.symbols, str)
+			}
+			if len(s.symbols) == 0 {
+				return nil, fmt.Errorf("codec: avro enum %s must have symbols", s.name)
+			}
+		case "fixed":
+			size, _ := v["size"].(json.Number)
+			n, err := size.Int64()
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("codec: avro fixed %s invalid size", s.name)
+			}
+			s.size = int(n)
+		case "array":
+			items, err := p.parse(v["items"], namespace)
+			if err != nil {
+				return nil, err
+			}
+			s.items = items
+		case "map":
+			values, err := p.parse(v["values"], namespace)
+			if err != nil {
+				return nil, err
+			}
+			s.values = values
+		default:
+			// primitive or named type in object form, example: {"type": "long", "logicalType": "timestamp-millis"}
+			return p.parse(typ, namespace)
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("codec: invalid avro schema %v", raw)
+}
+
+func isAvroPrimitive(typ string) bool {
+	switch typ {
+	case "null", "boolean", "int", "long", "float", "double", "bytes", "string":
+		return true
+	}
+	return false
+}
+
+// acceptDefault check default value of field, default of union must match the first branch
+func (s *avroSchema) acceptDefault(def interface{}) bool {
+	if s.typ == "union" {
+		return len(s.branches) > 0 && s.branches[0].accept(def)
+	}
+	return s.accept(def)
+}
+
+// encodeAvro encode generic value (from json decoder with number) to avro binary
+func encodeAvro(buf *bytes.Buffer, schema *avroSchema, value interface{}, path string) error {
+	switch schema.typ {
+	case "null":
+		if value != nil {
+			return avroTypeError(path, schema, value)
+		}
+	case "boolean":
+		b, ok := value.(bool)
+		if !ok {
+			return avroTypeError(path, schema, value)
+		}
+		if b {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+	case "int", "long":
+		num, ok := value.(json.Number)
+		if !ok {
+			return avroTypeError(path, schema, value)
+		}
+		n, err := num.Int64()
+		if err != nil || (schema.typ == "int" && (n < math.MinInt32 || n > math.MaxInt32)) {
+			return avroTypeError(path, schema, value)
+		}
+		writeAvroLong(buf, n)
+	case "float", "double":
+		num, ok := value.(json.Number)
+		if !ok {
+			return avroTypeError(path, schema, value)
+		}
+		f, err := num.Float64()
+		if err != nil {
+			return avroTypeError(path, schema, value)
+		}
+		if schema.typ == "float" {
+			binary.Write(buf, binary.LittleEndian, math.Float32bits(float32(f)))
+		} else {
+			binary.Write(buf, binary.LittleEndian, math.Float64bits(f))
+		}
+	case "bytes", "string":
+		s, ok := value.(string)
+		if !ok {
+			return avroTypeError(path, schema, value)
+		}
+		writeAvroLong(buf, int64(len(s)))
+		buf.WriteString(s)
+	case "fixed":
+		s, ok := value.(string)
+		if !ok || len(s) != schema.size {
+			return avroTypeError(path, schema, value)
+		}
+		buf.WriteString(s)
+	case "enum":
+		s, ok := value.(string)
+		if !ok {
+			return avroTypeError(path, schema, value)
+		}
+		for i, symbol := range schema.symbols {
+			if symbol == s {
+				writeAvroLong(buf, int64(i))
+				return nil
+			}
+		}
+		return fmt.Errorf("%s: unknown symbol %s of enum %s", path, s, schema.name)
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return avroTypeError(path, schema, value)
+		}
+		if len(items) > 0 {
+			writeAvroLong(buf, int64(len(items)))
+			for i, item := range items {
+				if err := encodeAvro(buf, schema.items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+		writeAvroLong(buf, 0)
+	case "map":
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			return avroTypeError(path, schema, value)
+		}
+		if len(values) > 0 {
+			writeAvroLong(buf, int64(len(values)))
+			for k, v := range values {
+				writeAvroLong(buf, int64(len(k)))
+				buf.WriteString(k)
+				if err := encodeAvro(buf, schema.values, v, path+"."+k); err != nil {
+					return err
+				}
+			}
+		}
+		writeAvroLong(buf, 0)
+	case "record":
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			return avroTypeError(path, schema, value)
+		}
+		for _, field := range schema.fields {
+			v, ok := values[field.name]
+			if !ok && field.hasDefault {
+				v = field.def
+			} else if !ok && !field.schema.accept(nil) {
+				return fmt.Errorf("%s.%s: required field is missing", path, field.name)
+			}
+			if err := encodeAvro(buf, field.schema, v, path+"."+field.name); err != nil {
+				return err
+			}
+		}
+	case "union":
+		for i, branch := range schema.branches {
+			if branch.accept(value) {
+				writeAvroLong(buf, int64(i))
+				return encodeAvro(buf, branch, value, path)
+			}
+		}
+		return avroTypeError(path, schema, value)
+	}
+	return nil
+}
+
+// accept check type of value can be encoded with schema, used for choosing union branch
+func (s *avroSchema) accept(value interface{}) bool {
+	switch s.typ {
+	case "null":
+		return value == nil
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "int", "long":
+		num, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		_, err := num.Int64()
+		return err == nil
+	case "float", "double":
+		_, ok := value.(json.Number)
+		return ok
+	case "bytes", "string":
+		_, ok := value.(string)
+		return ok
+	case "fixed":
+		str, ok := value.(string)
+		return ok && len(str) == s.size
+	case "enum":
+		str, ok := value.(string)
+		for _, symbol := range s.symbols {
+			if ok && symbol == str {
+				return true
+			}
+		}
+		return false
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "map", "record":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "union":
+		for _, branch := range s.branches {
+			if branch.accept(value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// decodeAvro decode avro binary to generic value
+func decodeAvro(reader *bytes.Reader, schema *avroSchema) (interface{}, error) {
+	switch schema.typ {
+	case "null":
+		return nil, nil
+	case "boolean":
+		b, err := reader.ReadByte()
+		return b == 1, err
+	case "int", "long":
+		return binary.ReadVarint(reader)
+	case "float":
+		var bits uint32
+		err := binary.Read(reader, binary.LittleEndian, &bits)
+		return float64(math.Float32frombits(bits)), err
+	case "double":
+		var bits uint64
+		err := binary.Read(reader, binary.LittleEndian, &bits)
+		return math.Float64frombits(bits), err
+	case "bytes", "string":
+		return readAvroString(reader)
+	case "fixed":
+		b := make([]byte, schema.size)
+		_, err := io.ReadFull(reader, b)
+		return string(b), err
+	case "enum":
+		i, err := binary.ReadVarint(reader)
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || int(i) >= len(schema.symbols) {
+			return nil, fmt.Errorf("codec: invalid enum index %d of %s", i, schema.name)
+		}
+		return schema.symbols[i], nil
+	case "array":
+		items := []interface{}{}
+		err := readAvroBlocks(reader, func() error {
+			item, err := decodeAvro(reader, schema.items)
+			items = append(items, item)
+			return err
+		})
+		return items, err
+	case "map":
+		values := map[string]interface{}{}
+		err := readAvroBlocks(reader, func() error {
+			key, err := readAvroString(reader)
+			if err != nil {
+				return err
+			}
+			values[key], err = decodeAvro(reader, schema.values)
+			return err
+		})
+		return values, err
+	case "record":
+		values := make(map[string]interface{}, len(schema.fields))
+		for _, field := range schema.fields {
+			v, err := decodeAvro(reader, field.schema)
+			if err != nil {
+				return nil, err
+			}
+			values[field.name] = v
+		}
+		return values, nil
+	case "union":
+		i, err := binary.ReadVarint(reader)
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || int(i) >= len(schema.branches) {
+			return nil, fmt.Errorf("codec: invalid union index %d", i)
+		}
+		return decodeAvro(reader, schema.branches[i])
+	}
+	return nil, fmt.Errorf("codec: unsupported avro type %s", schema.typ)
+}
+
+func readAvroBlocks(reader *bytes.Reader, readItem func() error) error {
+	for {
+		count, err := binary.ReadVarint(reader)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return nil
+		}
+		if count < 0 {
+			count = -count
+			if _, err := binary.ReadVarint(reader); err != nil { // block size in bytes
+				return err
+			}
+		}
+		for i := int64(0); i < count; i++ {
+			if err := readItem(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func readAvroString(reader *bytes.Reader) (string, error) {
+	length, err := binary.ReadVarint(reader)
+	if err != nil {
+		return "", err
+	}
+	if length < 0 || length > int64(reader.Len()) {
+		return "", errors.New("codec: invalid avro string length")
+	}
+	b := make([]byte, length)
+	_, err = io.ReadFull(reader, b)
+	return string(b), err
+}
+
+func writeAvroLong(buf *bytes.Buffer, n int64) {
+	b := make([]byte, binary.MaxVarintLen64)
+	buf.Write(b[:binary.PutVarint(b, n)])
+}
+
+func avroTypeError(path string, schema *avroSchema, value interface{}) error {
+	expected := schema.typ
+	if schema.name != "" {
+		expected += " " + schema.name
+	}
+	return fmt.Errorf("%s: expected %s, got %T", path, expected, value)
+}
+
+func unmarshalJSONNumber(data []byte, target interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(target)
+}
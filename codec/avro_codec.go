@@ -0,0 +1,108 @@
+package codec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/golangid/candi/candihelper"
+)
+
+type avroCodec struct {
+	registry SchemaRegistry
+	subjects map[string]string
+
+	mu     sync.RWMutex
+	parsed map[string]*avroSchema // mapping schema source to parsed schema
+}
+
+// NewAvroCodec codec with avro, subjects is mapping subject to avro schema source for encode.
+// Data is converted to json before encoded, so that struct json tag is used as field name.
+//
+// Supported subset: primitive, record, enum, array, map, union, fixed, and reference to named type defined before
+// (resolved in enclosing namespace). Value of bytes and fixed is json string encoded as raw bytes of the string
+// (use string field, []byte field is base64 in json). Logical type is encoded with the underlying type
+// (example timestamp-millis from epoch number), decimal and duration logical type is rejected.
+// Message is decoded with writer schema from registry without schema resolution to reader schema
+// (no alias, promotion, or default from reader schema), then converted to target through json.
+// Schema with unsupported or invalid definition is rejected when parsed, before registered to schema registry
+func NewAvroCodec(registry SchemaRegistry, subjects map[string]string) Codec {
+	return &avroCodec{
+		registry: registry, subjects: subjects, parsed: make(map[string]*avroSchema),
+	}
+}
+
+func (c *avroCodec) Encode(ctx context.Context, subject string, data interface{}) ([]byte, error) {
+	source, ok := c.subjects[subject]
+	if !ok {
+		return nil, fmt.Errorf("codec: avro schema of subject %s not found", subject)
+	}
+	schema, err := c.schema(source)
+	if err != nil {
+		return nil, err
+	}
+	id, err := c.registry.Register(ctx, subject, Schema{Type: Avro, Schema: source})
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	if err := unmarshalJSONNumber(candihelper.ToBytes(data), &value); err != nil {
+		return nil, fmt.Errorf("codec: data of subject %s is not valid json: %v", subject, err)
+	}
+	var buf bytes.Buffer
+	if err := encodeAvro(&buf, schema, value, "(root)"); err != nil {
+		return nil, fmt.Errorf("codec: data is not valid with schema of subject %s: %v", subject, err)
+	}
+	return encodeWireFormat(id, buf.Bytes()), nil
+}
+
+func (c *avroCodec) Decode(ctx context.Context, message []byte, target interface{}) error {
+	id, payload, err := decodeWireFormat(message)
+	if err != nil {
+		return err
+	}
+	registered, err := c.registry.GetSchema(ctx, id)
+	if err != nil {
+		return err
+	}
+	if registered.Type != Avro {
+		return fmt.Errorf("codec: schema id %d is %s, not avro", id, registered.Type)
+	}
+	schema, err := c.schema(registered.Schema)
+	if err != nil {
+		return err
+	}
+
+	reader := bytes.NewReader(payload)
+	value, err := decodeAvro(reader, schema)
+	if err != nil {
+		return fmt.Errorf("codec: message is not valid with schema id %d: %v", id, err)
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, target)
+}
+
+// schema get parsed schema from cache
+func (c *avroCodec) schema(source string) (*avroSchema, error) {
+	c.mu.RLock()
+	schema, ok := c.parsed[source]
+	c.mu.RUnlock()
+	if ok {
+		return schema, nil
+	}
+
+	schema, err := parseAvroSchema(source)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.parsed[source] = schema
+	c.mu.Unlock()
+	return schema, nil
+}
@@ -0,0 +1,61 @@
+/*
+Package codec schema-aware message encoding (JSON Schema, Protobuf, Avro) in confluent wire format with schema registry.
+
+Wrap publisher with codec.NewPublisher so that data is validated and encoded before published,
+and wrap worker handler with codec.DecodeHandler so that consumed message is decoded to target type.
+Use codec.NewSchemaRegistryClient for confluent schema registry, or codec.NewInMemorySchemaRegistry in test
+*/
+package codec
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+)
+
+// SchemaType type of schema in schema registry
+type SchemaType string
+
+const (
+	// JSONSchema schema type
+	JSONSchema SchemaType = "JSON"
+	// Protobuf schema type
+	Protobuf SchemaType = "PROTOBUF"
+	// Avro schema type
+	Avro SchemaType = "AVRO"
+)
+
+const magicByte byte = 0
+
+var (
+	// ErrInvalidWireFormat error for message which not encoded in confluent wire format
+	ErrInvalidWireFormat = errors.New("codec: invalid wire format")
+)
+
+// Codec schema-aware message encoding, message is encoded in confluent wire format
+// (magic byte, 4 bytes schema id, and payload) so that can be decoded by another services using schema registry
+type Codec interface {
+	// Encode validate data with schema of subject and encode the data, incompatible data is rejected
+	Encode(ctx context.Context, subject string, data interface{}) ([]byte, error)
+	// Decode message with schema from schema id in message to target
+	Decode(ctx context.Context, message []byte, target interface{}) error
+}
+
+// TopicSubject get subject of message value in topic (confluent topic name strategy)
+func TopicSubject(topic string) string {
+	return topic + "-value"
+}
+
+func encodeWireFormat(schemaID int, payload []byte) []byte {
+	message := make([]byte, 5, 5+len(payload))
+	message[0] = magicByte
+	binary.BigEndian.PutUint32(message[1:5], uint32(schemaID))
+	return append(message, payload...)
+}
+
+func decodeWireFormat(message []byte) (schemaID int, payload []byte, err error) {
+	if len(message) < 5 || message[0] != magicByte {
+		return 0, nil, ErrInvalidWireFormat
+	}
+	return int(binary.BigEndian.Uint32(message[1:5])), message[5:], nil
+}
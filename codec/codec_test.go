@@ -0,0 +1,204 @@
+package codec
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type order struct {
+	ID     string   `json:"id"`
+	Amount int      `json:"amount"`
+	Status string   `json:"status"`
+	Note   *string  `json:"note"`
+	Tags   []string `json:"tags"`
+}
+
+const orderAvroSchema = `{
+	"type": "record", "name": "Order", "namespace": "example",
+	"fields": [
+		{"name": "id", "type": "string"},
+		{"name": "amount", "type": "long"},
+		{"name": "status", "type": {"type": "enum", "name": "Status", "symbols": ["NEW", "PAID"]}},
+		{"name": "note", "type": ["null", "string"], "default": null},
+		{"name": "tags", "type": {"type": "array", "items": "string"}}
+	]
+}`
+
+const orderJSONSchema = `{
+	"type": "object",
+	"properties": {"id": {"type": "string"}, "amount": {"type": "integer", "minimum": 1}},
+	"required": ["id", "amount"]
+}`
+
+func TestAvroCodec(t *testing.T) {
+	ctx := context.Background()
+	codec := NewAvroCodec(NewInMemorySchemaRegistry(), map[string]string{"order-value": orderAvroSchema})
+
+	note := "fragile"
+	message, err := codec.Encode(ctx, "order-value", order{ID: "1", Amount: 100, Status: "PAID", Note: &note, Tags: []string{"a", "b"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0, 0, 0, 0, 1}, message[:5])
+
+	var result order
+	assert.NoError(t, codec.Decode(ctx, message, &result))
+	assert.Equal(t, order{ID: "1", Amount: 100, Status: "PAID", Note: &note, Tags: []string{"a", "b"}}, result)
+
+	_, err = codec.Encode(ctx, "order-value", order{ID: "1", Amount: 100, Status: "CANCELED"})
+	assert.Error(t, err)
+	_, err = codec.Encode(ctx, "order-value", map[string]interface{}{"id": "1", "status": "NEW", "tags": []string{}})
+	assert.Error(t, err)
+	_, err = codec.Encode(ctx, "unknown-value", order{})
+	assert.Error(t, err)
+}
+
+func TestAvroSchemaParse(t *testing.T) {
+	tests := []struct {
+		name, schema, err string
+	}{
+		{name: "named type reference in namespace", schema: `{"type": "record", "name": "Pair", "namespace": "example", "fields": [
+			{"name": "left", "type": {"type": "fixed", "name": "Code", "size": 2}},
+			{"name": "right", "type": "Code"},
+			{"name": "other", "type": "example.Code"},
+			{"name": "next", "type": ["null", "Pair"], "default": null}
+		]}`},
+		{name: "logical type with underlying type", schema: `{"type": "long", "logicalType": "timestamp-millis"}`},
+		{name: "decimal logical type", schema: `{"type": "bytes", "logicalType": "decimal", "precision": 4, "scale": 2}`, err: "logical type decimal is not supported"},
+		{name: "duration logical type", schema: `{"type": "fixed", "name": "Duration", "size": 12, "logicalType": "duration"}`, err: "logical type duration is not supported"},
+		{name: "reference from another namespace", schema: `{"type": "record", "name": "a.A", "fields": [
+			{"name": "code", "type": {"type": "fixed", "name": "Code", "size": 2}},
+			{"name": "b", "type": {"type": "record", "name": "b.B", "fields": [{"name": "code", "type": "Code"}]}}
+		]}`, err: "unknown avro type Code"},
+		{name: "reference before defined", schema: `{"type": "record", "name": "A", "fields": [{"name": "b", "type": "B"}]}`, err: "unknown avro type B"},
+		{name: "redefined type", schema: `["null", {"type": "enum", "name": "E", "symbols": ["A"]}, {"type": "fixed", "name": "E", "size": 1}]`, err: "type E is redefined"},
+		{name: "primitive type name", schema: `{"type": "fixed", "name": "int", "size": 1}`, err: "primitive type name"},
+		{name: "nested union", schema: `["null", ["int", "string"]]`, err: "cannot contain another union"},
+		{name: "duplicate union branch", schema: `["string", "int", "string"]`, err: "duplicate type string"},
+		{name: "duplicate field", schema: `{"type": "record", "name": "A", "fields": [{"name": "a", "type": "int"}, {"name": "a", "type": "int"}]}`, err: "duplicate field name"},
+		{name: "invalid default", schema: `{"type": "record", "name": "A", "fields": [{"name": "a", "type": ["string", "null"], "default": null}]}`, err: "invalid default value"},
+		{name: "empty enum", schema: `{"type": "enum", "name": "E", "symbols": []}`, err: "must have symbols"},
+		{name: "invalid fixed size", schema: `{"type": "fixed", "name": "F", "size": -1}`, err: "invalid size"},
+		{name: "unknown type", schema: `{"type": "uuid"}`, err: "unknown avro type uuid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseAvroSchema(tt.schema)
+			if tt.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.err)
+			}
+		})
+	}
+}
+
+func TestAvroCodecMapAndFixed(t *testing.T) {
+	ctx := context.Background()
+	registry := NewInMemorySchemaRegistry()
+	codec := NewAvroCodec(registry, map[string]string{
+		"stock-value": `{"type": "record", "name": "Stock", "fields": [
+			{"name": "sku", "type": {"type": "fixed", "name": "SKU", "size": 4}},
+			{"name": "alias", "type": ["null", "SKU"], "default": null},
+			{"name": "quantities", "type": {"type": "map", "values": "int"}}
+		]}`,
+		"price-value": `{"type": "bytes", "logicalType": "decimal", "precision": 4, "scale": 2}`,
+	})
+
+	data := map[string]interface{}{"sku": "A001", "alias": "B001", "quantities": map[string]interface{}{"jakarta": 10, "bandung": 5}}
+	message, err := codec.Encode(ctx, "stock-value", data)
+	assert.NoError(t, err)
+	var result map[string]interface{}
+	assert.NoError(t, codec.Decode(ctx, message, &result))
+	assert.Equal(t, map[string]interface{}{
+		"sku": "A001", "alias": "B001", "quantities": map[string]interface{}{"jakarta": float64(10), "bandung": float64(5)},
+	}, result)
+
+	_, err = codec.Encode(ctx, "stock-value", map[string]interface{}{"sku": "A01", "quantities": map[string]interface{}{}})
+	assert.Error(t, err, "fixed size mismatch")
+
+	_, err = codec.Encode(ctx, "price-value", "1234")
+	assert.Error(t, err)
+	_, err = registry.GetSchema(ctx, 2)
+	assert.Error(t, err, "unsupported schema is not registered")
+}
+
+func TestJSONSchemaCodec(t *testing.T) {
+	ctx := context.Background()
+	codec := NewJSONSchemaCodec(NewInMemorySchemaRegistry(), map[string]string{"order-value": orderJSONSchema})
+
+	message, err := codec.Encode(ctx, "order-value", order{ID: "1", Amount: 100})
+	assert.NoError(t, err)
+	var result order
+	assert.NoError(t, codec.Decode(ctx, message, &result))
+	assert.Equal(t, "1", result.ID)
+
+	_, err = codec.Encode(ctx, "order-value", order{ID: "1"})
+	assert.Error(t, err)
+	assert.Equal(t, ErrInvalidWireFormat, codec.Decode(ctx, []byte(`{"id":"1"}`), &result))
+}
+
+func TestProtobufCodec(t *testing.T) {
+	ctx := context.Background()
+	codec := NewProtobufCodec(NewInMemorySchemaRegistry(), map[string]ProtobufSubject{
+		"name-value": {Schema: `syntax = "proto3"; message StringValue { string value = 1; }`, Message: &wrapperspb.StringValue{}},
+	})
+
+	message, err := codec.Encode(ctx, "name-value", wrapperspb.String("candi"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{2, 14}, message[5:7]) // message indexes [7], StringValue is 8th message in wrappers.proto
+
+	var result wrapperspb.StringValue
+	assert.NoError(t, codec.Decode(ctx, message, &result))
+	assert.Equal(t, "candi", result.GetValue())
+
+	_, err = codec.Encode(ctx, "name-value", wrapperspb.Int64(1))
+	assert.Error(t, err)
+}
+
+func TestSchemaRegistryClient(t *testing.T) {
+	var mu sync.Mutex
+	var schemas []Schema
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/subjects/"):
+			var schema Schema
+			json.NewDecoder(r.Body).Decode(&schema)
+			schemas = append(schemas, schema)
+			json.NewEncoder(w).Encode(map[string]int{"id": len(schemas)})
+		case r.Method == http.MethodGet && r.URL.Path == "/schemas/ids/1":
+			json.NewEncoder(w).Encode(schemas[0])
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error_code":40403,"message":"Schema not found"}`))
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	registry := NewSchemaRegistryClient(server.URL)
+	id, err := registry.Register(ctx, "order-value", Schema{Type: Avro, Schema: orderAvroSchema})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, id)
+	id, _ = registry.Register(ctx, "order-value", Schema{Type: Avro, Schema: orderAvroSchema})
+	assert.Equal(t, 1, id)
+	assert.Len(t, schemas, 1)
+	assert.Equal(t, SchemaType(""), schemas[0].Type)
+
+	schema, err := registry.GetSchema(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, Avro, schema.Type)
+	_, err = registry.GetSchema(ctx, 2)
+	assert.Error(t, err)
+}
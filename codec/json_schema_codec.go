@@ -0,0 +1,91 @@
+package codec
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/golangid/candi/candihelper"
+	"github.com/golangid/candi/validator"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+type jsonSchemaCodec struct {
+	registry SchemaRegistry
+	subjects map[string]string
+
+	mu       sync.RWMutex
+	compiled map[int]*gojsonschema.Schema
+}
+
+// NewJSONSchemaCodec codec with json schema, subjects is mapping subject to json schema source for encode.
+// Data is validated with schema when encode and decode
+func NewJSONSchemaCodec(registry SchemaRegistry, subjects map[string]string) Codec {
+	return &jsonSchemaCodec{
+		registry: registry, subjects: subjects, compiled: make(map[int]*gojsonschema.Schema),
+	}
+}
+
+func (c *jsonSchemaCodec) Encode(ctx context.Context, subject string, data interface{}) ([]byte, error) {
+	source, ok := c.subjects[subject]
+	if !ok {
+		return nil, fmt.Errorf("codec: json schema of subject %s not found", subject)
+	}
+	id, err := c.registry.Register(ctx, subject, Schema{Type: JSONSchema, Schema: source})
+	if err != nil {
+		return nil, err
+	}
+	schema, err := c.schema(id, source)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := candihelper.ToBytes(data)
+	if err := validator.ValidateJSONSchemaDocument(schema, payload); err != nil {
+		return nil, fmt.Errorf("codec: data is not valid with schema of subject %s: %v", subject, err)
+	}
+	return encodeWireFormat(id, payload), nil
+}
+
+func (c *jsonSchemaCodec) Decode(ctx context.Context, message []byte, target interface{}) error {
+	id, payload, err := decodeWireFormat(message)
+	if err != nil {
+		return err
+	}
+	registered, err := c.registry.GetSchema(ctx, id)
+	if err != nil {
+		return err
+	}
+	if registered.Type != JSONSchema {
+		return fmt.Errorf("codec: schema id %d is %s, not json schema", id, registered.Type)
+	}
+	schema, err := c.schema(id, registered.Schema)
+	if err != nil {
+		return err
+	}
+
+	if err := validator.ValidateJSONSchemaDocument(schema, payload); err != nil {
+		return fmt.Errorf("codec: message is not valid with schema id %d: %v", id, err)
+	}
+	return json.Unmarshal(payload, target)
+}
+
+// schema get compiled schema from cache
+func (c *jsonSchemaCodec) schema(id int, source string) (*gojsonschema.Schema, error) {
+	c.mu.RLock()
+	schema, ok := c.compiled[id]
+	c.mu.RUnlock()
+	if ok {
+		return schema, nil
+	}
+
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(source))
+	if err != nil {
+		return nil, fmt.Errorf("codec: invalid json schema id %d: %v", id, err)
+	}
+	c.mu.Lock()
+	c.compiled[id] = schema
+	c.mu.Unlock()
+	return schema, nil
+}
@@ -0,0 +1,116 @@
+package codec
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ProtobufSubject schema of subject for protobuf codec
+type ProtobufSubject struct {
+	// Schema .proto source registered to schema registry
+	Schema string
+	// Message message type of subject, encoded data must be the same message type
+	Message proto.Message
+}
+
+type protobufCodec struct {
+	registry SchemaRegistry
+	subjects map[string]ProtobufSubject
+}
+
+// NewProtobufCodec codec with protobuf, subjects is mapping subject to schema and message type for encode.
+// Encoded data must be proto.Message with the same message type of subject, and decode target must be proto.Message
+func NewProtobufCodec(registry SchemaRegistry, subjects map[string]ProtobufSubject) Codec {
+	return &protobufCodec{registry: registry, subjects: subjects}
+}
+
+func (c *protobufCodec) Encode(ctx context.Context, subject string, data interface{}) ([]byte, error) {
+	protobufSubject, ok := c.subjects[subject]
+	if !ok {
+		return nil, fmt.Errorf("codec: protobuf schema of subject %s not found", subject)
+	}
+	message, ok := data.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("codec: data of subject %s must be proto.Message, got %T", subject, data)
+	}
+	descriptor := message.ProtoReflect().Descriptor()
+	if expected := protobufSubject.Message.ProtoReflect().Descriptor().FullName(); descriptor.FullName() != expected {
+		return nil, fmt.Errorf("codec: incompatible message type %s for subject %s, expected %s", descriptor.FullName(), subject, expected)
+	}
+
+	id, err := c.registry.Register(ctx, subject, Schema{Type: Protobuf, Schema: protobufSubject.Schema})
+	if err != nil {
+		return nil, err
+	}
+	payload, err := proto.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+	return encodeWireFormat(id, append(encodeMessageIndexes(descriptor), payload...)), nil
+}
+
+func (c *protobufCodec) Decode(ctx context.Context, message []byte, target interface{}) error {
+	id, payload, err := decodeWireFormat(message)
+	if err != nil {
+		return err
+	}
+	protoTarget, ok := target.(proto.Message)
+	if !ok {
+		return fmt.Errorf("codec: decode target must be proto.Message, got %T", target)
+	}
+	registered, err := c.registry.GetSchema(ctx, id)
+	if err != nil {
+		return err
+	}
+	if registered.Type != Protobuf {
+		return fmt.Errorf("codec: schema id %d is %s, not protobuf", id, registered.Type)
+	}
+
+	reader := bytes.NewReader(payload)
+	if err := skipMessageIndexes(reader); err != nil {
+		return err
+	}
+	return proto.Unmarshal(payload[len(payload)-reader.Len():], protoTarget)
+}
+
+// encodeMessageIndexes encode path of message in .proto file (index of message in file and index of nested message),
+// path [0] (first message in file) is encoded as single zero
+func encodeMessageIndexes(descriptor protoreflect.MessageDescriptor) []byte {
+	var indexes []int
+	for d := protoreflect.Descriptor(descriptor); ; {
+		indexes = append([]int{d.Index()}, indexes...)
+		parent, ok := d.Parent().(protoreflect.MessageDescriptor)
+		if !ok {
+			break
+		}
+		d = parent
+	}
+
+	buf := make([]byte, binary.MaxVarintLen64)
+	if len(indexes) == 1 && indexes[0] == 0 {
+		return []byte{0}
+	}
+	result := append([]byte{}, buf[:binary.PutVarint(buf, int64(len(indexes)))]...)
+	for _, index := range indexes {
+		result = append(result, buf[:binary.PutVarint(buf, int64(index))]...)
+	}
+	return result
+}
+
+func skipMessageIndexes(reader *bytes.Reader) error {
+	total, err := binary.ReadVarint(reader)
+	if err != nil {
+		return ErrInvalidWireFormat
+	}
+	for i := int64(0); i < total; i++ {
+		if _, err := binary.ReadVarint(reader); err != nil {
+			return ErrInvalidWireFormat
+		}
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package codec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Schema in schema registry
+type Schema struct {
+	Type   SchemaType `json:"schemaType,omitempty"`
+	Schema string     `json:"schema"`
+}
+
+// SchemaRegistry abstraction
+type SchemaRegistry interface {
+	// Register schema to subject, return schema id (existing id if schema already registered)
+	Register(ctx context.Context, subject string, schema Schema) (id int, err error)
+	// GetSchema get schema by id
+	GetSchema(ctx context.Context, id int) (Schema, error)
+}
+
+// SchemaRegistryOptionFunc option func type for schema registry client
+type SchemaRegistryOptionFunc func(*schemaRegistryClient)
+
+// SchemaRegistrySetBasicAuth set basic auth credential
+func SchemaRegistrySetBasicAuth(username, password string) SchemaRegistryOptionFunc {
+	return func(c *schemaRegistryClient) {
+		c.username, c.password = username, password
+	}
+}
+
+// SchemaRegistrySetHTTPClient set custom http client
+func SchemaRegistrySetHTTPClient(httpClient *http.Client) SchemaRegistryOptionFunc {
+	return func(c *schemaRegistryClient) {
+		c.httpClient = httpClient
+	}
+}
+
+type schemaRegistryClient struct {
+	baseURL            string
+	username, password string
+	httpClient         *http.Client
+
+	mu      sync.RWMutex
+	ids     map[string]int
+	schemas map[int]Schema
+}
+
+// NewSchemaRegistryClient client for confluent schema registry REST API, registered id and schema are cached
+func NewSchemaRegistryClient(baseURL string, opts ...SchemaRegistryOptionFunc) SchemaRegistry {
+	c := &schemaRegistryClient{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		ids:        make(map[string]int),
+		schemas:    make(map[int]Schema),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *schemaRegistryClient) Register(ctx context.Context, subject string, schema Schema) (int, error) {
+	cacheKey := subject + "\x00" + string(schema.Type) + "\x00" + schema.Schema
+	c.mu.RLock()
+	id, ok := c.ids[cacheKey]
+	c.mu.RUnlock()
+	if ok {
+		return id, nil
+	}
+
+	if schema.Type == Avro {
+		schema.Type = "" // avro is default schema type, not sent for compatibility with older registry
+	}
+	var resp struct {
+		ID int `json:"id"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/subjects/"+url.PathEscape(subject)+"/versions", schema, &resp); err != nil {
+		return 0, fmt.Errorf("codec: failed register schema of subject %s: %v", subject, err)
+	}
+
+	c.mu.Lock()
+	c.ids[cacheKey] = resp.ID
+	c.mu.Unlock()
+	return resp.ID, nil
+}
+
+func (c *schemaRegistryClient) GetSchema(ctx context.Context, id int) (Schema, error) {
+	c.mu.RLock()
+	schema, ok := c.schemas[id]
+	c.mu.RUnlock()
+	if ok {
+		return schema, nil
+	}
+
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/schemas/ids/%d", id), nil, &schema); err != nil {
+		return schema, fmt.Errorf("codec: failed get schema id %d: %v", id, err)
+	}
+	if schema.Type == "" {
+		schema.Type = Avro
+	}
+
+	c.mu.Lock()
+	c.schemas[id] = schema
+	c.mu.Unlock()
+	return schema, nil
+}
+
+func (c *schemaRegistryClient) do(ctx context.Context, method, path string, reqBody, respBody interface{}) error {
+	var body bytes.Buffer
+	if reqBody != nil {
+		json.NewEncoder(&body).Encode(reqBody)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json, application/json")
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var errResp struct {
+			ErrorCode int    `json:"error_code"`
+			Message   string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&errResp)
+		return fmt.Errorf("status %d, error code %d: %s", resp.StatusCode, errResp.ErrorCode, errResp.Message)
+	}
+	return json.NewDecoder(resp.Body).Decode(respBody)
+}
+
+// InMemorySchemaRegistry local schema registry stand-in, can be used in test or local development
+type InMemorySchemaRegistry struct {
+	mu      sync.Mutex
+	ids     map[string]int
+	schemas []Schema
+}
+
+// NewInMemorySchemaRegistry constructor
+func NewInMemorySchemaRegistry() *InMemorySchemaRegistry {
+	return &InMemorySchemaRegistry{ids: make(map[string]int)}
+}
+
+// Register method, schema id is started from 1
+func (r *InMemorySchemaRegistry) Register(ctx context.Context, subject string, schema Schema) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cacheKey := subject + "\x00" + string(schema.Type) + "\x00" + schema.Schema
+	if id, ok := r.ids[cacheKey]; ok {
+		return id, nil
+	}
+	r.schemas = append(r.schemas, schema)
+	r.ids[cacheKey] = len(r.schemas)
+	return len(r.schemas), nil
+}
+
+// GetSchema method
+func (r *InMemorySchemaRegistry) GetSchema(ctx context.Context, id int) (Schema, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if id <= 0 || id > len(r.schemas) {
+		return Schema{}, fmt.Errorf("codec: schema id %d not found", id)
+	}
+	return r.schemas[id-1], nil
+}
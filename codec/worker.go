@@ -0,0 +1,43 @@
+package codec
+
+import (
+	"context"
+
+	"github.com/golangid/candi/candishared"
+	"github.com/golangid/candi/codebase/factory/types"
+	"github.com/golangid/candi/codebase/interfaces"
+)
+
+type publisher struct {
+	pub   interfaces.Publisher
+	codec Codec
+}
+
+// NewPublisher wrap publisher, data is encoded by codec with subject from topic ("{topic}-value") before published.
+// Data which not valid with schema is rejected and not published
+func NewPublisher(pub interfaces.Publisher, codec Codec) interfaces.Publisher {
+	return &publisher{pub: pub, codec: codec}
+}
+
+func (p *publisher) PublishMessage(ctx context.Context, args *candishared.PublisherArgument) error {
+	message, err := p.codec.Encode(ctx, TopicSubject(args.Topic), args.Data)
+	if err != nil {
+		return err
+	}
+
+	encodedArgs := *args
+	encodedArgs.Data = message
+	return p.pub.PublishMessage(ctx, &encodedArgs)
+}
+
+// DecodeHandler wrap worker handler func, consumed message is decoded by codec to new target from newTarget
+// (must be pointer) before passed to handler. Decode error is returned as handler error
+func DecodeHandler(codec Codec, newTarget func() interface{}, handlerFunc func(ctx context.Context, data interface{}) error) types.WorkerHandlerFunc {
+	return func(ctx context.Context, message []byte) error {
+		target := newTarget()
+		if err := codec.Decode(ctx, message, target); err != nil {
+			return err
+		}
+		return handlerFunc(ctx, target)
+	}
+}
@@ -3,10 +3,16 @@ package broker
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Shopify/sarama"
 
 	"github.com/golangid/candi/candihelper"
 	"github.com/golangid/candi/codebase/factory/types"
 	"github.com/golangid/candi/codebase/interfaces"
+	"github.com/golangid/candi/config/env"
 	"github.com/golangid/candi/logger"
 )
 
@@ -27,19 +33,48 @@ func SetRabbitMQ(bk *RabbitMQBroker) OptionFunc {
 	}
 }
 
+// SetRedis set redis broker
+func SetRedis(bk *RedisBroker) OptionFunc {
+	return func(bi *brokerInstance) {
+		bi.redis = bk
+	}
+}
+
+// SetRedisStream set redis stream broker
+func SetRedisStream(bk *RedisStreamBroker) OptionFunc {
+	return func(bi *brokerInstance) {
+		bi.redisStream = bk
+	}
+}
+
 type brokerInstance struct {
-	kafka    *KafkaBroker
-	rabbitmq *RabbitMQBroker
+	kafka       *KafkaBroker
+	rabbitmq    *RabbitMQBroker
+	redis       *RedisBroker
+	redisStream *RedisStreamBroker
 }
 
 /*
 InitBrokers init registered broker
 
 * for kafka, pass NewKafkaBroker(...KafkaOptionFunc) in param, init kafka broker configuration from env
-KAFKA_BROKERS, KAFKA_CLIENT_ID, KAFKA_CLIENT_VERSION
+KAFKA_BROKERS, KAFKA_CLIENT_ID, KAFKA_CLIENT_VERSION, publisher from KAFKA_PUBLISHER_MODE (sync or async),
+KAFKA_PRODUCER_COMPRESSION, KAFKA_PRODUCER_IDEMPOTENT, KAFKA_PRODUCER_FLUSH_INTERVAL, KAFKA_PRODUCER_FLUSH_MESSAGES.
+Pending messages in async publisher are flushed when disconnect.
+Security from KAFKA_SASL_MECHANISM (PLAIN, SCRAM-SHA-256, SCRAM-SHA-512), KAFKA_SASL_USERNAME, KAFKA_SASL_PASSWORD,
+KAFKA_TLS_ENABLED, KAFKA_TLS_CA_FILE, KAFKA_TLS_CERT_FILE, KAFKA_TLS_KEY_FILE, KAFKA_TLS_SKIP_VERIFY.
+Consumer from KAFKA_CONSUMER_INITIAL_OFFSET, KAFKA_CONSUMER_REBALANCE_STRATEGY, KAFKA_CONSUMER_SESSION_TIMEOUT,
+KAFKA_CONSUMER_HEARTBEAT_INTERVAL, KAFKA_CONSUMER_REBALANCE_TIMEOUT
 
 * for rabbitmq, pass NewRabbitMQBroker(...RabbitMQOptionFunc) in param, init rabbitmq broker configuration from env
-RABBITMQ_BROKER, RABBITMQ_CONSUMER_GROUP, RABBITMQ_EXCHANGE_NAME
+RABBITMQ_BROKER, RABBITMQ_CONSUMER_GROUP, RABBITMQ_EXCHANGE_NAME.
+Connection is reopened with backoff when closed, GetConfiguration(types.RabbitMQ) return current default *amqp.Channel,
+use RabbitMQ() for get *RabbitMQBroker
+
+* for redis subscriber, pass NewRedisBroker(redisPool, ...RedisOptionFunc) in param, publish to delayed queue if
+REDIS_WORKER_QUEUE_MODE is "sorted_set"
+
+* for redis stream, pass NewRedisStreamBroker(redisPool, ...RedisStreamOptionFunc) in param
 */
 func InitBrokers(opts ...OptionFunc) interfaces.Broker {
 	brokerInst := new(brokerInstance)
@@ -55,17 +90,33 @@ func (b *brokerInstance) GetConfiguration(brokerType types.Worker) interface{} {
 	case types.Kafka:
 		return b.kafka.client
 	case types.RabbitMQ:
-		return b.rabbitmq.ch
+		if b.rabbitmq == nil {
+			return nil
+		}
+		return b.rabbitmq.Channel()
+	case types.RedisSubscriber:
+		return b.redis.pool
+	case types.RedisStream:
+		return b.redisStream.pool
 	}
 	return nil
 }
 
+// RabbitMQ get rabbitmq broker, nil if not set
+func (b *brokerInstance) RabbitMQ() *RabbitMQBroker {
+	return b.rabbitmq
+}
+
 func (b *brokerInstance) Publisher(brokerType types.Worker) interfaces.Publisher {
 	switch brokerType {
 	case types.Kafka:
 		return b.kafka.pub
 	case types.RabbitMQ:
 		return b.rabbitmq.pub
+	case types.RedisSubscriber:
+		return b.redis.pub
+	case types.RedisStream:
+		return b.redisStream.pub
 	}
 	return nil
 }
@@ -73,22 +124,68 @@ func (b *brokerInstance) Publisher(brokerType types.Worker) interfaces.Publisher
 func (b *brokerInstance) Health() map[string]error {
 	mErr := make(map[string]error)
 
-	if b.kafka.client != nil {
+	if b.kafka != nil && b.kafka.client != nil {
 		var err error
 		if len(b.kafka.client.Brokers()) == 0 {
 			err = errors.New("not ok")
 		}
 		mErr[string(types.Kafka)] = err
+
+		if env.BaseEnv().UseKafkaConsumer {
+			mErr[string(types.Kafka)+"_consumer"] = kafkaConsumerHealth(b.kafka.client)
+		}
 	}
 
-	if b.rabbitmq.conn != nil {
-		var err error
-		mErr[string(types.RabbitMQ)] = err
+	if b.rabbitmq != nil {
+		mErr[string(types.RabbitMQ)] = b.rabbitmq.Health()
+	}
+
+	if b.redis != nil {
+		conn := b.redis.pool.Get()
+		_, err := conn.Do("PING")
+		conn.Close()
+		mErr[string(types.RedisSubscriber)] = err
+	}
+
+	if b.redisStream != nil {
+		conn := b.redisStream.pool.Get()
+		_, err := conn.Do("PING")
+		conn.Close()
+		mErr[string(types.RedisStream)] = err
 	}
 
 	return mErr
 }
 
+// kafkaConsumerHealth check consumer is in consumer group session and total lag is not exceed KAFKA_MAX_CONSUMER_LAG environment (if set).
+// Not joined is failed only if longer than session timeout plus rebalance timeout, because it is normal when starting or rebalancing.
+// Total lag and assigned partitions are included in error message
+func kafkaConsumerHealth(client sarama.Client) error {
+	lags, err := KafkaConsumerLag(client)
+	if err != nil {
+		return err
+	}
+	var totalLag int64
+	for _, lag := range lags {
+		totalLag += lag.Lag
+	}
+
+	groupConfig := client.Config().Consumer.Group
+	gracePeriod := groupConfig.Session.Timeout + groupConfig.Rebalance.Timeout
+	if notJoined := kafkaConsumerNotJoined(); notJoined > gracePeriod {
+		return fmt.Errorf("consumer is not joined in consumer group for %s, total lag %d", notJoined.Truncate(time.Second), totalLag)
+	}
+	if maxLag := env.BaseEnv().Kafka.MaxConsumerLag; maxLag > 0 && totalLag > maxLag {
+		_, assigned := KafkaConsumerAssignment()
+		var totalAssigned int
+		for _, partitions := range assigned {
+			totalAssigned += len(partitions)
+		}
+		return fmt.Errorf("consumer lag %d exceed maximum %d, %d partitions assigned to this instance", totalLag, maxLag, totalAssigned)
+	}
+	return nil
+}
+
 func (b *brokerInstance) Disconnect(ctx context.Context) error {
 
 	mErr := candihelper.NewMultiError()
@@ -96,6 +193,12 @@ func (b *brokerInstance) Disconnect(ctx context.Context) error {
 		func() {
 			deferFunc := logger.LogWithDefer("kafka: disconnect...")
 			defer deferFunc()
+			// flush pending messages in publisher
+			if closer, ok := b.kafka.pub.(io.Closer); ok {
+				if err := closer.Close(); err != nil {
+					mErr.Append(string(types.Kafka)+"_publisher", err)
+				}
+			}
 			if err := b.kafka.client.Close(); err != nil {
 				mErr.Append(string(types.Kafka), err)
 			}
@@ -106,7 +209,7 @@ func (b *brokerInstance) Disconnect(ctx context.Context) error {
 		func() {
 			deferFunc := logger.LogWithDefer("rabbitmq: disconnect...")
 			defer deferFunc()
-			if err := b.rabbitmq.conn.Close(); err != nil {
+			if err := b.rabbitmq.Close(); err != nil {
 				mErr.Append(string(types.RabbitMQ), err)
 			}
 		}()
@@ -1,6 +1,7 @@
 package broker
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/Shopify/sarama"
@@ -27,11 +28,23 @@ func KafkaSetPublisher(pub interfaces.Publisher) KafkaOptionFunc {
 	}
 }
 
+// KafkaSetAsyncPublisher use async publisher (batched, PublishMessage does not wait broker response),
+// default publisher mode is from KAFKA_PUBLISHER_MODE environment
+func KafkaSetAsyncPublisher(opts ...publisher.KafkaAsyncOptionFunc) KafkaOptionFunc {
+	return func(kb *KafkaBroker) {
+		kb.asyncPublisher = true
+		kb.asyncPublisherOpts = opts
+	}
+}
+
 // KafkaBroker configuration
 type KafkaBroker struct {
 	config *sarama.Config
 	client sarama.Client
 	pub    interfaces.Publisher
+
+	asyncPublisher     bool
+	asyncPublisherOpts []publisher.KafkaAsyncOptionFunc
 }
 
 // NewKafkaBroker constructor with option, empty option param for default configuration
@@ -40,6 +53,7 @@ func NewKafkaBroker(opts ...KafkaOptionFunc) *KafkaBroker {
 	defer deferFunc()
 
 	kb := new(KafkaBroker)
+	kb.asyncPublisher = env.BaseEnv().Kafka.PublisherMode == "async"
 	for _, opt := range opts {
 		opt(kb)
 	}
@@ -60,10 +74,56 @@ func NewKafkaBroker(opts ...KafkaOptionFunc) *KafkaBroker {
 		kb.config.Producer.Retry.Backoff = 50 * time.Millisecond
 		kb.config.Producer.RequiredAcks = sarama.WaitForAll
 		kb.config.Producer.Return.Successes = true
+		kb.config.Producer.Flush.Frequency = env.BaseEnv().Kafka.ProducerFlushInterval
+		kb.config.Producer.Flush.Messages = env.BaseEnv().Kafka.ProducerFlushMessages
+		switch env.BaseEnv().Kafka.ProducerCompression {
+		case "gzip":
+			kb.config.Producer.Compression = sarama.CompressionGZIP
+		case "snappy":
+			kb.config.Producer.Compression = sarama.CompressionSnappy
+		case "lz4":
+			kb.config.Producer.Compression = sarama.CompressionLZ4
+		case "zstd":
+			kb.config.Producer.Compression = sarama.CompressionZSTD
+		}
+		if env.BaseEnv().Kafka.ProducerIdempotent {
+			kb.config.Producer.Idempotent = true
+			kb.config.Net.MaxOpenRequests = 1
+		}
 
 		// Consumer config
 		kb.config.Consumer.Offsets.Initial = sarama.OffsetOldest
-		kb.config.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategyRoundRobin
+		if env.BaseEnv().Kafka.ConsumerInitialOffset == "newest" {
+			kb.config.Consumer.Offsets.Initial = sarama.OffsetNewest
+		}
+		switch env.BaseEnv().Kafka.ConsumerRebalanceStrategy {
+		case "range":
+			kb.config.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategyRange
+		case "sticky":
+			kb.config.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategySticky
+		default:
+			kb.config.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategyRoundRobin
+		}
+		if timeout := env.BaseEnv().Kafka.ConsumerSessionTimeout; timeout > 0 {
+			kb.config.Consumer.Group.Session.Timeout = timeout
+		}
+		if interval := env.BaseEnv().Kafka.ConsumerHeartbeatInterval; interval > 0 {
+			kb.config.Consumer.Group.Heartbeat.Interval = interval
+		}
+		if timeout := env.BaseEnv().Kafka.ConsumerRebalanceTimeout; timeout > 0 {
+			kb.config.Consumer.Group.Rebalance.Timeout = timeout
+		}
+		// offset is committed manually by kafka worker in at-least-once mode
+		kb.config.Consumer.Offsets.AutoCommit.Enable = !env.BaseEnv().Kafka.AtLeastOnce
+
+		// Security config
+		setKafkaSASL(kb.config)
+		if err := setKafkaTLS(kb.config); err != nil {
+			panic(err)
+		}
+		if err := kb.config.Validate(); err != nil {
+			panic(fmt.Errorf("invalid kafka configuration: %v", err))
+		}
 	}
 
 	saramaClient, err := sarama.NewClient(env.BaseEnv().Kafka.Brokers, kb.config)
@@ -73,7 +133,11 @@ func NewKafkaBroker(opts ...KafkaOptionFunc) *KafkaBroker {
 	kb.client = saramaClient
 
 	if kb.pub == nil {
-		kb.pub = publisher.NewKafkaPublisher(saramaClient)
+		if kb.asyncPublisher {
+			kb.pub = publisher.NewKafkaAsyncPublisher(saramaClient, kb.asyncPublisherOpts...)
+		} else {
+			kb.pub = publisher.NewKafkaPublisher(saramaClient)
+		}
 	}
 
 	return kb
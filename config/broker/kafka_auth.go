@@ -0,0 +1,96 @@
+package broker
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/Shopify/sarama"
+	"github.com/golangid/candi/config/env"
+	"github.com/xdg-go/scram"
+)
+
+// setKafkaSASL set SASL authentication from environment KAFKA_SASL_MECHANISM, KAFKA_SASL_USERNAME, KAFKA_SASL_PASSWORD
+func setKafkaSASL(cfg *sarama.Config) {
+	kafkaEnv := env.BaseEnv().Kafka
+	if kafkaEnv.SASLMechanism == "" {
+		return
+	}
+
+	cfg.Net.SASL.Enable = true
+	cfg.Net.SASL.Handshake = true
+	cfg.Net.SASL.User = kafkaEnv.SASLUsername
+	cfg.Net.SASL.Password = kafkaEnv.SASLPassword
+	switch kafkaEnv.SASLMechanism {
+	case sarama.SASLTypePlaintext:
+		cfg.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+	case sarama.SASLTypeSCRAMSHA256:
+		cfg.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA256
+		cfg.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &kafkaSCRAMClient{hashGenerator: sha256.New}
+		}
+	case sarama.SASLTypeSCRAMSHA512:
+		cfg.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA512
+		cfg.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &kafkaSCRAMClient{hashGenerator: sha512.New}
+		}
+	}
+}
+
+// setKafkaTLS set TLS from environment KAFKA_TLS_ENABLED, KAFKA_TLS_CA_FILE, KAFKA_TLS_CERT_FILE, KAFKA_TLS_KEY_FILE, KAFKA_TLS_SKIP_VERIFY
+func setKafkaTLS(cfg *sarama.Config) error {
+	kafkaEnv := env.BaseEnv().Kafka
+	if !kafkaEnv.TLSEnabled {
+		return nil
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: kafkaEnv.TLSSkipVerify}
+	if kafkaEnv.TLSCAFile != "" {
+		caCert, err := ioutil.ReadFile(kafkaEnv.TLSCAFile)
+		if err != nil {
+			return fmt.Errorf("kafka tls: cannot read CA file: %v", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caCert) {
+			return errors.New("kafka tls: invalid CA file, no certificate found")
+		}
+	}
+	if kafkaEnv.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(kafkaEnv.TLSCertFile, kafkaEnv.TLSKeyFile)
+		if err != nil {
+			return fmt.Errorf("kafka tls: cannot load client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	cfg.Net.TLS.Enable = true
+	cfg.Net.TLS.Config = tlsConfig
+	return nil
+}
+
+// kafkaSCRAMClient implement sarama.SCRAMClient
+type kafkaSCRAMClient struct {
+	hashGenerator scram.HashGeneratorFcn
+	conversation  *scram.ClientConversation
+}
+
+func (c *kafkaSCRAMClient) Begin(userName, password, authzID string) error {
+	client, err := c.hashGenerator.NewClient(userName, password, authzID)
+	if err != nil {
+		return err
+	}
+	c.conversation = client.NewConversation()
+	return nil
+}
+
+func (c *kafkaSCRAMClient) Step(challenge string) (string, error) {
+	return c.conversation.Step(challenge)
+}
+
+func (c *kafkaSCRAMClient) Done() bool {
+	return c.conversation.Done()
+}
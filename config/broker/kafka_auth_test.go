@@ -0,0 +1,150 @@
+package broker
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/golangid/candi/config/env"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetKafkaSASL(t *testing.T) {
+	baseEnv := env.BaseEnv()
+	defer env.SetEnv(baseEnv)
+
+	cfg := sarama.NewConfig()
+	setKafkaSASL(cfg)
+	assert.False(t, cfg.Net.SASL.Enable, "sasl is not active without KAFKA_SASL_MECHANISM")
+
+	tests := []struct {
+		mechanism string
+		scram     bool
+	}{
+		{mechanism: sarama.SASLTypePlaintext},
+		{mechanism: sarama.SASLTypeSCRAMSHA256, scram: true},
+		{mechanism: sarama.SASLTypeSCRAMSHA512, scram: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mechanism, func(t *testing.T) {
+			newEnv := baseEnv
+			newEnv.Kafka.SASLMechanism, newEnv.Kafka.SASLUsername, newEnv.Kafka.SASLPassword = tt.mechanism, "user", "secret"
+			env.SetEnv(newEnv)
+
+			cfg := sarama.NewConfig()
+			setKafkaSASL(cfg)
+			assert.True(t, cfg.Net.SASL.Enable)
+			assert.True(t, cfg.Net.SASL.Handshake)
+			assert.Equal(t, "user", cfg.Net.SASL.User)
+			assert.Equal(t, "secret", cfg.Net.SASL.Password)
+			assert.Equal(t, sarama.SASLMechanism(tt.mechanism), cfg.Net.SASL.Mechanism)
+			if !tt.scram {
+				assert.Nil(t, cfg.Net.SASL.SCRAMClientGeneratorFunc)
+				return
+			}
+
+			client := cfg.Net.SASL.SCRAMClientGeneratorFunc()
+			assert.NoError(t, client.Begin("user", "secret", ""))
+			first, err := client.Step("")
+			assert.NoError(t, err)
+			assert.Contains(t, first, "n=user")
+			assert.False(t, client.Done())
+		})
+	}
+}
+
+func TestSetKafkaTLS(t *testing.T) {
+	baseEnv := env.BaseEnv()
+	defer env.SetEnv(baseEnv)
+
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, dir)
+	invalidFile := filepath.Join(dir, "invalid.pem")
+	assert.NoError(t, ioutil.WriteFile(invalidFile, []byte("not a certificate"), 0600))
+
+	cfg := sarama.NewConfig()
+	assert.NoError(t, setKafkaTLS(cfg))
+	assert.False(t, cfg.Net.TLS.Enable, "tls is not active without KAFKA_TLS_ENABLED")
+
+	t.Run("skip verify", func(t *testing.T) {
+		newEnv := baseEnv
+		newEnv.Kafka.TLSEnabled, newEnv.Kafka.TLSSkipVerify = true, true
+		env.SetEnv(newEnv)
+
+		cfg := sarama.NewConfig()
+		assert.NoError(t, setKafkaTLS(cfg))
+		assert.True(t, cfg.Net.TLS.Enable)
+		assert.True(t, cfg.Net.TLS.Config.InsecureSkipVerify)
+		assert.Nil(t, cfg.Net.TLS.Config.RootCAs)
+		assert.Empty(t, cfg.Net.TLS.Config.Certificates)
+	})
+	t.Run("ca and client certificate", func(t *testing.T) {
+		newEnv := baseEnv
+		newEnv.Kafka.TLSEnabled = true
+		newEnv.Kafka.TLSCAFile, newEnv.Kafka.TLSCertFile, newEnv.Kafka.TLSKeyFile = certFile, certFile, keyFile
+		env.SetEnv(newEnv)
+
+		cfg := sarama.NewConfig()
+		assert.NoError(t, setKafkaTLS(cfg))
+		assert.True(t, cfg.Net.TLS.Enable)
+		assert.False(t, cfg.Net.TLS.Config.InsecureSkipVerify)
+		assert.NotNil(t, cfg.Net.TLS.Config.RootCAs)
+		assert.Len(t, cfg.Net.TLS.Config.Certificates, 1)
+	})
+
+	errorTests := []struct {
+		name, caFile, certFile, keyFile, wantErr string
+	}{
+		{name: "missing ca file", caFile: filepath.Join(dir, "missing.pem"), wantErr: "kafka tls: cannot read CA file"},
+		{name: "invalid ca file", caFile: invalidFile, wantErr: "kafka tls: invalid CA file, no certificate found"},
+		{name: "invalid client certificate", certFile: invalidFile, keyFile: keyFile, wantErr: "kafka tls: cannot load client certificate"},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			newEnv := baseEnv
+			newEnv.Kafka.TLSEnabled = true
+			newEnv.Kafka.TLSCAFile, newEnv.Kafka.TLSCertFile, newEnv.Kafka.TLSKeyFile = tt.caFile, tt.certFile, tt.keyFile
+			env.SetEnv(newEnv)
+
+			cfg := sarama.NewConfig()
+			err := setKafkaTLS(cfg)
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.wantErr)
+			}
+			assert.False(t, cfg.Net.TLS.Enable)
+		})
+	}
+}
+
+// writeTestCertificate write self signed certificate and key in PEM format, certificate is also used as CA
+func writeTestCertificate(t *testing.T, dir string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kafka"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	assert.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}), 0600))
+	assert.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
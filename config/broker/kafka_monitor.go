@@ -0,0 +1,208 @@
+package broker
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/golangid/candi/config/env"
+)
+
+// KafkaPartitionLag consumer group lag of partition
+type KafkaPartitionLag struct {
+	Topic     string
+	Partition int32
+	// Committed offset of consumer group, -1 if consumer group has no committed offset
+	Committed int64
+	// Newest offset (high water mark) of partition
+	Newest int64
+	Lag    int64
+}
+
+// kafkaConsumerMonitor track consumer group of kafka worker in this instance
+type kafkaConsumerMonitor struct {
+	mu       sync.RWMutex
+	group    string
+	topics   []string
+	joined   bool
+	assigned map[string][]int32
+	// leftAt time when consumer is registered or session is ended, zero if joined
+	leftAt time.Time
+
+	lagMu      sync.Mutex // lag is fetched by one caller at a time, another caller wait for the cached result
+	lags       []KafkaPartitionLag
+	lagErr     error
+	lagFetched time.Time
+}
+
+var kafkaMonitor kafkaConsumerMonitor
+
+// RegisterKafkaConsumer register consumer group and topics of kafka worker for health and lag reporting
+func RegisterKafkaConsumer(group string, topics []string) {
+	kafkaMonitor.mu.Lock()
+	defer kafkaMonitor.mu.Unlock()
+	kafkaMonitor.group, kafkaMonitor.topics = group, topics
+	kafkaMonitor.joined, kafkaMonitor.assigned, kafkaMonitor.leftAt = false, nil, time.Now()
+
+	kafkaMonitor.lagMu.Lock()
+	kafkaMonitor.lagFetched = time.Time{}
+	kafkaMonitor.lagMu.Unlock()
+}
+
+// SetKafkaConsumerAssignment set assigned partitions in current consumer group session, nil claims if session is ended
+func SetKafkaConsumerAssignment(claims map[string][]int32) {
+	kafkaMonitor.mu.Lock()
+	defer kafkaMonitor.mu.Unlock()
+	switch {
+	case claims != nil:
+		kafkaMonitor.leftAt = time.Time{}
+	case kafkaMonitor.joined || kafkaMonitor.leftAt.IsZero():
+		kafkaMonitor.leftAt = time.Now()
+	}
+	kafkaMonitor.joined, kafkaMonitor.assigned = claims != nil, claims
+}
+
+// KafkaConsumerAssignment get assigned partitions of kafka worker in this instance,
+// joined is false if consumer is not in consumer group session (starting or rebalancing)
+func KafkaConsumerAssignment() (joined bool, assigned map[string][]int32) {
+	kafkaMonitor.mu.RLock()
+	defer kafkaMonitor.mu.RUnlock()
+	return kafkaMonitor.joined, kafkaMonitor.assigned
+}
+
+// kafkaConsumerNotJoined get duration since consumer is not in consumer group session, zero if joined or consumer is not registered
+func kafkaConsumerNotJoined() time.Duration {
+	kafkaMonitor.mu.RLock()
+	defer kafkaMonitor.mu.RUnlock()
+	if kafkaMonitor.group == "" || kafkaMonitor.joined {
+		return 0
+	}
+	return time.Since(kafkaMonitor.leftAt)
+}
+
+// KafkaConsumerLag get lag of registered consumer group for each partition of registered topics,
+// result (include error) is cached in KAFKA_CONSUMER_LAG_CACHE_TTL so that health check and metrics scrape do not flood broker
+func KafkaConsumerLag(client sarama.Client) ([]KafkaPartitionLag, error) {
+	kafkaMonitor.lagMu.Lock()
+	defer kafkaMonitor.lagMu.Unlock()
+	if !kafkaMonitor.lagFetched.IsZero() && time.Since(kafkaMonitor.lagFetched) < env.BaseEnv().Kafka.ConsumerLagCacheTTL {
+		return kafkaMonitor.lags, kafkaMonitor.lagErr
+	}
+
+	kafkaMonitor.lags, kafkaMonitor.lagErr = fetchKafkaConsumerLag(client)
+	kafkaMonitor.lagFetched = time.Now()
+	return kafkaMonitor.lags, kafkaMonitor.lagErr
+}
+
+// fetchKafkaConsumerLag fetch committed offset of consumer group and newest offset of partitions from broker
+func fetchKafkaConsumerLag(client sarama.Client) ([]KafkaPartitionLag, error) {
+	kafkaMonitor.mu.RLock()
+	group, topics := kafkaMonitor.group, kafkaMonitor.topics
+	kafkaMonitor.mu.RUnlock()
+	if group == "" {
+		return nil, nil
+	}
+
+	request := &sarama.OffsetFetchRequest{ConsumerGroup: group, Version: 1}
+	partitions := make(map[string][]int32, len(topics))
+	var totalPartitions int
+	for _, topic := range topics {
+		topicPartitions, err := client.Partitions(topic)
+		if err == sarama.ErrUnknownTopicOrPartition {
+			continue // topic is not created yet or deleted, no lag to report
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot get partitions of topic %s: %v", topic, err)
+		}
+		partitions[topic] = topicPartitions
+		totalPartitions += len(topicPartitions)
+		for _, partition := range topicPartitions {
+			request.AddPartition(topic, partition)
+		}
+	}
+	if totalPartitions == 0 {
+		return nil, nil
+	}
+
+	coordinator, err := client.Coordinator(group)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get coordinator of consumer group %s: %v", group, err)
+	}
+	response, err := coordinator.FetchOffset(request)
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch offset of consumer group %s: %v", group, err)
+	}
+
+	var result []KafkaPartitionLag
+	for _, topic := range topics {
+		for _, partition := range partitions[topic] {
+			lag := KafkaPartitionLag{Topic: topic, Partition: partition, Committed: -1}
+			if block := response.GetBlock(topic, partition); block != nil {
+				if block.Err != sarama.ErrNoError {
+					return nil, fmt.Errorf("cannot fetch offset of %s/%d: %v", topic, partition, block.Err)
+				}
+				lag.Committed = block.Offset
+			}
+			if lag.Newest, err = client.GetOffset(topic, partition, sarama.OffsetNewest); err != nil {
+				return nil, fmt.Errorf("cannot get newest offset of %s/%d: %v", topic, partition, err)
+			}
+
+			from := lag.Committed
+			if from < 0 { // no committed offset, all messages in partition is not consumed
+				if from, err = client.GetOffset(topic, partition, sarama.OffsetOldest); err != nil {
+					return nil, fmt.Errorf("cannot get oldest offset of %s/%d: %v", topic, partition, err)
+				}
+			}
+			if lag.Lag = lag.Newest - from; lag.Lag < 0 {
+				lag.Lag = 0
+			}
+			result = append(result, lag)
+		}
+	}
+	return result, nil
+}
+
+// NewKafkaMetricsHandler http handler for kafka consumer metrics in prometheus text format
+func NewKafkaMetricsHandler(client sarama.Client) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		kafkaMonitor.mu.RLock()
+		group := kafkaMonitor.group
+		kafkaMonitor.mu.RUnlock()
+
+		lags, err := KafkaConsumerLag(client)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		joined, assigned := KafkaConsumerAssignment()
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		fmt.Fprintln(w, "# HELP kafka_consumer_lag Total messages not consumed by consumer group in partition.")
+		fmt.Fprintln(w, "# TYPE kafka_consumer_lag gauge")
+		for _, lag := range lags {
+			fmt.Fprintf(w, "kafka_consumer_lag{group=%q,topic=%q,partition=\"%d\"} %d\n", group, lag.Topic, lag.Partition, lag.Lag)
+		}
+
+		fmt.Fprintln(w, "# HELP kafka_consumer_assigned_partitions Total partitions assigned to this instance.")
+		fmt.Fprintln(w, "# TYPE kafka_consumer_assigned_partitions gauge")
+		topics := make([]string, 0, len(assigned))
+		for topic := range assigned {
+			topics = append(topics, topic)
+		}
+		sort.Strings(topics)
+		for _, topic := range topics {
+			fmt.Fprintf(w, "kafka_consumer_assigned_partitions{group=%q,topic=%q} %d\n", group, topic, len(assigned[topic]))
+		}
+
+		fmt.Fprintln(w, "# HELP kafka_consumer_joined Whether this instance is in consumer group session (1) or not (0).")
+		fmt.Fprintln(w, "# TYPE kafka_consumer_joined gauge")
+		joinedValue := 0
+		if joined {
+			joinedValue = 1
+		}
+		fmt.Fprintf(w, "kafka_consumer_joined{group=%q} %d\n", group, joinedValue)
+	})
+}
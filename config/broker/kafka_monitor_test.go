@@ -0,0 +1,87 @@
+package broker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/golangid/candi/config/env"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeKafkaClient count partitions request, all topics is not exist
+type fakeKafkaClient struct {
+	sarama.Client
+	config         *sarama.Config
+	partitionCalls int
+}
+
+func (c *fakeKafkaClient) Config() *sarama.Config {
+	if c.config == nil {
+		return sarama.NewConfig()
+	}
+	return c.config
+}
+
+func (c *fakeKafkaClient) Partitions(topic string) ([]int32, error) {
+	c.partitionCalls++
+	return nil, sarama.ErrUnknownTopicOrPartition
+}
+
+func TestKafkaConsumerLag(t *testing.T) {
+	baseEnv := env.BaseEnv()
+	defer env.SetEnv(baseEnv)
+	newEnv := baseEnv
+	newEnv.Kafka.ConsumerLagCacheTTL = 50 * time.Millisecond
+	newEnv.Kafka.MaxConsumerLag = 10
+	env.SetEnv(newEnv)
+
+	RegisterKafkaConsumer("group", []string{"orders", "payments"})
+	defer RegisterKafkaConsumer("", nil)
+	SetKafkaConsumerAssignment(nil)
+
+	client := &fakeKafkaClient{}
+	lags, err := KafkaConsumerLag(client)
+	assert.NoError(t, err, "missing topic is skipped")
+	assert.Empty(t, lags)
+	assert.Equal(t, 2, client.partitionCalls)
+
+	assert.NoError(t, kafkaConsumerHealth(client), "not joined in grace period is not failed")
+	assert.Equal(t, 2, client.partitionCalls, "lag is cached")
+
+	time.Sleep(60 * time.Millisecond)
+	KafkaConsumerLag(client)
+	assert.Equal(t, 4, client.partitionCalls, "lag is fetched again after cache expired")
+}
+
+func TestKafkaConsumerHealthNotJoined(t *testing.T) {
+	baseEnv := env.BaseEnv()
+	defer env.SetEnv(baseEnv)
+	newEnv := baseEnv
+	newEnv.Kafka.MaxConsumerLag = 0
+	env.SetEnv(newEnv)
+
+	config := sarama.NewConfig()
+	config.Consumer.Group.Session.Timeout, config.Consumer.Group.Rebalance.Timeout = 10*time.Millisecond, 10*time.Millisecond
+	client := &fakeKafkaClient{config: config}
+
+	RegisterKafkaConsumer("group", []string{"orders"})
+	defer RegisterKafkaConsumer("", nil)
+	assert.NoError(t, kafkaConsumerHealth(client), "not joined when starting is not failed in grace period")
+
+	time.Sleep(30 * time.Millisecond)
+	assert.EqualError(t, kafkaConsumerHealth(client), "consumer is not joined in consumer group for 0s, total lag 0")
+
+	SetKafkaConsumerAssignment(map[string][]int32{"orders": {0, 1}})
+	assert.NoError(t, kafkaConsumerHealth(client))
+	joined, assigned := KafkaConsumerAssignment()
+	assert.True(t, joined)
+	assert.Equal(t, map[string][]int32{"orders": {0, 1}}, assigned)
+
+	// session is ended for rebalance, failed only if not joined again after grace period
+	SetKafkaConsumerAssignment(nil)
+	SetKafkaConsumerAssignment(nil)
+	assert.NoError(t, kafkaConsumerHealth(client))
+	time.Sleep(30 * time.Millisecond)
+	assert.Error(t, kafkaConsumerHealth(client))
+}
@@ -1,6 +1,11 @@
 package broker
 
 import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
 	"github.com/golangid/candi/codebase/interfaces"
 	"github.com/golangid/candi/config/env"
 	"github.com/golangid/candi/logger"
@@ -8,10 +13,15 @@ import (
 	"github.com/streadway/amqp"
 )
 
+const (
+	rabbitmqMinReconnectDelay = time.Second
+	rabbitmqMaxReconnectDelay = 30 * time.Second
+)
+
 // RabbitMQOptionFunc func type
 type RabbitMQOptionFunc func(*RabbitMQBroker)
 
-// RabbitMQSetChannel set custom channel configuration
+// RabbitMQSetChannel set custom channel configuration, replaced with default channel configuration when reconnected
 func RabbitMQSetChannel(ch *amqp.Channel) RabbitMQOptionFunc {
 	return func(bk *RabbitMQBroker) {
 		bk.ch = ch
@@ -25,11 +35,15 @@ func RabbitMQSetPublisher(pub interfaces.Publisher) RabbitMQOptionFunc {
 	}
 }
 
-// RabbitMQBroker broker
+// RabbitMQBroker broker, connection and channel are reopened with backoff when closed by server or network error
 type RabbitMQBroker struct {
-	conn *amqp.Connection
-	ch   *amqp.Channel
-	pub  interfaces.Publisher
+	mu        sync.RWMutex
+	conn      *amqp.Connection
+	ch        *amqp.Channel
+	pub       interfaces.Publisher
+	err       error // not nil when disconnected
+	closed    bool
+	notifiers []chan struct{}
 }
 
 // NewRabbitMQBroker constructor, connection from RABBITMQ_BROKER environment
@@ -50,34 +64,175 @@ func NewRabbitMQBroker(opts ...RabbitMQOptionFunc) *RabbitMQBroker {
 
 	if rabbitmq.ch == nil {
 		// set default configuration
-		rabbitmq.ch, err = rabbitmq.conn.Channel()
+		rabbitmq.ch, err = openRabbitMQChannel(rabbitmq.conn)
 		if err != nil {
-			panic("RabbitMQ channel: " + err.Error())
-		}
-		if err := rabbitmq.ch.ExchangeDeclare("amq.direct", "direct", true, false, false, false, nil); err != nil {
-			panic("RabbitMQ exchange declare direct: " + err.Error())
-		}
-		if err := rabbitmq.ch.ExchangeDeclare(
-			env.BaseEnv().RabbitMQ.ExchangeName, // name
-			"x-delayed-message",                 // type
-			true,                                // durable
-			false,                               // auto-deleted
-			false,                               // internal
-			false,                               // no-wait
-			amqp.Table{
-				"x-delayed-type": "direct",
-			},
-		); err != nil {
-			panic("RabbitMQ exchange declare delayed: " + err.Error())
-		}
-		if err := rabbitmq.ch.Qos(2, 0, false); err != nil {
-			panic("RabbitMQ Qos: " + err.Error())
+			panic(err)
 		}
 	}
 
 	if rabbitmq.pub == nil {
-		rabbitmq.pub = publisher.NewRabbitMQPublisher(rabbitmq.conn)
+		rabbitmq.pub = publisher.NewRabbitMQPublisher(rabbitmq.conn, publisher.RabbitMQSetConnection(rabbitmq.Connection))
 	}
 
+	go rabbitmq.watchConnection()
 	return rabbitmq
 }
+
+// openRabbitMQChannel open channel with default configuration
+func openRabbitMQChannel(conn *amqp.Connection) (*amqp.Channel, error) {
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, errors.New("RabbitMQ channel: " + err.Error())
+	}
+	if err := ch.ExchangeDeclare("amq.direct", "direct", true, false, false, false, nil); err != nil {
+		return nil, errors.New("RabbitMQ exchange declare direct: " + err.Error())
+	}
+	if err := ch.ExchangeDeclare(
+		env.BaseEnv().RabbitMQ.ExchangeName, // name
+		"x-delayed-message",                 // type
+		true,                                // durable
+		false,                               // auto-deleted
+		false,                               // internal
+		false,                               // no-wait
+		amqp.Table{
+			"x-delayed-type": "direct",
+		},
+	); err != nil {
+		return nil, errors.New("RabbitMQ exchange declare delayed: " + err.Error())
+	}
+	if err := ch.Qos(2, 0, false); err != nil {
+		return nil, errors.New("RabbitMQ Qos: " + err.Error())
+	}
+	return ch, nil
+}
+
+// Connection get current connection, connection is replaced when reconnected
+func (r *RabbitMQBroker) Connection() *amqp.Connection {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.conn
+}
+
+// Channel get current default channel, channel is replaced when reconnected
+func (r *RabbitMQBroker) Channel() *amqp.Channel {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.ch
+}
+
+// NotifyReconnect register receiver for signal after connection or default channel is reopened,
+// consumer should setup queue and consume again when received. Signal is dropped if receiver is full
+func (r *RabbitMQBroker) NotifyReconnect(receiver chan struct{}) chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.notifiers = append(r.notifiers, receiver)
+	return receiver
+}
+
+// Health get connection state, return error when disconnected and reconnecting
+func (r *RabbitMQBroker) Health() error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.err
+}
+
+// Close connection, connection is not reopened after closed
+func (r *RabbitMQBroker) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	conn := r.conn
+	r.mu.Unlock()
+	return conn.Close()
+}
+
+// watchConnection reopen connection or default channel when closed
+func (r *RabbitMQBroker) watchConnection() {
+	for {
+		r.mu.RLock()
+		conn, ch := r.conn, r.ch
+		r.mu.RUnlock()
+
+		connClosed := conn.NotifyClose(make(chan *amqp.Error, 1))
+		chClosed := ch.NotifyClose(make(chan *amqp.Error, 1))
+		var reason *amqp.Error
+		select {
+		case reason = <-connClosed:
+		case reason = <-chClosed:
+		}
+
+		r.mu.Lock()
+		if r.closed {
+			r.mu.Unlock()
+			return
+		}
+		r.err = fmt.Errorf("disconnected, reconnecting: %v", reason)
+		r.mu.Unlock()
+		logger.LogRed(fmt.Sprintf("RabbitMQ: connection or channel closed, reconnecting: %v", reason))
+
+		r.reconnect(conn)
+	}
+}
+
+// reconnect open connection (if closed) and default channel with backoff until success or broker is closed
+func (r *RabbitMQBroker) reconnect(conn *amqp.Connection) {
+	delay := rabbitmqMinReconnectDelay
+	for attempt := 1; ; attempt++ {
+		err := func() error {
+			if conn.IsClosed() {
+				newConn, err := amqp.Dial(env.BaseEnv().RabbitMQ.Broker)
+				if err != nil {
+					return err
+				}
+				conn = newConn
+			}
+			ch, err := openRabbitMQChannel(conn)
+			if err != nil {
+				return err
+			}
+
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if r.closed {
+				conn.Close()
+				return nil
+			}
+			r.conn, r.ch, r.err = conn, ch, nil
+			return nil
+		}()
+		if err == nil {
+			break
+		}
+
+		r.mu.Lock()
+		if r.closed {
+			r.mu.Unlock()
+			return
+		}
+		r.err = fmt.Errorf("disconnected, reconnecting (attempt %d): %v", attempt, err)
+		r.mu.Unlock()
+		logger.LogRed(fmt.Sprintf("RabbitMQ: reconnect attempt %d failed, retry in %s: %v", attempt, delay, err))
+
+		time.Sleep(delay)
+		if delay *= 2; delay > rabbitmqMaxReconnectDelay {
+			delay = rabbitmqMaxReconnectDelay
+		}
+	}
+
+	r.notifyReconnected()
+}
+
+// notifyReconnected send signal to all reconnect receivers, full receiver is skipped and nothing is sent after broker is closed
+func (r *RabbitMQBroker) notifyReconnected() {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		return
+	}
+	logger.LogGreen("RabbitMQ: reconnected")
+	for _, receiver := range r.notifiers {
+		select {
+		case receiver <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package broker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRabbitMQNotifyReconnected(t *testing.T) {
+	bk := new(RabbitMQBroker)
+	first := bk.NotifyReconnect(make(chan struct{}, 1))
+	second := bk.NotifyReconnect(make(chan struct{}, 1))
+
+	bk.notifyReconnected()
+	assert.Len(t, first, 1)
+	assert.Len(t, second, 1)
+
+	// receiver is full (signal is not consumed yet), notify must not block
+	bk.notifyReconnected()
+	assert.Len(t, first, 1)
+	<-first
+	<-second
+
+	bk.closed = true
+	bk.notifyReconnected()
+	assert.Len(t, first, 0, "no signal after broker is closed")
+	assert.Len(t, second, 0, "no signal after broker is closed")
+}
@@ -0,0 +1,41 @@
+package broker
+
+import (
+	"github.com/golangid/candi/codebase/interfaces"
+	"github.com/golangid/candi/logger"
+	"github.com/golangid/candi/publisher"
+	"github.com/gomodule/redigo/redis"
+)
+
+// RedisOptionFunc func type
+type RedisOptionFunc func(*RedisBroker)
+
+// RedisSetPublisher set custom publisher
+func RedisSetPublisher(pub interfaces.Publisher) RedisOptionFunc {
+	return func(bk *RedisBroker) {
+		bk.pub = pub
+	}
+}
+
+// RedisBroker broker for publish scheduled job to redis subscriber worker
+type RedisBroker struct {
+	pool *redis.Pool
+	pub  interfaces.Publisher
+}
+
+// NewRedisBroker constructor, pool is not closed when broker disconnected (closed by redis dependency)
+func NewRedisBroker(pool *redis.Pool, opts ...RedisOptionFunc) *RedisBroker {
+	deferFunc := logger.LogWithDefer("Load Redis broker configuration... ")
+	defer deferFunc()
+
+	redisBroker := &RedisBroker{pool: pool}
+	for _, opt := range opts {
+		opt(redisBroker)
+	}
+
+	if redisBroker.pub == nil {
+		redisBroker.pub = publisher.NewRedisPublisher(pool)
+	}
+
+	return redisBroker
+}
@@ -0,0 +1,41 @@
+package broker
+
+import (
+	"github.com/golangid/candi/codebase/interfaces"
+	"github.com/golangid/candi/logger"
+	"github.com/golangid/candi/publisher"
+	"github.com/gomodule/redigo/redis"
+)
+
+// RedisStreamOptionFunc func type
+type RedisStreamOptionFunc func(*RedisStreamBroker)
+
+// RedisStreamSetPublisher set custom publisher
+func RedisStreamSetPublisher(pub interfaces.Publisher) RedisStreamOptionFunc {
+	return func(bk *RedisStreamBroker) {
+		bk.pub = pub
+	}
+}
+
+// RedisStreamBroker broker
+type RedisStreamBroker struct {
+	pool *redis.Pool
+	pub  interfaces.Publisher
+}
+
+// NewRedisStreamBroker constructor, pool is not closed when broker disconnected (closed by redis dependency)
+func NewRedisStreamBroker(pool *redis.Pool, opts ...RedisStreamOptionFunc) *RedisStreamBroker {
+	deferFunc := logger.LogWithDefer("Load Redis Stream broker configuration... ")
+	defer deferFunc()
+
+	redisStream := &RedisStreamBroker{pool: pool}
+	for _, opt := range opts {
+		opt(redisStream)
+	}
+
+	if redisStream.pub == nil {
+		redisStream.pub = publisher.NewRedisStreamPublisher(pool)
+	}
+
+	return redisStream
+}
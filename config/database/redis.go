@@ -2,6 +2,9 @@ package database
 
 import (
 	"context"
+	"errors"
+	"net/url"
+	"time"
 
 	"github.com/golangid/candi/cache"
 	"github.com/golangid/candi/codebase/interfaces"
@@ -29,7 +32,7 @@ func (m *redisInstance) Health() map[string]error {
 	_, err := connWrite.Do("PING")
 	mErr["redis_write"] = err
 
-	connRead := m.write.Get()
+	connRead := m.read.Get()
 	defer connRead.Close()
 	_, err = connRead.Do("PING")
 	mErr["redis_read"] = err
@@ -49,19 +52,66 @@ func (m *redisInstance) Disconnect(ctx context.Context) (err error) {
 	return m.write.Close()
 }
 
-// InitRedis connection from environment:
-// REDIS_READ_DSN, REDIS_READ_TLS
-// REDIS_WRITE_DSN, REDIS_WRITE_TLS
+/*
+InitRedis connection from environment:
+
+REDIS_READ_DSN, REDIS_READ_TLS
+
+REDIS_WRITE_DSN, REDIS_WRITE_TLS
+
+REDIS_MODE (standalone, sentinel, or cluster), host in dsn is ignored in sentinel and cluster mode:
+
+* sentinel: REDIS_SENTINEL_ADDRS, REDIS_SENTINEL_MASTER_NAME, REDIS_SENTINEL_PASSWORD, write pool connect to master
+and read pool connect to replica (fallback to master if no healthy replica)
+
+* cluster: REDIS_CLUSTER_ADDRS, command is routed to node by key slot (follow MOVED and ASK redirection), read pool
+connect to replica of slot with READONLY (fallback to master). Command which require all nodes (KEYS, SCAN, etc) is rejected,
+and keyspace mode redis worker is not supported (use REDIS_WORKER_QUEUE_MODE=sorted_set)
+
+Pool configuration: REDIS_MAX_IDLE, REDIS_MAX_ACTIVE, REDIS_IDLE_TIMEOUT, REDIS_MAX_CONN_LIFETIME
+*/
 func InitRedis() interfaces.RedisPool {
 	deferFunc := logger.LogWithDefer("Load Redis connection...")
 	defer deferFunc()
 
 	inst := new(redisInstance)
-
-	inst.read = &redis.Pool{
-		Dial: func() (redis.Conn, error) {
-			return redis.DialURL(env.BaseEnv().DbRedisReadDSN)
-		},
+	redisEnv := env.BaseEnv().Redis
+	readDialer := newRedisDialer(env.BaseEnv().DbRedisReadDSN, redisEnv.ReadTLS)
+	writeDialer := newRedisDialer(env.BaseEnv().DbRedisWriteDSN, redisEnv.WriteTLS)
+
+	switch redisEnv.Mode {
+	case "sentinel":
+		sentinel := newRedisSentinel(redisEnv.SentinelAddrs, redisEnv.SentinelMasterName, redisEnv.SentinelPassword)
+		inst.read = newRedisPool(func() (redis.Conn, error) {
+			addr, err := sentinel.replicaAddr()
+			if err != nil {
+				return nil, err
+			}
+			return readDialer(addr)
+		}, pingOnBorrow)
+		inst.write = newRedisPool(func() (redis.Conn, error) {
+			addr, err := sentinel.masterAddr()
+			if err != nil {
+				return nil, err
+			}
+			return writeDialer(addr)
+		}, testRoleOnBorrow("master"))
+
+	case "cluster":
+		readCluster, err := newRedisCluster(redisEnv.ClusterAddrs, readDialer, true)
+		if err != nil {
+			panic("redis read: " + err.Error())
+		}
+		writeCluster, err := newRedisCluster(redisEnv.ClusterAddrs, writeDialer, false)
+		if err != nil {
+			panic("redis write: " + err.Error())
+		}
+		inst.read = newRedisPool(readCluster.Dial, pingOnBorrow)
+		inst.write = newRedisPool(writeCluster.Dial, pingOnBorrow)
+
+	default:
+		inst.read = newRedisPool(func() (redis.Conn, error) { return readDialer("") }, pingOnBorrow)
+		inst.write = newRedisPool(func() (redis.Conn, error) { return writeDialer("") }, pingOnBorrow)
 	}
 
 	pingRead := inst.read.Get()
@@ -71,12 +121,6 @@ func InitRedis() interfaces.RedisPool {
 		panic("redis read: " + err.Error())
 	}
 
-	inst.write = &redis.Pool{
-		Dial: func() (redis.Conn, error) {
-			return redis.DialURL(env.BaseEnv().DbRedisWriteDSN)
-		},
-	}
-
 	pingWrite := inst.write.Get()
 	defer pingWrite.Close()
 	_, err = pingWrite.Do("PING")
@@ -88,3 +132,72 @@ func InitRedis() interfaces.RedisPool {
 
 	return inst
 }
+
+func newRedisPool(dial func() (redis.Conn, error), testOnBorrow func(redis.Conn, time.Time) error) *redis.Pool {
+	redisEnv := env.BaseEnv().Redis
+	return &redis.Pool{
+		Dial:            dial,
+		TestOnBorrow:    testOnBorrow,
+		MaxIdle:         redisEnv.MaxIdle,
+		MaxActive:       redisEnv.MaxActive,
+		Wait:            redisEnv.MaxActive > 0,
+		IdleTimeout:     redisEnv.IdleTimeout,
+		MaxConnLifetime: redisEnv.MaxConnLifetime,
+	}
+}
+
+// newRedisDialer create connection with credential and database from dsn, addr replace host in dsn if not empty
+func newRedisDialer(dsn string, useTLS bool) func(addr string) (redis.Conn, error) {
+	opts := []redis.DialOption{redis.DialConnectTimeout(5 * time.Second)}
+	if useTLS {
+		opts = append(opts, redis.DialUseTLS(true))
+	}
+	if env.BaseEnv().Redis.TLSSkipVerify {
+		opts = append(opts, redis.DialTLSSkipVerify(true))
+	}
+
+	return func(addr string) (redis.Conn, error) {
+		if addr == "" {
+			return redis.DialURL(dsn, opts...)
+		}
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme == "" {
+			// dsn is empty in sentinel or cluster mode without credential
+			u.Scheme = "redis"
+		}
+		u.Host = addr
+		return redis.DialURL(u.String(), opts...)
+	}
+}
+
+// pingOnBorrow check idle connection before used
+func pingOnBorrow(c redis.Conn, lastUsed time.Time) error {
+	if time.Since(lastUsed) < time.Minute {
+		return nil
+	}
+	_, err := c.Do("PING")
+	return err
+}
+
+// testRoleOnBorrow check role of connected node, connection is closed if role changed (example: master become replica after failover)
+func testRoleOnBorrow(role string) func(redis.Conn, time.Time) error {
+	return func(c redis.Conn, lastUsed time.Time) error {
+		if time.Since(lastUsed) < time.Second {
+			return nil
+		}
+		values, err := redis.Values(c.Do("ROLE"))
+		if err != nil {
+			return err
+		}
+		if len(values) == 0 {
+			return errors.New("redis: invalid role reply")
+		}
+		if currentRole, _ := redis.String(values[0], nil); currentRole != role {
+			return errors.New("redis: role changed to " + currentRole)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,497 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const redisClusterSlots = 16384
+
+// redisCluster hold slot mapping of cluster, connection from Dial route each command to node by key slot.
+// Commands in one pipeline (Send/Flush or MULTI/EXEC) must use keys in the same slot (use hash tag),
+// commands after MULTI are queued and sent with EXEC to node of the first command with key.
+// Keyless command is sent to one node (node of slot 0), command which require all nodes (KEYS, SCAN, etc) is rejected
+type redisCluster struct {
+	seeds []string
+	dial  func(addr string) (redis.Conn, error)
+	// readOnly route command to replica of slot (fallback to master) with READONLY connection
+	readOnly bool
+
+	mu       sync.RWMutex
+	slots    [redisClusterSlots]string
+	replicas [redisClusterSlots]string
+	// refreshing 1 if refresh slots from MOVED redirection is running
+	refreshing int32
+}
+
+func newRedisCluster(seeds []string, dial func(addr string) (redis.Conn, error), readOnly bool) (*redisCluster, error) {
+	c := &redisCluster{seeds: seeds, dial: dial, readOnly: readOnly}
+	if err := c.refreshSlots(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Dial create cluster connection, connection to node is created when used
+func (c *redisCluster) Dial() (redis.Conn, error) {
+	return &redisClusterConn{cluster: c, conns: make(map[string]redis.Conn)}, nil
+}
+
+// refreshSlots load slot mapping from first available node
+func (c *redisCluster) refreshSlots() error {
+	var lastErr error
+	for _, addr := range c.seeds {
+		conn, err := c.dial(addr)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		values, err := redis.Values(conn.Do("CLUSTER", "SLOTS"))
+		conn.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		host, _, _ := net.SplitHostPort(addr)
+		c.mu.Lock()
+		for _, value := range values {
+			slotRange, err := redis.Values(value, nil)
+			if err != nil || len(slotRange) < 3 {
+				continue
+			}
+			start, _ := redis.Int(slotRange[0], nil)
+			end, _ := redis.Int(slotRange[1], nil)
+			master, err := redis.Values(slotRange[2], nil)
+			if err != nil || len(master) < 2 {
+				continue
+			}
+			masterAddr := redisNodeAddr(master, host)
+			// first replica of slot, used for read only cluster
+			var replicaAddr string
+			if len(slotRange) > 3 {
+				if replica, err := redis.Values(slotRange[3], nil); err == nil && len(replica) >= 2 {
+					replicaAddr = redisNodeAddr(replica, host)
+				}
+			}
+			for slot := start; slot <= end && slot < redisClusterSlots; slot++ {
+				c.slots[slot], c.replicas[slot] = masterAddr, replicaAddr
+			}
+		}
+		c.mu.Unlock()
+		return nil
+	}
+	return fmt.Errorf("redis cluster: cannot load slots: %v", lastErr)
+}
+
+// refreshSlotsAsync refresh slots in background after MOVED redirection, only one refresh is running at a time
+func (c *redisCluster) refreshSlotsAsync() {
+	if !atomic.CompareAndSwapInt32(&c.refreshing, 0, 1) {
+		return
+	}
+	go func() {
+		defer atomic.StoreInt32(&c.refreshing, 0)
+		c.refreshSlots()
+	}()
+}
+
+// redisNodeAddr get address from node info in cluster slots reply ([host, port, id]), empty host is replaced with seed host
+func redisNodeAddr(node []interface{}, seedHost string) string {
+	host, _ := redis.String(node[0], nil)
+	port, _ := redis.Int(node[1], nil)
+	if host == "" {
+		host = seedHost
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// setSlot set master of slot from MOVED redirection, replica is unknown until slots refreshed
+func (c *redisCluster) setSlot(slot int, addr string) {
+	c.mu.Lock()
+	c.slots[slot], c.replicas[slot] = addr, ""
+	c.mu.Unlock()
+}
+
+// addrByKey get node address of key slot (replica if read only), node of slot 0 for keyless command
+func (c *redisCluster) addrByKey(key string, hasKey bool) string {
+	slot := 0
+	if hasKey {
+		slot = redisClusterSlot(key)
+	}
+	c.mu.RLock()
+	addr := c.slots[slot]
+	if c.readOnly && c.replicas[slot] != "" {
+		addr = c.replicas[slot]
+	}
+	c.mu.RUnlock()
+	if addr == "" {
+		addr = c.seeds[0]
+	}
+	return addr
+}
+
+type redisClusterCommand struct {
+	name string
+	args []interface{}
+}
+
+// redisClusterConn implement redis.Conn, hold connection for each used node
+type redisClusterConn struct {
+	cluster *redisCluster
+	conns   map[string]redis.Conn
+	current redis.Conn
+	pending []redisClusterCommand
+	err     error
+	// multi true after MULTI until EXEC or DISCARD, multiAt is index of MULTI in pending commands
+	multi   bool
+	multiAt int
+}
+
+func (c *redisClusterConn) nodeConn(addr string) (redis.Conn, error) {
+	if conn, ok := c.conns[addr]; ok {
+		return conn, nil
+	}
+	conn, err := c.cluster.dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	if c.cluster.readOnly {
+		// allow read from replica connection
+		if _, err := conn.Do("READONLY"); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	c.conns[addr] = conn
+	return conn, nil
+}
+
+// pipelineConn get node connection by the first command with key in commands
+func (c *redisClusterConn) pipelineConn(commands []redisClusterCommand) (redis.Conn, error) {
+	for _, cmd := range commands {
+		if key, ok := redisCommandKey(cmd.name, cmd.args); ok {
+			return c.nodeConn(c.cluster.addrByKey(key, true))
+		}
+	}
+	if c.current != nil {
+		return c.current, nil
+	}
+	return c.nodeConn(c.cluster.addrByKey("", false))
+}
+
+func (c *redisClusterConn) Close() error {
+	var err error
+	for _, conn := range c.conns {
+		if e := conn.Close(); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (c *redisClusterConn) Err() error {
+	if c.err != nil {
+		return c.err
+	}
+	for _, conn := range c.conns {
+		if err := conn.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *redisClusterConn) Do(commandName string, args ...interface{}) (reply interface{}, err error) {
+	if err := checkRedisClusterCommand(commandName); err != nil {
+		return nil, err
+	}
+	switch name := strings.ToUpper(commandName); {
+	case name == "MULTI":
+		// transaction is pinned to one node, commands are queued until EXEC
+		c.Send(commandName, args...)
+		return "OK", nil
+	case c.multi && (name == "DISCARD" || name == ""):
+		// transaction is not sent to node yet
+		c.pending, c.multi = c.pending[:c.multiAt], false
+		if name == "" {
+			break
+		}
+		return "OK", nil
+	case c.multi && name != "EXEC":
+		c.Send(commandName, args...)
+		return "QUEUED", nil
+	}
+
+	if len(c.pending) > 0 {
+		commands := c.pending
+		if commandName != "" {
+			commands = append(commands, redisClusterCommand{name: commandName, args: args})
+		}
+		c.pending, c.multi = nil, false
+		return c.doPipeline(commands)
+	}
+	if commandName == "" {
+		if c.current == nil {
+			return nil, nil
+		}
+		return c.current.Do("")
+	}
+
+	key, hasKey := redisCommandKey(commandName, args)
+	addr := c.cluster.addrByKey(key, hasKey)
+	if name := strings.ToUpper(commandName); c.current != nil && (name == "EXEC" || name == "DISCARD") {
+		// transaction already sent with Flush, finish in the same node
+		return c.current.Do(commandName, args...)
+	}
+	for attempt := 0; attempt < 5; attempt++ {
+		conn, err := c.nodeConn(addr)
+		if err != nil {
+			c.err = err
+			return nil, err
+		}
+		c.current = conn
+
+		reply, err = conn.Do(commandName, args...)
+		redirection := redisRedirection(err)
+		if redirection == nil {
+			return reply, err
+		}
+
+		addr = redirection[2]
+		if redirection[0] == "MOVED" {
+			c.moved(redirection)
+			continue
+		}
+		// ASK redirection, slot is migrating to target node
+		target, err := c.nodeConn(addr)
+		if err != nil {
+			c.err = err
+			return nil, err
+		}
+		target.Send("ASKING")
+		c.current = target
+		return target.Do(commandName, args...)
+	}
+	return nil, errors.New("redis cluster: too many redirections")
+}
+
+// doPipeline send commands in one round trip to node of the first command with key, return the last reply and the first error like redigo.
+// MOVED or ASK redirection is followed only if all commands with key are redirected (keys are in the same slot),
+// so that no command is executed twice. ASK redirection is not followed for transaction
+func (c *redisClusterConn) doPipeline(commands []redisClusterCommand) (reply interface{}, err error) {
+	conn, err := c.pipelineConn(commands)
+	if err != nil {
+		c.err = err
+		return nil, err
+	}
+
+	var asking bool
+	for attempt := 0; attempt < 5; attempt++ {
+		c.current = conn
+		for _, cmd := range commands {
+			if asking {
+				conn.Send("ASKING")
+			}
+			conn.Send(cmd.name, cmd.args...)
+		}
+		if err := conn.Flush(); err != nil {
+			return nil, err
+		}
+
+		var firstErr error
+		var redirection []string
+		var keyed, redirected int
+		var transaction bool
+		for _, cmd := range commands {
+			if asking {
+				if _, err := conn.Receive(); err != nil {
+					if _, ok := err.(redis.Error); !ok {
+						return nil, err
+					}
+				}
+			}
+			r, e := conn.Receive()
+			if _, ok := e.(redis.Error); !ok && e != nil {
+				return nil, e
+			}
+			if _, hasKey := redisCommandKey(cmd.name, cmd.args); hasKey {
+				keyed++
+				if fields := redisRedirection(e); fields != nil {
+					redirected, redirection = redirected+1, fields
+				}
+			}
+			transaction = transaction || strings.EqualFold(cmd.name, "MULTI")
+			if firstErr == nil {
+				firstErr = e
+			}
+			reply = r
+		}
+		if redirected == 0 || redirected < keyed || (redirection[0] == "ASK" && transaction) {
+			return reply, firstErr
+		}
+
+		asking = redirection[0] == "ASK"
+		if !asking {
+			c.moved(redirection)
+		}
+		if conn, err = c.nodeConn(redirection[2]); err != nil {
+			c.err = err
+			return nil, err
+		}
+	}
+	return nil, errors.New("redis cluster: too many redirections")
+}
+
+// moved update slot mapping from MOVED redirection
+func (c *redisClusterConn) moved(redirection []string) {
+	if slot, err := strconv.Atoi(redirection[1]); err == nil {
+		c.cluster.setSlot(slot, redirection[2])
+	}
+	c.cluster.refreshSlotsAsync()
+}
+
+func (c *redisClusterConn) Send(commandName string, args ...interface{}) error {
+	if err := checkRedisClusterCommand(commandName); err != nil {
+		return err
+	}
+	switch strings.ToUpper(commandName) {
+	case "MULTI":
+		c.multi, c.multiAt = true, len(c.pending)
+	case "EXEC", "DISCARD":
+		c.multi = false
+	}
+	c.pending = append(c.pending, redisClusterCommand{name: commandName, args: args})
+	return nil
+}
+
+func (c *redisClusterConn) Flush() error {
+	if len(c.pending) == 0 {
+		if c.current == nil {
+			return nil
+		}
+		return c.current.Flush()
+	}
+
+	conn, err := c.pipelineConn(c.pending)
+	if err != nil {
+		c.err = err
+		return err
+	}
+	for _, cmd := range c.pending {
+		if err := conn.Send(cmd.name, cmd.args...); err != nil {
+			return err
+		}
+	}
+	// transaction is sent, EXEC or DISCARD is sent to the same node
+	c.pending, c.current, c.multi = nil, conn, false
+	return conn.Flush()
+}
+
+func (c *redisClusterConn) Receive() (interface{}, error) {
+	if len(c.pending) > 0 {
+		if err := c.Flush(); err != nil {
+			return nil, err
+		}
+	}
+	if c.current == nil {
+		return nil, errors.New("redis cluster: no pending command")
+	}
+	return c.current.Receive()
+}
+
+// redisRedirection get fields of MOVED or ASK error ([MOVED|ASK, slot, addr]), nil if not redirection
+func redisRedirection(err error) []string {
+	redisErr, ok := err.(redis.Error)
+	if !ok {
+		return nil
+	}
+	fields := strings.Fields(redisErr.Error())
+	if len(fields) != 3 || (fields[0] != "MOVED" && fields[0] != "ASK") {
+		return nil
+	}
+	return fields
+}
+
+// checkRedisClusterCommand reject command which result is partial when sent to one node
+func checkRedisClusterCommand(commandName string) error {
+	switch strings.ToUpper(commandName) {
+	case "KEYS", "SCAN", "DBSIZE", "RANDOMKEY", "FLUSHDB", "FLUSHALL":
+		return fmt.Errorf("redis cluster: command %s is not supported, keys are distributed in multiple nodes", strings.ToUpper(commandName))
+	}
+	return nil
+}
+
+// redisCommandKey get first key of command, return false for keyless command
+func redisCommandKey(commandName string, args []interface{}) (string, bool) {
+	switch strings.ToUpper(commandName) {
+	case "", "PING", "ECHO", "INFO", "TIME", "ROLE", "AUTH", "SELECT", "ASKING", "READONLY", "CLUSTER", "CONFIG", "SCRIPT",
+		"MULTI", "EXEC", "DISCARD", "UNWATCH",
+		"PUBLISH", "SUBSCRIBE", "UNSUBSCRIBE", "PSUBSCRIBE", "PUNSUBSCRIBE":
+		return "", false
+
+	case "EVAL", "EVALSHA":
+		if len(args) < 3 {
+			return "", false
+		}
+		if numKeys, _ := strconv.Atoi(fmt.Sprint(args[1])); numKeys <= 0 {
+			return "", false
+		}
+		return redisArgString(args[2]), true
+
+	case "XREAD", "XREADGROUP":
+		for i, arg := range args {
+			if strings.EqualFold(redisArgString(arg), "STREAMS") && i+1 < len(args) {
+				return redisArgString(args[i+1]), true
+			}
+		}
+		return "", false
+	}
+
+	if len(args) == 0 {
+		return "", false
+	}
+	return redisArgString(args[0]), true
+}
+
+func redisArgString(arg interface{}) string {
+	switch v := arg.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+	return fmt.Sprint(arg)
+}
+
+// redisClusterSlot get hash slot of key, only hash tag (substring inside first {...}) is hashed if exist
+func redisClusterSlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key) % redisClusterSlots)
+}
+
+// crc16 CRC16-XMODEM used in redis cluster
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,242 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	mocks "github.com/golangid/candi/mocks/redis"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+// newFakeCluster create cluster with slot 0-8191 in :7000 (replica :7003) and slot 8192-16383 in :7001
+func newFakeCluster(t *testing.T, readOnly bool) (*redisCluster, map[string]*mocks.Conn) {
+	nodes := map[string]*mocks.Conn{
+		"127.0.0.1:7000": mocks.NewConn(nil), "127.0.0.1:7001": mocks.NewConn(nil), "127.0.0.1:7002": mocks.NewConn(nil), "127.0.0.1:7003": mocks.NewConn(nil),
+	}
+	nodes["127.0.0.1:7000"].SetReply(func(cmd string, args ...interface{}) (interface{}, error) {
+		if cmd == "CLUSTER" {
+			return []interface{}{
+				[]interface{}{int64(0), int64(8191), []interface{}{[]byte("127.0.0.1"), int64(7000), []byte("id0")}, []interface{}{[]byte("127.0.0.1"), int64(7003), []byte("id3")}},
+				[]interface{}{int64(8192), int64(16383), []interface{}{[]byte(""), int64(7001), []byte("id1")}},
+			}, nil
+		}
+		return "OK", nil
+	})
+
+	cluster, err := newRedisCluster([]string{"127.0.0.1:7000"}, func(addr string) (redis.Conn, error) {
+		node, ok := nodes[addr]
+		if !ok {
+			return nil, fmt.Errorf("dial %s: connection refused", addr)
+		}
+		return node, nil
+	}, readOnly)
+	assert.NoError(t, err)
+	return cluster, nodes
+}
+
+func TestRedisClusterSlot(t *testing.T) {
+	assert.Equal(t, uint16(0x31C3), crc16("123456789"))
+	assert.Equal(t, 12182, redisClusterSlot("foo"))
+	assert.Equal(t, 5061, redisClusterSlot("bar"))
+	assert.Equal(t, redisClusterSlot("{user1000}.following"), redisClusterSlot("{user1000}.followers"))
+	assert.Equal(t, redisClusterSlot("user1000"), redisClusterSlot("{user1000}:history"))
+	// empty or unclosed hash tag, whole key is hashed
+	assert.Equal(t, int(crc16("foo{}{bar}")%redisClusterSlots), redisClusterSlot("foo{}{bar}"))
+	assert.Equal(t, int(crc16("{bar")%redisClusterSlots), redisClusterSlot("{bar"))
+}
+
+func TestNewRedisClusterError(t *testing.T) {
+	_, err := newRedisCluster([]string{"127.0.0.1:7000"}, func(addr string) (redis.Conn, error) {
+		return nil, errors.New("connection refused")
+	}, false)
+	assert.EqualError(t, err, "redis cluster: cannot load slots: connection refused")
+}
+
+func TestRedisClusterRouting(t *testing.T) {
+	cluster, nodes := newFakeCluster(t, false)
+	conn, _ := cluster.Dial()
+	defer conn.Close()
+
+	_, err := conn.Do("GET", "foo")
+	assert.NoError(t, err)
+	_, err = conn.Do("GET", "bar")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"GET foo"}, nodes["127.0.0.1:7001"].CommandKeys(), "empty host in slots reply use seed host")
+	assert.Contains(t, nodes["127.0.0.1:7000"].CommandKeys(), "GET bar")
+
+	_, err = conn.Do("KEYS", "*")
+	assert.EqualError(t, err, "redis cluster: command KEYS is not supported, keys are distributed in multiple nodes")
+	assert.Error(t, conn.Send("SCAN", 0))
+}
+
+func TestRedisClusterRedirection(t *testing.T) {
+	cluster, nodes := newFakeCluster(t, false)
+	conn, _ := cluster.Dial()
+	defer conn.Close()
+
+	nodes["127.0.0.1:7001"].SetReply(func(cmd string, args ...interface{}) (interface{}, error) {
+		if redisArgString(args[0]) == "foo" {
+			return nil, redis.Error("MOVED 12182 127.0.0.1:7002")
+		}
+		return nil, redis.Error("ASK 12182 127.0.0.1:7002")
+	})
+	reply, err := conn.Do("GET", "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "OK", reply)
+	assert.Equal(t, []string{"GET foo"}, nodes["127.0.0.1:7002"].CommandKeys())
+
+	// slot is migrating, command is sent to target node with ASKING
+	cluster.setSlot(redisClusterSlot("{foo}:ask"), "127.0.0.1:7001")
+	reply, err = conn.Do("GET", "{foo}:ask")
+	assert.NoError(t, err)
+	assert.Equal(t, "OK", reply)
+	assert.Equal(t, []string{"GET foo", "ASKING", "GET {foo}:ask"}, nodes["127.0.0.1:7002"].CommandKeys())
+	assert.Equal(t, "127.0.0.1:7001", cluster.addrByKey("{foo}:ask", true), "slot mapping is not changed by ASK")
+}
+
+func TestRedisClusterPipeline(t *testing.T) {
+	cluster, nodes := newFakeCluster(t, false)
+	conn, _ := cluster.Dial()
+	defer conn.Close()
+
+	// commands in pipeline is routed by the first command with key
+	conn.Send("MULTI")
+	conn.Send("SET", "{foo}:a", "1")
+	conn.Send("SET", "{foo}:b", "2")
+	_, err := conn.Do("EXEC")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"MULTI", "SET {foo}:a", "SET {foo}:b", "EXEC"}, nodes["127.0.0.1:7001"].CommandKeys())
+
+	conn.Send("GET", "bar")
+	conn.Send("GET", "{bar}:2")
+	assert.NoError(t, conn.Flush())
+	for i := 0; i < 2; i++ {
+		_, err := conn.Receive()
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, []string{"CLUSTER SLOTS", "GET bar", "GET {bar}:2"}, nodes["127.0.0.1:7000"].CommandKeys())
+}
+
+func TestRedisClusterReadOnly(t *testing.T) {
+	cluster, nodes := newFakeCluster(t, true)
+	conn, _ := cluster.Dial()
+	defer conn.Close()
+
+	_, err := conn.Do("GET", "bar")
+	assert.NoError(t, err)
+	_, err = conn.Do("GET", "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"READONLY", "GET bar"}, nodes["127.0.0.1:7003"].CommandKeys())
+	assert.Equal(t, []string{"READONLY", "GET foo"}, nodes["127.0.0.1:7001"].CommandKeys(), "fallback to master if slot has no replica")
+	assert.False(t, strings.Contains(strings.Join(nodes["127.0.0.1:7000"].CommandKeys(), ","), "GET"))
+}
+
+func TestRedisClusterTransaction(t *testing.T) {
+	cluster, nodes := newFakeCluster(t, false)
+	conn, _ := cluster.Dial()
+	defer conn.Close()
+
+	// commands after MULTI are queued and sent with EXEC to node of the first command with key
+	reply, err := conn.Do("MULTI")
+	assert.NoError(t, err)
+	assert.Equal(t, "OK", reply)
+	reply, err = conn.Do("SET", "{foo}:a", "1")
+	assert.NoError(t, err)
+	assert.Equal(t, "QUEUED", reply)
+	conn.Do("SET", "{foo}:b", "2")
+	_, err = conn.Do("EXEC")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"MULTI", "SET {foo}:a", "SET {foo}:b", "EXEC"}, nodes["127.0.0.1:7001"].CommandKeys())
+
+	conn.Do("MULTI")
+	conn.Do("SET", "bar", "1")
+	reply, err = conn.Do("DISCARD")
+	assert.NoError(t, err)
+	assert.Equal(t, "OK", reply)
+	assert.Equal(t, []string{"CLUSTER SLOTS"}, nodes["127.0.0.1:7000"].CommandKeys(), "discarded transaction is not sent")
+
+	// transaction is sent with Flush, EXEC is sent to the same node
+	conn.Send("MULTI")
+	conn.Send("SET", "{foo}:c", "3")
+	assert.NoError(t, conn.Flush())
+	_, err = conn.Do("EXEC")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"MULTI", "SET {foo}:c", "EXEC"}, nodes["127.0.0.1:7001"].CommandKeys()[4:])
+}
+
+func TestRedisClusterPipelineRedirection(t *testing.T) {
+	cluster, nodes := newFakeCluster(t, false)
+	conn, _ := cluster.Dial()
+	defer conn.Close()
+
+	nodes["127.0.0.1:7001"].SetReply(func(cmd string, args ...interface{}) (interface{}, error) {
+		switch {
+		case cmd == "EXEC":
+			return nil, redis.Error("EXECABORT Transaction discarded because of previous errors.")
+		case len(args) == 0:
+			return "OK", nil
+		case strings.HasPrefix(redisArgString(args[0]), "{foo}"):
+			return nil, redis.Error("MOVED 12182 127.0.0.1:7002")
+		case redisArgString(args[0]) == "{bar}:partial":
+			return "OK", nil
+		}
+		return nil, redis.Error("ASK 5061 127.0.0.1:7002")
+	})
+
+	// transaction is rejected in old node (MOVED when queued), sent again to new node
+	conn.Send("MULTI")
+	conn.Send("SET", "{foo}:a", "1")
+	reply, err := conn.Do("EXEC")
+	assert.NoError(t, err)
+	assert.Equal(t, "OK", reply)
+	assert.Equal(t, []string{"MULTI", "SET {foo}:a", "EXEC"}, nodes["127.0.0.1:7002"].CommandKeys())
+	assert.Equal(t, "127.0.0.1:7002", cluster.addrByKey("{foo}:a", true))
+
+	// migrating slot, pipeline is sent to target node with ASKING before each command
+	cluster.setSlot(redisClusterSlot("{bar}:a"), "127.0.0.1:7001")
+	conn.Send("GET", "{bar}:a")
+	_, err = conn.Do("GET", "{bar}:b")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ASKING", "GET {bar}:a", "ASKING", "GET {bar}:b"}, nodes["127.0.0.1:7002"].CommandKeys()[3:])
+
+	// ASK is not followed for transaction or if some command is already executed in old node
+	conn.Send("MULTI")
+	conn.Send("SET", "{bar}:a", "1")
+	_, err = conn.Do("EXEC")
+	assert.EqualError(t, err, "ASK 5061 127.0.0.1:7002")
+	conn.Send("SET", "{bar}:partial", "1")
+	_, err = conn.Do("SET", "{bar}:a", "1")
+	assert.EqualError(t, err, "ASK 5061 127.0.0.1:7002")
+	assert.Len(t, nodes["127.0.0.1:7002"].CommandKeys(), 7)
+}
+
+func TestRedisClusterRefreshSlotsCoalesced(t *testing.T) {
+	cluster, nodes := newFakeCluster(t, false)
+	countRefresh := func() (count int) {
+		for _, cmd := range nodes["127.0.0.1:7000"].Names() {
+			if cmd == "CLUSTER" {
+				count++
+			}
+		}
+		return count
+	}
+
+	atomic.StoreInt32(&cluster.refreshing, 1)
+	cluster.refreshSlotsAsync()
+	assert.Equal(t, 1, countRefresh(), "refresh is already running")
+
+	atomic.StoreInt32(&cluster.refreshing, 0)
+	cluster.refreshSlotsAsync()
+	assert.Eventually(t, func() bool { return countRefresh() == 2 && atomic.LoadInt32(&cluster.refreshing) == 0 }, time.Second, time.Millisecond)
+}
+
+func TestNewRedisDialerEmptyDSN(t *testing.T) {
+	_, err := newRedisDialer("", false)("127.0.0.1:1")
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "invalid redis URL", "address without dsn is dialed with redis scheme")
+}
@@ -0,0 +1,107 @@
+package database
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// redisSentinel discover master and replica address from sentinel
+type redisSentinel struct {
+	mu         sync.Mutex
+	addrs      []string
+	masterName string
+	password   string
+}
+
+func newRedisSentinel(addrs []string, masterName, password string) *redisSentinel {
+	return &redisSentinel{addrs: addrs, masterName: masterName, password: password}
+}
+
+// do execute fn in first available sentinel, available sentinel moved to first order for next call
+func (s *redisSentinel) do(fn func(conn redis.Conn) error) error {
+	s.mu.Lock()
+	addrs := append([]string{}, s.addrs...)
+	s.mu.Unlock()
+
+	var lastErr error
+	for i, addr := range addrs {
+		conn, err := redis.Dial("tcp", addr,
+			redis.DialConnectTimeout(time.Second), redis.DialReadTimeout(time.Second), redis.DialWriteTimeout(time.Second),
+			redis.DialPassword(s.password))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		err = fn(conn)
+		conn.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if i > 0 {
+			s.mu.Lock()
+			s.addrs = append([]string{addr}, append(addrs[:i:i], addrs[i+1:]...)...)
+			s.mu.Unlock()
+		}
+		return nil
+	}
+	return fmt.Errorf("redis sentinel: cannot get address of %s from all sentinels: %v", s.masterName, lastErr)
+}
+
+func (s *redisSentinel) masterAddr() (addr string, err error) {
+	err = s.do(func(conn redis.Conn) error {
+		res, err := redis.Strings(conn.Do("SENTINEL", "get-master-addr-by-name", s.masterName))
+		if err != nil {
+			return err
+		}
+		if len(res) != 2 {
+			return fmt.Errorf("master %s not found", s.masterName)
+		}
+		addr = net.JoinHostPort(res[0], res[1])
+		return nil
+	})
+	return
+}
+
+// replicaAddr get random healthy replica address, fallback to master address if no healthy replica
+func (s *redisSentinel) replicaAddr() (string, error) {
+	var addrs []string
+	err := s.do(func(conn redis.Conn) error {
+		replicas, err := redis.Values(conn.Do("SENTINEL", "slaves", s.masterName))
+		if err != nil {
+			return err
+		}
+		for _, replica := range replicas {
+			info, err := redis.StringMap(replica, nil)
+			if err != nil {
+				return err
+			}
+			if isRedisNodeDown(info["flags"]) || info["master-link-status"] != "ok" {
+				continue
+			}
+			addrs = append(addrs, net.JoinHostPort(info["ip"], info["port"]))
+		}
+		return nil
+	})
+	if err != nil || len(addrs) == 0 {
+		return s.masterAddr()
+	}
+	return addrs[rand.Intn(len(addrs))], nil
+}
+
+func isRedisNodeDown(flags string) bool {
+	for _, flag := range strings.Split(flags, ",") {
+		switch flag {
+		case "s_down", "o_down", "disconnected":
+			return true
+		}
+	}
+	return false
+}
@@ -42,6 +42,8 @@ type Env struct {
 	UsePostgresListenerWorker bool
 	// UseRabbitMQWorker env
 	UseRabbitMQWorker bool
+	// UseRedisStreamWorker env
+	UseRedisStreamWorker bool
 
 	IsProduction, DebugMode bool
 
@@ -54,12 +56,25 @@ type Env struct {
 	// TaskQueueDashboardMaxClientSubscribers Config
 	TaskQueueDashboardMaxClientSubscribers int
 
+	// CronSchedulerTimezone default IANA timezone for computing cron job activation time, empty for local timezone
+	CronSchedulerTimezone string
+	// CronSchedulerHistoryStore store for cron job run history, "memory" or "redis", history is not recorded if empty
+	CronSchedulerHistoryStore string
+	// CronSchedulerCatchUpStore store for last success activation time of cron job with catch up mode, "redis" or "postgres"
+	CronSchedulerCatchUpStore string
+	// CronSchedulerAdminPort port for cron scheduler admin api, admin api is not served in separate port if empty
+	CronSchedulerAdminPort uint16
+
 	// UseConsul for distributed lock if run in multiple instance
 	UseConsul bool
 	// ConsulAgentHost consul agent host
 	ConsulAgentHost string
 	// ConsulMaxJobRebalance env, if worker execute total job in env config, rebalance worker to another active intance
+	// (used by all distributed lock backend)
 	ConsulMaxJobRebalance int
+	// DistributedLockBackend for distributed lock of worker if run in multiple instance, "consul", "redis", or "postgres".
+	// Default "consul" if USE_CONSUL is true
+	DistributedLockBackend string
 
 	// BasicAuthUsername config
 	BasicAuthUsername string
@@ -76,12 +91,93 @@ type Env struct {
 		ClientVersion string
 		ClientID      string
 		ConsumerGroup string
+		// RetryDelays delay of each retry attempt for failed message (republished to "{topic}.retry.{attempt}"),
+		// total retry attempt is length of delays
+		RetryDelays []time.Duration
+		// DeadLetter publish failed message to "{topic}.dlq" after all retry attempts are failed
+		DeadLetter bool
+		// AtLeastOnce offset is marked only after handler success or failed message is republished,
+		// auto commit is disabled and marked offsets are committed every CommitInterval or CommitBatchSize messages
+		AtLeastOnce     bool
+		CommitInterval  time.Duration
+		CommitBatchSize int
+		// MaxAttempts in at-least-once mode, failed message is processed again with backoff until MaxAttempts times
+		// before published to retry or dead letter topic (require DeadLetter), zero for republish on first failure
+		MaxAttempts int
+		// Concurrency total worker goroutines for processing messages of each partition concurrently,
+		// messages with the same key are processed in order
+		Concurrency int
+
+		// PublisherMode "sync" (default) or "async" (batched, delivery error is passed to error handler)
+		PublisherMode string
+		// ProducerCompression none, gzip, snappy, lz4, or zstd
+		ProducerCompression   string
+		ProducerIdempotent    bool
+		ProducerFlushInterval time.Duration
+		ProducerFlushMessages int
+
+		// SASLMechanism PLAIN, SCRAM-SHA-256, or SCRAM-SHA-512, empty for no SASL authentication
+		SASLMechanism string
+		SASLUsername  string
+		SASLPassword  string
+
+		TLSEnabled    bool
+		TLSCAFile     string
+		TLSCertFile   string
+		TLSKeyFile    string
+		TLSSkipVerify bool
+
+		// ConsumerInitialOffset "oldest" (default) or "newest", used if consumer group has no committed offset
+		ConsumerInitialOffset string
+		// ConsumerRebalanceStrategy "roundrobin" (default), "range", or "sticky"
+		ConsumerRebalanceStrategy string
+		ConsumerSessionTimeout    time.Duration
+		ConsumerHeartbeatInterval time.Duration
+		ConsumerRebalanceTimeout  time.Duration
+		// MaxConsumerLag health check is failed if total lag of consumer group exceed this value, zero for not check lag
+		MaxConsumerLag int64
+		// ConsumerLagCacheTTL consumer lag is fetched from broker at most once in this duration, default 10 seconds
+		ConsumerLagCacheTTL time.Duration
+		// TopicRefreshInterval interval of refresh metadata for discover new topics matching handler pattern
+		TopicRefreshInterval time.Duration
 	}
 	RabbitMQ struct {
 		Broker        string
 		ConsumerGroup string
 		ExchangeName  string
 		AutoACK       bool
+		// MaxRetry failed message is republished to queue with x-retry-count header up to MaxRetry times (only when AutoACK is false)
+		MaxRetry int
+		// RetryDelay delay of retry message using delayed exchange, zero for retry immediately
+		RetryDelay time.Duration
+		// DeadLetter declare dead letter exchange and "{queue}.dlq" queue, message is rejected to dead letter queue after MaxRetry
+		DeadLetter bool
+	}
+	RedisWorker struct {
+		// QueueMode "keyspace" (default, using redis key expired notification) or "sorted_set" (reliable delayed queue)
+		QueueMode string
+		// QueueKey key prefix of sorted set delayed queue, default is "{service name}:redis_worker"
+		QueueKey string
+		// PollInterval interval for claim due messages in sorted set delayed queue
+		PollInterval time.Duration
+		// VisibilityTimeout claimed message which not acknowledged in duration will be recovered to delayed queue
+		VisibilityTimeout time.Duration
+		// MaxRetry failed message in delayed queue is retried up to MaxRetry times, then dropped
+		MaxRetry int
+		// RetryDelay delay of failed message before claimed again
+		RetryDelay time.Duration
+	}
+	RedisStream struct {
+		// ConsumerGroup consumer group name, default is service name
+		ConsumerGroup string
+		// ConsumerName unique consumer name in group, default is hostname
+		ConsumerName string
+		// BatchSize maximum messages read from stream in one call
+		BatchSize int
+		// ClaimMinIdle minimum idle time of pending message (not acknowledged) before claimed by another consumer
+		ClaimMinIdle time.Duration
+		// MaxDelivery pending message which delivered more than MaxDelivery is moved to dead letter stream "{stream}:dlq"
+		MaxDelivery int
 	}
 
 	// MaxGoroutines env for goroutine semaphore
@@ -91,6 +187,26 @@ type Env struct {
 	DbMongoWriteHost, DbMongoReadHost, DbMongoDatabaseName string
 	DbSQLWriteDSN, DbSQLReadDSN                            string
 	DbRedisReadDSN, DbRedisWriteDSN                        string
+
+	// Redis connection environment
+	Redis struct {
+		// Mode "standalone" (default), "sentinel", or "cluster"
+		Mode string
+		// SentinelAddrs sentinel addresses for discover master and replica (read pool), dsn host is ignored in sentinel mode
+		SentinelAddrs []string
+		// SentinelMasterName master name monitored by sentinel
+		SentinelMasterName string
+		// SentinelPassword password for connect to sentinel
+		SentinelPassword string
+		// ClusterAddrs seed node addresses for discover cluster slots, dsn host is ignored in cluster mode
+		ClusterAddrs []string
+		// ReadTLS, WriteTLS use TLS connection (also enabled with "rediss://" dsn scheme)
+		ReadTLS, WriteTLS, TLSSkipVerify bool
+		// MaxIdle, MaxActive pool size, zero MaxActive for unlimited connection
+		MaxIdle, MaxActive int
+		// IdleTimeout, MaxConnLifetime close connection after idle or age duration
+		IdleTimeout, MaxConnLifetime time.Duration
+	}
 }
 
 var env Env
@@ -160,12 +276,44 @@ func Load(serviceName string) {
 		}
 	}
 
+	env.CronSchedulerTimezone = os.Getenv("CRON_SCHEDULER_TIMEZONE")
+	if env.CronSchedulerTimezone != "" {
+		if _, err := time.LoadLocation(env.CronSchedulerTimezone); err != nil {
+			panic(fmt.Errorf("invalid CRON_SCHEDULER_TIMEZONE environment: %v", err))
+		}
+	}
+
+	env.CronSchedulerHistoryStore = os.Getenv("CRON_SCHEDULER_HISTORY_STORE")
+	if !candihelper.StringInSlice(env.CronSchedulerHistoryStore, []string{"", "memory", "redis"}) {
+		panic(`CRON_SCHEDULER_HISTORY_STORE environment must one of "memory" or "redis"`)
+	}
+	env.CronSchedulerCatchUpStore = os.Getenv("CRON_SCHEDULER_CATCH_UP_STORE")
+	if !candihelper.StringInSlice(env.CronSchedulerCatchUpStore, []string{"", "redis", "postgres"}) {
+		panic(`CRON_SCHEDULER_CATCH_UP_STORE environment must one of "redis" or "postgres"`)
+	}
+	if adminPort, ok := os.LookupEnv("CRON_SCHEDULER_ADMIN_PORT"); ok && env.UseCronScheduler {
+		port, err := strconv.Atoi(adminPort)
+		if err != nil {
+			panic("CRON_SCHEDULER_ADMIN_PORT environment must in integer format")
+		}
+		env.CronSchedulerAdminPort = uint16(port)
+	}
+
 	env.UseConsul = parseBool("USE_CONSUL")
-	if env.UseConsul {
+	env.DistributedLockBackend = os.Getenv("DISTRIBUTED_LOCK_BACKEND")
+	if !candihelper.StringInSlice(env.DistributedLockBackend, []string{"", "consul", "redis", "postgres"}) {
+		panic(`DISTRIBUTED_LOCK_BACKEND environment must one of "consul", "redis", or "postgres"`)
+	}
+	if env.DistributedLockBackend == "" && env.UseConsul {
+		env.DistributedLockBackend = "consul"
+	}
+	if env.DistributedLockBackend == "consul" {
 		env.ConsulAgentHost, ok = os.LookupEnv("CONSUL_AGENT_HOST")
 		if !ok {
 			panic("consul is active, missing CONSUL_AGENT_HOST environment")
 		}
+	}
+	if env.DistributedLockBackend != "" {
 		env.ConsulMaxJobRebalance = 10
 		if count, err := strconv.Atoi(os.Getenv("CONSUL_MAX_JOB_REBALANCE")); err == nil {
 			env.ConsulMaxJobRebalance = count
@@ -278,6 +426,12 @@ func parseAppConfig() {
 	} else {
 		env.UseRabbitMQWorker, _ = strconv.ParseBool(useRabbitMQWorker)
 	}
+	useRedisStreamWorker, ok := os.LookupEnv("USE_REDIS_STREAM_WORKER")
+	if !ok {
+		flag.BoolVar(&env.UseRedisStreamWorker, "USE_REDIS_STREAM_WORKER", false, "USE REDIS STREAM WORKER")
+	} else {
+		env.UseRedisStreamWorker, _ = strconv.ParseBool(useRedisStreamWorker)
+	}
 
 	flag.Usage = func() {
 		fmt.Println("	-USE_REST :=> Activate REST Server")
@@ -289,6 +443,7 @@ func parseAppConfig() {
 		fmt.Println("	-USE_TASK_QUEUE_WORKER :=> Activate Task Queue Worker")
 		fmt.Println("	-USE_POSTGRES_LISTENER_WORKER :=> Activate Postgres Event Worker")
 		fmt.Println("	-USE_RABBITMQ_CONSUMER :=> Activate Rabbit MQ Consumer")
+		fmt.Println("	-USE_REDIS_STREAM_WORKER :=> Activate Redis Stream Consumer Worker")
 	}
 	flag.Parse()
 }
@@ -309,6 +464,109 @@ func parseBrokerEnv() {
 			panic("kafka consumer is active, missing KAFKA_CONSUMER_GROUP environment")
 		}
 	}
+	for _, delay := range splitNonEmpty(os.Getenv("KAFKA_RETRY_DELAYS")) {
+		d, err := time.ParseDuration(delay)
+		if err != nil {
+			panic(fmt.Errorf("invalid KAFKA_RETRY_DELAYS environment: %v", err))
+		}
+		env.Kafka.RetryDelays = append(env.Kafka.RetryDelays, d)
+	}
+	env.Kafka.DeadLetter = parseBool("KAFKA_DEAD_LETTER")
+	env.Kafka.AtLeastOnce = parseBool("KAFKA_AT_LEAST_ONCE")
+	if commitInterval, ok := os.LookupEnv("KAFKA_COMMIT_INTERVAL"); ok {
+		var err error
+		if env.Kafka.CommitInterval, err = time.ParseDuration(commitInterval); err != nil {
+			panic(fmt.Errorf("invalid KAFKA_COMMIT_INTERVAL environment: %v", err))
+		}
+	} else {
+		env.Kafka.CommitInterval = time.Second
+	}
+	env.Kafka.CommitBatchSize = parseNonNegativeInt("KAFKA_COMMIT_BATCH_SIZE")
+	if env.Kafka.MaxAttempts = parseNonNegativeInt("KAFKA_MAX_ATTEMPTS"); env.Kafka.MaxAttempts > 0 && !env.Kafka.DeadLetter {
+		panic("KAFKA_MAX_ATTEMPTS environment require KAFKA_DEAD_LETTER=true, failed message is published to dead letter topic after max attempts")
+	}
+	if env.Kafka.Concurrency = parseNonNegativeInt("KAFKA_CONSUMER_CONCURRENCY"); env.Kafka.Concurrency == 0 {
+		env.Kafka.Concurrency = 1
+	}
+
+	env.Kafka.PublisherMode = os.Getenv("KAFKA_PUBLISHER_MODE")
+	switch env.Kafka.PublisherMode {
+	case "":
+		env.Kafka.PublisherMode = "sync"
+	case "sync", "async":
+	default:
+		panic(`KAFKA_PUBLISHER_MODE environment must one of "sync" or "async"`)
+	}
+	env.Kafka.ProducerCompression = os.Getenv("KAFKA_PRODUCER_COMPRESSION")
+	switch env.Kafka.ProducerCompression {
+	case "", "none", "gzip", "snappy", "lz4", "zstd":
+	default:
+		panic(`KAFKA_PRODUCER_COMPRESSION environment must one of "none", "gzip", "snappy", "lz4", or "zstd"`)
+	}
+	env.Kafka.ProducerIdempotent = parseBool("KAFKA_PRODUCER_IDEMPOTENT")
+	if flushInterval, ok := os.LookupEnv("KAFKA_PRODUCER_FLUSH_INTERVAL"); ok {
+		var err error
+		if env.Kafka.ProducerFlushInterval, err = time.ParseDuration(flushInterval); err != nil {
+			panic(fmt.Errorf("invalid KAFKA_PRODUCER_FLUSH_INTERVAL environment: %v", err))
+		}
+	}
+	env.Kafka.ProducerFlushMessages = parseNonNegativeInt("KAFKA_PRODUCER_FLUSH_MESSAGES")
+
+	env.Kafka.SASLMechanism = os.Getenv("KAFKA_SASL_MECHANISM")
+	env.Kafka.SASLUsername = os.Getenv("KAFKA_SASL_USERNAME")
+	env.Kafka.SASLPassword = os.Getenv("KAFKA_SASL_PASSWORD")
+	switch env.Kafka.SASLMechanism {
+	case "":
+	case "PLAIN", "SCRAM-SHA-256", "SCRAM-SHA-512":
+		if env.Kafka.SASLUsername == "" || env.Kafka.SASLPassword == "" {
+			panic("kafka sasl is active, missing KAFKA_SASL_USERNAME or KAFKA_SASL_PASSWORD environment")
+		}
+	default:
+		panic(`KAFKA_SASL_MECHANISM environment must one of "PLAIN", "SCRAM-SHA-256", or "SCRAM-SHA-512"`)
+	}
+
+	env.Kafka.TLSEnabled = parseBool("KAFKA_TLS_ENABLED")
+	env.Kafka.TLSCAFile = os.Getenv("KAFKA_TLS_CA_FILE")
+	env.Kafka.TLSCertFile = os.Getenv("KAFKA_TLS_CERT_FILE")
+	env.Kafka.TLSKeyFile = os.Getenv("KAFKA_TLS_KEY_FILE")
+	env.Kafka.TLSSkipVerify = parseBool("KAFKA_TLS_SKIP_VERIFY")
+	if (env.Kafka.TLSCertFile == "") != (env.Kafka.TLSKeyFile == "") {
+		panic("kafka tls client certificate, KAFKA_TLS_CERT_FILE and KAFKA_TLS_KEY_FILE environment must be set together")
+	}
+	if !env.Kafka.TLSEnabled && (env.Kafka.TLSCAFile != "" || env.Kafka.TLSCertFile != "" || env.Kafka.TLSSkipVerify) {
+		panic("kafka tls file or skip verify is set, but KAFKA_TLS_ENABLED environment is not true")
+	}
+
+	env.Kafka.ConsumerInitialOffset = os.Getenv("KAFKA_CONSUMER_INITIAL_OFFSET")
+	switch env.Kafka.ConsumerInitialOffset {
+	case "":
+		env.Kafka.ConsumerInitialOffset = "oldest"
+	case "oldest", "newest":
+	default:
+		panic(`KAFKA_CONSUMER_INITIAL_OFFSET environment must one of "oldest" or "newest"`)
+	}
+	env.Kafka.ConsumerRebalanceStrategy = os.Getenv("KAFKA_CONSUMER_REBALANCE_STRATEGY")
+	switch env.Kafka.ConsumerRebalanceStrategy {
+	case "":
+		env.Kafka.ConsumerRebalanceStrategy = "roundrobin"
+	case "roundrobin", "range", "sticky":
+	default:
+		panic(`KAFKA_CONSUMER_REBALANCE_STRATEGY environment must one of "roundrobin", "range", or "sticky"`)
+	}
+	env.Kafka.ConsumerSessionTimeout = parseDuration("KAFKA_CONSUMER_SESSION_TIMEOUT")
+	env.Kafka.ConsumerHeartbeatInterval = parseDuration("KAFKA_CONSUMER_HEARTBEAT_INTERVAL")
+	env.Kafka.ConsumerRebalanceTimeout = parseDuration("KAFKA_CONSUMER_REBALANCE_TIMEOUT")
+	env.Kafka.MaxConsumerLag, _ = strconv.ParseInt(os.Getenv("KAFKA_MAX_CONSUMER_LAG"), 10, 64)
+	if env.Kafka.ConsumerLagCacheTTL = parseDuration("KAFKA_CONSUMER_LAG_CACHE_TTL"); env.Kafka.ConsumerLagCacheTTL <= 0 {
+		env.Kafka.ConsumerLagCacheTTL = 10 * time.Second
+	}
+	if env.Kafka.ConsumerSessionTimeout > 0 && env.Kafka.ConsumerHeartbeatInterval > 0 &&
+		env.Kafka.ConsumerHeartbeatInterval >= env.Kafka.ConsumerSessionTimeout {
+		panic("KAFKA_CONSUMER_HEARTBEAT_INTERVAL environment must be lower than KAFKA_CONSUMER_SESSION_TIMEOUT")
+	}
+	if env.Kafka.TopicRefreshInterval = parseDuration("KAFKA_TOPIC_REFRESH_INTERVAL"); env.Kafka.TopicRefreshInterval <= 0 {
+		env.Kafka.TopicRefreshInterval = time.Minute
+	}
 	env.RabbitMQ.Broker = os.Getenv("RABBITMQ_BROKER")
 	env.RabbitMQ.ConsumerGroup = os.Getenv("RABBITMQ_CONSUMER_GROUP")
 	env.RabbitMQ.ExchangeName = os.Getenv("RABBITMQ_EXCHANGE_NAME")
@@ -317,6 +575,69 @@ func parseBrokerEnv() {
 	} else {
 		env.RabbitMQ.AutoACK = autoACK
 	}
+	env.RabbitMQ.MaxRetry = 3
+	if maxRetry := os.Getenv("RABBITMQ_MAX_RETRY"); maxRetry != "" {
+		var err error
+		if env.RabbitMQ.MaxRetry, err = strconv.Atoi(maxRetry); err != nil || env.RabbitMQ.MaxRetry < 0 {
+			panic("RABBITMQ_MAX_RETRY environment must be non negative integer")
+		}
+	}
+	env.RabbitMQ.RetryDelay = parseDuration("RABBITMQ_RETRY_DELAY")
+	env.RabbitMQ.DeadLetter = parseBool("RABBITMQ_DEAD_LETTER")
+
+	env.RedisWorker.QueueMode = os.Getenv("REDIS_WORKER_QUEUE_MODE")
+	switch env.RedisWorker.QueueMode {
+	case "":
+		env.RedisWorker.QueueMode = "keyspace"
+	case "keyspace", "sorted_set":
+	default:
+		panic(`REDIS_WORKER_QUEUE_MODE environment must one of "keyspace" or "sorted_set"`)
+	}
+	env.RedisWorker.QueueKey = os.Getenv("REDIS_WORKER_QUEUE_KEY")
+	if env.RedisWorker.QueueKey == "" {
+		env.RedisWorker.QueueKey = env.ServiceName + ":redis_worker"
+	}
+	if env.RedisWorker.PollInterval = parseDuration("REDIS_WORKER_POLL_INTERVAL"); env.RedisWorker.PollInterval <= 0 {
+		env.RedisWorker.PollInterval = time.Second
+	}
+	if env.RedisWorker.VisibilityTimeout = parseDuration("REDIS_WORKER_VISIBILITY_TIMEOUT"); env.RedisWorker.VisibilityTimeout <= 0 {
+		env.RedisWorker.VisibilityTimeout = 5 * time.Minute
+	}
+	env.RedisWorker.MaxRetry = 3
+	if maxRetry := os.Getenv("REDIS_WORKER_MAX_RETRY"); maxRetry != "" {
+		var err error
+		if env.RedisWorker.MaxRetry, err = strconv.Atoi(maxRetry); err != nil || env.RedisWorker.MaxRetry < 0 {
+			panic("REDIS_WORKER_MAX_RETRY environment must be non negative integer")
+		}
+	}
+	env.RedisWorker.RetryDelay = parseDuration("REDIS_WORKER_RETRY_DELAY")
+
+	env.RedisStream.ConsumerGroup = os.Getenv("REDIS_STREAM_CONSUMER_GROUP")
+	if env.RedisStream.ConsumerGroup == "" {
+		env.RedisStream.ConsumerGroup = env.ServiceName
+	}
+	env.RedisStream.ConsumerName = os.Getenv("REDIS_STREAM_CONSUMER_NAME")
+	if env.RedisStream.ConsumerName == "" {
+		env.RedisStream.ConsumerName, _ = os.Hostname()
+	}
+	if env.RedisStream.BatchSize, _ = strconv.Atoi(os.Getenv("REDIS_STREAM_BATCH_SIZE")); env.RedisStream.BatchSize <= 0 {
+		env.RedisStream.BatchSize = 10
+	}
+	if claimMinIdle, ok := os.LookupEnv("REDIS_STREAM_CLAIM_MIN_IDLE"); ok {
+		var err error
+		if env.RedisStream.ClaimMinIdle, err = time.ParseDuration(claimMinIdle); err != nil {
+			panic(fmt.Errorf("invalid REDIS_STREAM_CLAIM_MIN_IDLE environment: %v", err))
+		}
+	} else {
+		env.RedisStream.ClaimMinIdle = time.Minute
+	}
+	env.RedisStream.MaxDelivery = 5
+	if maxDelivery := os.Getenv("REDIS_STREAM_MAX_DELIVERY"); maxDelivery != "" {
+		var err error
+		if env.RedisStream.MaxDelivery, err = strconv.Atoi(maxDelivery); err != nil || env.RedisStream.MaxDelivery <= 0 {
+			panic("REDIS_STREAM_MAX_DELIVERY environment must be positive integer")
+		}
+	}
 }
 
 func parseDatabaseEnv() {
@@ -329,6 +650,75 @@ func parseDatabaseEnv() {
 
 	env.DbRedisReadDSN = os.Getenv("REDIS_READ_DSN")
 	env.DbRedisWriteDSN = os.Getenv("REDIS_WRITE_DSN")
+
+	env.Redis.Mode = os.Getenv("REDIS_MODE")
+	switch env.Redis.Mode {
+	case "":
+		env.Redis.Mode = "standalone"
+	case "standalone":
+	case "sentinel":
+		env.Redis.SentinelAddrs = splitNonEmpty(os.Getenv("REDIS_SENTINEL_ADDRS"))
+		env.Redis.SentinelMasterName = os.Getenv("REDIS_SENTINEL_MASTER_NAME")
+		env.Redis.SentinelPassword = os.Getenv("REDIS_SENTINEL_PASSWORD")
+		if len(env.Redis.SentinelAddrs) == 0 || env.Redis.SentinelMasterName == "" {
+			panic("redis sentinel mode, missing REDIS_SENTINEL_ADDRS or REDIS_SENTINEL_MASTER_NAME environment")
+		}
+	case "cluster":
+		env.Redis.ClusterAddrs = splitNonEmpty(os.Getenv("REDIS_CLUSTER_ADDRS"))
+		if len(env.Redis.ClusterAddrs) == 0 {
+			panic("redis cluster mode, missing REDIS_CLUSTER_ADDRS environment")
+		}
+	default:
+		panic(`REDIS_MODE environment must one of "standalone", "sentinel", or "cluster"`)
+	}
+	env.Redis.ReadTLS = parseBool("REDIS_READ_TLS")
+	env.Redis.WriteTLS = parseBool("REDIS_WRITE_TLS")
+	env.Redis.TLSSkipVerify = parseBool("REDIS_TLS_SKIP_VERIFY")
+
+	env.Redis.MaxIdle = 10
+	if maxIdle, err := strconv.Atoi(os.Getenv("REDIS_MAX_IDLE")); err == nil {
+		env.Redis.MaxIdle = maxIdle
+	}
+	env.Redis.MaxActive, _ = strconv.Atoi(os.Getenv("REDIS_MAX_ACTIVE")) // zero for unlimited connection
+	if env.Redis.IdleTimeout, _ = time.ParseDuration(os.Getenv("REDIS_IDLE_TIMEOUT")); env.Redis.IdleTimeout <= 0 {
+		env.Redis.IdleTimeout = 4 * time.Minute
+	}
+	env.Redis.MaxConnLifetime, _ = time.ParseDuration(os.Getenv("REDIS_MAX_CONN_LIFETIME")) // zero for not close connection by age
+}
+
+func splitNonEmpty(value string) (result []string) {
+	for _, s := range strings.Split(value, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			result = append(result, s)
+		}
+	}
+	return
+}
+
+// parseDuration parse optional duration environment, zero if not set
+func parseDuration(envName string) time.Duration {
+	value, ok := os.LookupEnv(envName)
+	if !ok || value == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		panic(fmt.Errorf("invalid %s environment: %v", envName, err))
+	}
+	return d
+}
+
+// parseNonNegativeInt parse optional non negative integer environment, zero if not set
+func parseNonNegativeInt(envName string) int {
+	value, ok := os.LookupEnv(envName)
+	if !ok || value == "" {
+		return 0
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil || i < 0 {
+		panic(envName + " environment must be non negative integer")
+	}
+	return i
 }
 
 func parseBool(envName string) bool {
@@ -0,0 +1,82 @@
+package env
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setTestEnv set environment variables and restore previous value after test
+func setTestEnv(t *testing.T, values map[string]string) {
+	for key, value := range values {
+		key := key
+		prev, ok := os.LookupEnv(key)
+		os.Setenv(key, value)
+		t.Cleanup(func() {
+			if ok {
+				os.Setenv(key, prev)
+			} else {
+				os.Unsetenv(key)
+			}
+		})
+	}
+}
+
+func TestParseBrokerEnvKafkaAuth(t *testing.T) {
+	baseEnv := BaseEnv()
+	defer SetEnv(baseEnv)
+
+	t.Run("valid", func(t *testing.T) {
+		setTestEnv(t, map[string]string{
+			"KAFKA_SASL_MECHANISM": "SCRAM-SHA-512", "KAFKA_SASL_USERNAME": "user", "KAFKA_SASL_PASSWORD": "secret",
+			"KAFKA_TLS_ENABLED": "true", "KAFKA_TLS_CA_FILE": "ca.pem", "KAFKA_TLS_CERT_FILE": "cert.pem", "KAFKA_TLS_KEY_FILE": "key.pem",
+		})
+		assert.NotPanics(t, parseBrokerEnv)
+		assert.Equal(t, "SCRAM-SHA-512", env.Kafka.SASLMechanism)
+		assert.Equal(t, "user", env.Kafka.SASLUsername)
+		assert.Equal(t, "secret", env.Kafka.SASLPassword)
+		assert.True(t, env.Kafka.TLSEnabled)
+		assert.Equal(t, "ca.pem", env.Kafka.TLSCAFile)
+		assert.Equal(t, "cert.pem", env.Kafka.TLSCertFile)
+		assert.Equal(t, "key.pem", env.Kafka.TLSKeyFile)
+	})
+
+	tests := []struct {
+		name   string
+		values map[string]string
+		panic  string
+	}{
+		{
+			name:   "invalid sasl mechanism",
+			values: map[string]string{"KAFKA_SASL_MECHANISM": "GSSAPI"},
+			panic:  `KAFKA_SASL_MECHANISM environment must one of "PLAIN", "SCRAM-SHA-256", or "SCRAM-SHA-512"`,
+		},
+		{
+			name:   "missing sasl password",
+			values: map[string]string{"KAFKA_SASL_MECHANISM": "PLAIN", "KAFKA_SASL_USERNAME": "user"},
+			panic:  "kafka sasl is active, missing KAFKA_SASL_USERNAME or KAFKA_SASL_PASSWORD environment",
+		},
+		{
+			name:   "tls certificate without key",
+			values: map[string]string{"KAFKA_TLS_ENABLED": "true", "KAFKA_TLS_CERT_FILE": "cert.pem"},
+			panic:  "kafka tls client certificate, KAFKA_TLS_CERT_FILE and KAFKA_TLS_KEY_FILE environment must be set together",
+		},
+		{
+			name:   "tls file without tls enabled",
+			values: map[string]string{"KAFKA_TLS_CA_FILE": "ca.pem"},
+			panic:  "kafka tls file or skip verify is set, but KAFKA_TLS_ENABLED environment is not true",
+		},
+		{
+			name:   "tls skip verify without tls enabled",
+			values: map[string]string{"KAFKA_TLS_SKIP_VERIFY": "true"},
+			panic:  "kafka tls file or skip verify is set, but KAFKA_TLS_ENABLED environment is not true",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestEnv(t, tt.values)
+			assert.PanicsWithValue(t, tt.panic, parseBrokerEnv)
+		})
+	}
+}
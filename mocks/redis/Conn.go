@@ -0,0 +1,142 @@
+package mocks
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Call command received by fake connection
+type Call struct {
+	Name string
+	Args []interface{}
+}
+
+// String format call as "CMD arg1 arg2 ..."
+func (c Call) String() string {
+	return strings.TrimSpace(fmt.Sprintln(append([]interface{}{c.Name}, c.Args...)...))
+}
+
+// Conn fake redis.Conn for test, record received commands and reply from Reply func.
+// Sent commands are replied in Receive or in next Do like redigo connection (Do return the last reply and the first error),
+// empty command from pool Close is ignored. Default reply is "OK"
+type Conn struct {
+	mu      sync.Mutex
+	reply   func(cmd string, args ...interface{}) (interface{}, error)
+	calls   []Call
+	pending []Call
+}
+
+// NewConn create fake connection with reply func
+func NewConn(reply func(cmd string, args ...interface{}) (interface{}, error)) *Conn {
+	return &Conn{reply: reply}
+}
+
+// SetReply change reply func
+func (c *Conn) SetReply(reply func(cmd string, args ...interface{}) (interface{}, error)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reply = reply
+}
+
+// Pool create pool which always return this connection
+func (c *Conn) Pool() *redis.Pool {
+	return &redis.Pool{Dial: func() (redis.Conn, error) { return c, nil }}
+}
+
+// Calls get all received commands
+func (c *Conn) Calls() []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Call{}, c.calls...)
+}
+
+// Commands get all received commands with all arguments ("CMD arg1 arg2 ...")
+func (c *Conn) Commands() (commands []string) {
+	for _, call := range c.Calls() {
+		commands = append(commands, call.String())
+	}
+	return commands
+}
+
+// CommandKeys get all received commands with the first argument only ("CMD key")
+func (c *Conn) CommandKeys() (commands []string) {
+	for _, call := range c.Calls() {
+		if len(call.Args) > 0 {
+			call.Args = call.Args[:1]
+		}
+		commands = append(commands, call.String())
+	}
+	return commands
+}
+
+// Names get name of all received commands
+func (c *Conn) Names() (names []string) {
+	for _, call := range c.Calls() {
+		names = append(names, call.Name)
+	}
+	return names
+}
+
+func (c *Conn) Close() error { return nil }
+func (c *Conn) Err() error   { return nil }
+
+func (c *Conn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = nil
+	if cmd != "" {
+		c.calls = append(c.calls, Call{Name: cmd, Args: args})
+		pending = append(pending, Call{Name: cmd, Args: args})
+	}
+	c.mu.Unlock()
+
+	var reply interface{}
+	var err error
+	for _, call := range pending {
+		r, e := c.do(call)
+		if _, ok := e.(redis.Error); !ok && e != nil {
+			return nil, e
+		}
+		if err == nil {
+			err = e
+		}
+		reply = r
+	}
+	return reply, err
+}
+
+func (c *Conn) Send(cmd string, args ...interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, Call{Name: cmd, Args: args})
+	c.pending = append(c.pending, Call{Name: cmd, Args: args})
+	return nil
+}
+
+func (c *Conn) Flush() error { return nil }
+
+func (c *Conn) Receive() (interface{}, error) {
+	c.mu.Lock()
+	if len(c.pending) == 0 {
+		c.mu.Unlock()
+		return nil, errors.New("no pending command")
+	}
+	call := c.pending[0]
+	c.pending = c.pending[1:]
+	c.mu.Unlock()
+	return c.do(call)
+}
+
+func (c *Conn) do(call Call) (interface{}, error) {
+	c.mu.Lock()
+	reply := c.reply
+	c.mu.Unlock()
+	if reply == nil {
+		return "OK", nil
+	}
+	return reply(call.Name, call.Args...)
+}
@@ -0,0 +1,94 @@
+package publisher
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	"github.com/golangid/candi/candishared"
+	"github.com/golangid/candi/logger"
+	"github.com/golangid/candi/tracer"
+)
+
+// KafkaAsyncOptionFunc option func type for kafka async publisher
+type KafkaAsyncOptionFunc func(*KafkaAsyncPublisher)
+
+// KafkaAsyncSetErrorHandler set handler for message which failed to deliver (default is log the error)
+func KafkaAsyncSetErrorHandler(handler func(err *sarama.ProducerError)) KafkaAsyncOptionFunc {
+	return func(p *KafkaAsyncPublisher) {
+		p.errorHandler = handler
+	}
+}
+
+// KafkaAsyncPublisher kafka publisher with async producer, message is sent in batch
+// (configured from Producer.Flush in sarama config) and PublishMessage does not wait broker response.
+// Delivery error is passed to error handler
+type KafkaAsyncPublisher struct {
+	producer     sarama.AsyncProducer
+	errorHandler func(err *sarama.ProducerError)
+	wg           sync.WaitGroup
+}
+
+// NewKafkaAsyncPublisher constructor
+func NewKafkaAsyncPublisher(client sarama.Client, opts ...KafkaAsyncOptionFunc) *KafkaAsyncPublisher {
+
+	producer, err := sarama.NewAsyncProducerFromClient(client)
+	if err != nil {
+		logger.LogYellow(fmt.Sprintf("(Kafka async publisher: warning, %v. Should be panicked when using kafka publisher.) ", err))
+		return nil
+	}
+	return newKafkaAsyncPublisher(producer, opts...)
+}
+
+func newKafkaAsyncPublisher(producer sarama.AsyncProducer, opts ...KafkaAsyncOptionFunc) *KafkaAsyncPublisher {
+	p := &KafkaAsyncPublisher{
+		producer: producer,
+		errorHandler: func(err *sarama.ProducerError) {
+			logger.LogRed(fmt.Sprintf("kafka_async_publisher > failed deliver message to topic %s: %v", err.Msg.Topic, err.Err))
+		},
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	p.wg.Add(2)
+	go func() {
+		defer p.wg.Done()
+		for err := range producer.Errors() {
+			p.errorHandler(err)
+		}
+	}()
+	go func() {
+		defer p.wg.Done()
+		for range producer.Successes() {
+			// drain success channel if Producer.Return.Successes is enabled
+		}
+	}()
+	return p
+}
+
+// PublishMessage method, message is queued to producer and will be sent in batch
+func (p *KafkaAsyncPublisher) PublishMessage(ctx context.Context, args *candishared.PublisherArgument) (err error) {
+	trace := tracer.StartTrace(ctx, "kafka:publish_message_async")
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+		trace.SetError(err)
+		trace.Finish()
+	}()
+
+	p.producer.Input() <- newKafkaProducerMessage(trace, args)
+	return
+}
+
+// Close flush pending messages and close producer
+func (p *KafkaAsyncPublisher) Close() error {
+	if p == nil {
+		return nil
+	}
+	p.producer.AsyncClose()
+	p.wg.Wait()
+	return nil
+}
@@ -0,0 +1,43 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"github.com/golangid/candi/candishared"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKafkaAsyncPublisher(t *testing.T) {
+	config := sarama.NewConfig()
+	config.Producer.Return.Successes = true
+	producer := mocks.NewAsyncProducer(t, config)
+	producer.ExpectInputAndSucceed()
+	producer.ExpectInputAndFail(errors.New("leader not available"))
+	producer.ExpectInputAndSucceed()
+
+	var mu sync.Mutex
+	var failed []*sarama.ProducerError
+	pub := newKafkaAsyncPublisher(producer, KafkaAsyncSetErrorHandler(func(err *sarama.ProducerError) {
+		mu.Lock()
+		defer mu.Unlock()
+		failed = append(failed, err)
+	}))
+
+	for _, topic := range []string{"orders", "payments", "orders"} {
+		assert.NoError(t, pub.PublishMessage(context.Background(), &candishared.PublisherArgument{Topic: topic, Key: "key", Data: "hello"}))
+	}
+	// pending messages are flushed and all delivery errors are handled before Close return
+	assert.NoError(t, pub.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+	if assert.Len(t, failed, 1) {
+		assert.Equal(t, "payments", failed[0].Msg.Topic)
+		assert.EqualError(t, failed[0].Err, "leader not available")
+	}
+}
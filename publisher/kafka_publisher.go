@@ -8,6 +8,7 @@ import (
 	"github.com/Shopify/sarama"
 	"github.com/golangid/candi/candihelper"
 	"github.com/golangid/candi/candishared"
+	"github.com/golangid/candi/codebase/interfaces"
 	"github.com/golangid/candi/logger"
 	"github.com/golangid/candi/tracer"
 )
@@ -42,18 +43,42 @@ func (p *KafkaPublisher) PublishMessage(ctx context.Context, args *candishared.P
 		trace.Finish()
 	}()
 
+	msg := newKafkaProducerMessage(trace, args)
+	_, _, err = p.producer.SendMessage(msg)
+	return
+}
+
+// Close producer
+func (p *KafkaPublisher) Close() error {
+	if p == nil {
+		return nil
+	}
+	return p.producer.Close()
+}
+
+// newKafkaProducerMessage build producer message from argument, active span is injected to message header
+func newKafkaProducerMessage(trace interfaces.Tracer, args *candishared.PublisherArgument) *sarama.ProducerMessage {
 	payload := candihelper.ToBytes(args.Data)
 
 	trace.SetTag("topic", args.Topic)
 	trace.SetTag("key", args.Key)
 	trace.Log("message", payload)
 
+	header := make(map[string]string, len(args.Header))
+	for key, value := range args.Header {
+		header[key] = string(candihelper.ToBytes(value))
+	}
+	tracer.InjectToHeader(trace.Context(), header)
+	trace.Log("header", header)
+
 	msg := &sarama.ProducerMessage{
 		Topic:     args.Topic,
 		Key:       sarama.ByteEncoder([]byte(args.Key)),
 		Value:     sarama.ByteEncoder(payload),
 		Timestamp: time.Now(),
 	}
-	_, _, err = p.producer.SendMessage(msg)
-	return
+	for key, value := range header {
+		msg.Headers = append(msg.Headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+	}
+	return msg
 }
@@ -0,0 +1,45 @@
+package publisher
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golangid/candi/candishared"
+	"github.com/golangid/candi/tracer"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKafkaProducerMessageTraceHeader(t *testing.T) {
+	globalTracer := opentracing.GlobalTracer()
+	defer opentracing.SetGlobalTracer(globalTracer)
+	mockTracer := mocktracer.New()
+	opentracing.SetGlobalTracer(mockTracer)
+
+	trace := tracer.StartTrace(context.Background(), "kafka:publish_message")
+	msg := newKafkaProducerMessage(trace, &candishared.PublisherArgument{
+		Topic: "orders", Key: "order-1", Data: "hello", Header: map[string]interface{}{"x-user-id": 10},
+	})
+	trace.Finish()
+
+	// consumer receive header of producer message and continue the span
+	header := make(map[string]string, len(msg.Headers))
+	for _, h := range msg.Headers {
+		header[string(h.Key)] = string(h.Value)
+	}
+	assert.Equal(t, "10", header["x-user-id"])
+	consumerTrace, _ := tracer.StartTraceFromHeader(context.Background(), "KafkaConsumer", header)
+	consumerTrace.Finish()
+
+	spans := mockTracer.FinishedSpans()
+	if assert.Len(t, spans, 2) {
+		producer, consumer := spans[0], spans[1]
+		assert.Equal(t, ext.SpanKindProducerEnum, producer.Tag("span.kind"))
+		assert.Equal(t, "orders", producer.Tag("topic"))
+		assert.Equal(t, producer.SpanContext.TraceID, consumer.SpanContext.TraceID)
+		assert.Equal(t, producer.SpanContext.SpanID, consumer.ParentID)
+		assert.Equal(t, ext.SpanKindConsumerEnum, consumer.Tag("span.kind"))
+	}
+}
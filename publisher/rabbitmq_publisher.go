@@ -17,16 +17,31 @@ const (
 	RabbitMQDelayHeader = "x-delay"
 )
 
+// RabbitMQOptionFunc type
+type RabbitMQOptionFunc func(*RabbitMQPublisher)
+
+// RabbitMQSetConnection option func, get current connection from getter for each publish
+// so that publisher use the new connection after reconnected
+func RabbitMQSetConnection(getter func() *amqp.Connection) RabbitMQOptionFunc {
+	return func(r *RabbitMQPublisher) {
+		r.connection = getter
+	}
+}
+
 // RabbitMQPublisher rabbitmq
 type RabbitMQPublisher struct {
-	conn *amqp.Connection
+	connection func() *amqp.Connection
 }
 
 // NewRabbitMQPublisher constructor
-func NewRabbitMQPublisher(conn *amqp.Connection) *RabbitMQPublisher {
-	return &RabbitMQPublisher{
-		conn: conn,
+func NewRabbitMQPublisher(conn *amqp.Connection, opts ...RabbitMQOptionFunc) *RabbitMQPublisher {
+	pub := &RabbitMQPublisher{
+		connection: func() *amqp.Connection { return conn },
+	}
+	for _, opt := range opts {
+		opt(pub)
 	}
+	return pub
 }
 
 // PublishMessage method
@@ -40,7 +55,7 @@ func (r *RabbitMQPublisher) PublishMessage(ctx context.Context, args *candishare
 		trace.Finish()
 	}()
 
-	ch, err := r.conn.Channel()
+	ch, err := r.connection().Channel()
 	if err != nil {
 		return err
 	}
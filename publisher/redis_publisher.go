@@ -0,0 +1,68 @@
+package publisher
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/golangid/candi/candihelper"
+	"github.com/golangid/candi/candishared"
+	"github.com/golangid/candi/candiutils"
+	"github.com/golangid/candi/config/env"
+	"github.com/golangid/candi/tracer"
+	"github.com/gomodule/redigo/redis"
+)
+
+// RedisPublisher redis, publish scheduled job to redis subscriber worker
+type RedisPublisher struct {
+	pool  *redis.Pool
+	queue *candiutils.RedisDelayedQueue
+}
+
+// NewRedisPublisher constructor, message is pushed to sorted set delayed queue if REDIS_WORKER_QUEUE_MODE is "sorted_set",
+// otherwise using expired key (keyspace notification)
+func NewRedisPublisher(pool *redis.Pool) *RedisPublisher {
+	pub := &RedisPublisher{
+		pool: pool,
+	}
+	if env.BaseEnv().RedisWorker.QueueMode == "sorted_set" {
+		pub.queue = candiutils.NewRedisDelayedQueue(pool, env.BaseEnv().RedisWorker.QueueKey,
+			env.BaseEnv().RedisWorker.VisibilityTimeout)
+	}
+	return pub
+}
+
+// PublishMessage method, args.Topic is handler name in redis subscriber worker, message will be executed after args.Delay
+func (r *RedisPublisher) PublishMessage(ctx context.Context, args *candishared.PublisherArgument) (err error) {
+	trace := tracer.StartTrace(ctx, "redis:publish_message")
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+		trace.SetError(err)
+		trace.Finish()
+	}()
+
+	message := candihelper.ToBytes(args.Data)
+	trace.SetTag("handler_name", args.Topic)
+	trace.SetTag("delay", args.Delay.String())
+	trace.Log("message", message)
+
+	if r.queue != nil {
+		id, err := r.queue.Push(ctx, args.Topic, message, args.Delay)
+		trace.SetTag("message_id", id)
+		return err
+	}
+
+	// key expiration must be positive
+	delay := args.Delay
+	if delay < time.Millisecond {
+		delay = time.Millisecond
+	}
+
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	_, err = conn.Do("SET", candihelper.BuildRedisPubSubKeyTopic(args.Topic, message), "ok", "PX", delay.Milliseconds())
+	return err
+}
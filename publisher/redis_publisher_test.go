@@ -0,0 +1,72 @@
+package publisher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golangid/candi/candihelper"
+	"github.com/golangid/candi/candishared"
+	"github.com/golangid/candi/config/env"
+	mocks "github.com/golangid/candi/mocks/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisPublisherDelay(t *testing.T) {
+	baseEnv := env.BaseEnv()
+	defer env.SetEnv(baseEnv)
+	newEnv := baseEnv
+	newEnv.RedisWorker.QueueMode = "keyspace"
+	env.SetEnv(newEnv)
+
+	tests := []struct {
+		name  string
+		delay time.Duration
+		want  int64
+	}{
+		{name: "key expired after delay", delay: 5 * time.Minute, want: 300000},
+		{name: "without delay expired immediately", delay: 0, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := mocks.NewConn(nil)
+			pub := NewRedisPublisher(conn.Pool())
+			assert.NoError(t, pub.PublishMessage(context.Background(), &candishared.PublisherArgument{
+				Topic: "push-notif", Data: "hello", Delay: tt.delay,
+			}))
+
+			calls := conn.Calls()
+			if assert.Len(t, calls, 1) {
+				assert.Equal(t, "SET", calls[0].Name)
+				assert.Equal(t, []interface{}{candihelper.BuildRedisPubSubKeyTopic("push-notif", []byte("hello")), "ok", "PX", tt.want}, calls[0].Args)
+			}
+		})
+	}
+}
+
+func TestRedisPublisherDelayedQueue(t *testing.T) {
+	baseEnv := env.BaseEnv()
+	defer env.SetEnv(baseEnv)
+	newEnv := baseEnv
+	newEnv.RedisWorker.QueueMode, newEnv.RedisWorker.QueueKey = "sorted_set", "service:redis_worker"
+	env.SetEnv(newEnv)
+
+	conn := mocks.NewConn(nil)
+	pub := NewRedisPublisher(conn.Pool())
+	publishedAt := time.Now()
+	assert.NoError(t, pub.PublishMessage(context.Background(), &candishared.PublisherArgument{
+		Topic: "push-notif", Data: "hello", Delay: time.Minute,
+	}))
+
+	calls := conn.Calls()
+	if assert.Len(t, calls, 4) {
+		assert.Equal(t, "MULTI", calls[0].Name)
+		assert.Equal(t, []interface{}{"{service:redis_worker}:payload"}, calls[1].Args[:1])
+		assert.Equal(t, "ZADD", calls[2].Name)
+		assert.Equal(t, "{service:redis_worker}:delayed", calls[2].Args[0])
+		dueAt := calls[2].Args[1].(int64)
+		assert.InDelta(t, publishedAt.Add(time.Minute).UnixNano()/int64(time.Millisecond), dueAt, float64(time.Second.Milliseconds()),
+			"message is ready to claim after delay")
+		assert.Equal(t, "EXEC", calls[3].Name)
+	}
+}
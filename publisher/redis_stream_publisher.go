@@ -0,0 +1,70 @@
+package publisher
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/golangid/candi/candihelper"
+	"github.com/golangid/candi/candishared"
+	"github.com/golangid/candi/tracer"
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	// RedisStreamMessageField field name of message data in redis stream entry
+	RedisStreamMessageField = "message"
+	// RedisStreamKeyField field name of message key in redis stream entry
+	RedisStreamKeyField = "key"
+)
+
+// RedisStreamPublisher redis stream
+type RedisStreamPublisher struct {
+	pool *redis.Pool
+}
+
+// NewRedisStreamPublisher constructor
+func NewRedisStreamPublisher(pool *redis.Pool) *RedisStreamPublisher {
+	return &RedisStreamPublisher{
+		pool: pool,
+	}
+}
+
+// PublishMessage method, append message to stream (args.Topic) with XADD, header is stored as additional field in stream entry.
+// Header key cannot be RedisStreamMessageField or RedisStreamKeyField
+func (r *RedisStreamPublisher) PublishMessage(ctx context.Context, args *candishared.PublisherArgument) (err error) {
+	trace := tracer.StartTrace(ctx, "redis_stream:publish_message")
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+		trace.SetError(err)
+		trace.Finish()
+	}()
+
+	for key := range args.Header {
+		if key == RedisStreamMessageField || key == RedisStreamKeyField {
+			return fmt.Errorf(`redis stream: header "%s" is reserved field of stream entry`, key)
+		}
+	}
+
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	message := candihelper.ToBytes(args.Data)
+	cmdArgs := redis.Args{args.Topic, "*", RedisStreamMessageField, message}
+	if args.Key != "" {
+		cmdArgs = cmdArgs.Add(RedisStreamKeyField, args.Key)
+	}
+	for key, value := range args.Header {
+		cmdArgs = cmdArgs.Add(key, fmt.Sprint(value))
+	}
+
+	trace.SetTag("stream", args.Topic)
+	trace.SetTag("key", args.Key)
+	trace.Log("header", args.Header)
+	trace.Log("message", message)
+
+	id, err := redis.String(conn.Do("XADD", cmdArgs...))
+	trace.SetTag("message_id", id)
+	return err
+}
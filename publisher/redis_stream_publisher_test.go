@@ -0,0 +1,19 @@
+package publisher
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golangid/candi/candishared"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisStreamPublisherReservedHeader(t *testing.T) {
+	pub := NewRedisStreamPublisher(nil)
+	for _, field := range []string{RedisStreamMessageField, RedisStreamKeyField} {
+		err := pub.PublishMessage(context.Background(), &candishared.PublisherArgument{
+			Topic: "orders", Data: "hello", Header: map[string]interface{}{field: "overwrite"},
+		})
+		assert.EqualError(t, err, `redis stream: header "`+field+`" is reserved field of stream entry`)
+	}
+}
@@ -162,6 +162,35 @@ func (t *tracerImpl) InjectGRPCMetadata(md metadata.MD) {
 	)
 }
 
+// StartTraceFromHeader starting trace span with parent span extracted from message header (example: kafka message header),
+// new root span is created if header not contains span
+func StartTraceFromHeader(ctx context.Context, operationName string, header map[string]string) (interfaces.Tracer, context.Context) {
+	if candishared.GetValueFromContext(ctx, skipTracer) != nil {
+		return &tracerImpl{ctx: ctx}, ctx
+	}
+
+	globalTracer := opentracing.GlobalTracer()
+	spanCtx, err := globalTracer.Extract(opentracing.TextMap, opentracing.TextMapCarrier(header))
+	if err != nil {
+		return StartTraceWithContext(ctx, operationName)
+	}
+
+	span := globalTracer.StartSpan(operationName, opentracing.ChildOf(spanCtx), ext.SpanKindConsumer)
+	ctx = opentracing.ContextWithSpan(ctx, span)
+	return &tracerImpl{ctx: ctx, span: span}, ctx
+}
+
+// InjectToHeader to continue active span in context to message header (example: kafka message header)
+func InjectToHeader(ctx context.Context, header map[string]string) {
+	span := opentracing.SpanFromContext(ctx)
+	if span == nil {
+		return
+	}
+
+	ext.SpanKindProducer.Set(span)
+	span.Tracer().Inject(span.Context(), opentracing.TextMap, opentracing.TextMapCarrier(header))
+}
+
 // SetError set error in span
 func (t *tracerImpl) SetError(err error) {
 	SetError(t.ctx, err)
@@ -84,6 +84,12 @@ func (v *JSONSchemaValidator) ValidateDocument(schemaID string, documentSource i
 		return err
 	}
 
+	return ValidateJSONSchemaDocument(schema, documentSource)
+}
+
+// ValidateJSONSchemaDocument validate document with given compiled json schema
+func ValidateJSONSchemaDocument(schema *gojsonschema.Schema, documentSource interface{}) error {
+
 	document := gojsonschema.NewBytesLoader(candihelper.ToBytes(documentSource))
 	result, err := schema.Validate(document)
 	if err != nil {